@@ -2,6 +2,7 @@ package acr
 
 import (
 	"fmt"
+	"math"
 	"strings"
 	"testing"
 
@@ -385,6 +386,172 @@ func TestALLACCTRAN(t *testing.T) {
 	testCheckMap(t, "t21", statemap, "A")
 }
 
+func TestSankoffUnitCosts(t *testing.T) {
+	// With unit costs, Sankoff parsimony should give the same states as Fitch
+	// parsimony for downpass (all most parsimonious states) and uppass only
+	treeString := "(t1,(t2,((t3,(t4,t5)t6)t7,(t8,((t9,t10)t11,((t12,t13)t14,t15)t16)t17)t18)t19)t20)t21;"
+	tipstates := map[string]string{
+		"t1": "A", "t2": "A", "t3": "B", "t4": "B", "t5": "A", "t8": "B",
+		"t9": "B", "t10": "A", "t12": "A", "t13": "A", "t15": "A",
+	}
+	for _, algo := range []int{ALGO_DOWNPASS, ALGO_NONE} {
+		tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fitchmap, nsteps, err := ParsimonyAcr(tr.Clone(), tipstates, algo, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sankoffmap, cost, err := SankoffAcr(tr, tipstates, []string{"A", "B"}, [][]float64{{0, 1}, {1, 0}}, algo, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cost != float64(nsteps) {
+			t.Errorf("Sankoff cost is %f and should be %d", cost, nsteps)
+		}
+		for k, v := range fitchmap {
+			testCheckMap(t, k, sankoffmap, strings.Split(v, ",")...)
+		}
+	}
+}
+
+func TestSankoffAsymmetricCosts(t *testing.T) {
+	// Reversals (B->A) are very costly: B appears twice independently
+	treeString := "(((t1,t2)n1,(t3,t4)n2)n3,(t5,t6)n4)root;"
+	tipstates := map[string]string{"t1": "A", "t2": "B", "t3": "B", "t4": "A", "t5": "B", "t6": "B"}
+	costs := [][]float64{{0, 1}, {100, 0}}
+
+	for _, algo := range []int{ALGO_DOWNPASS, ALGO_DELTRAN, ALGO_ACCTRAN} {
+		tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		statemap, cost, err := SankoffAcr(tr, tipstates, []string{"A", "B"}, costs, algo, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cost != 3 {
+			t.Errorf("Sankoff cost is %f and should be %d", cost, 3)
+		}
+		testCheckMap(t, "root", statemap, "A")
+		testCheckMap(t, "n1", statemap, "A")
+		testCheckMap(t, "n2", statemap, "A")
+		testCheckMap(t, "n3", statemap, "A")
+		testCheckMap(t, "n4", statemap, "B")
+	}
+
+	tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = SankoffAcr(tr, tipstates, []string{"A", "B"}, [][]float64{{0, 1}}, ALGO_DOWNPASS, false); err == nil {
+		t.Error("Sankoff parsimony should fail with a non square cost matrix")
+	}
+	if _, _, err = SankoffAcr(tr, tipstates, []string{"A", "C"}, costs, ALGO_DOWNPASS, false); err == nil {
+		t.Error("Sankoff parsimony should fail when a tip state is not in the cost matrix")
+	}
+}
+
+func TestSankoffRandomResolveDownpass(t *testing.T) {
+	// Internal nodes are either all A or all B in the most parsimonious
+	// reconstructions: the randomly resolved reconstruction must be one of them
+	treeString := "(((t1,t2)n1,(t3,t4)n2)n3,(t5,t6)n4)root;"
+	tipstates := map[string]string{"t1": "A", "t2": "B", "t3": "A", "t4": "B", "t5": "A", "t6": "B"}
+	costs := [][]float64{{0, 1}, {1, 0}}
+	index := map[string]int{"A": 0, "B": 1}
+
+	for i := 0; i < 50; i++ {
+		tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		statemap, cost, err := SankoffAcr(tr, tipstates, []string{"A", "B"}, costs, ALGO_DOWNPASS, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for k, v := range tipstates {
+			statemap[k] = v
+		}
+		reconstruction := 0.0
+		for _, e := range tr.Edges() {
+			reconstruction += costs[index[statemap[e.Left().Name()]]][index[statemap[e.Right().Name()]]]
+		}
+		if reconstruction != cost {
+			t.Fatalf("Cost of the randomly resolved reconstruction is %f and should be %f: %v", reconstruction, cost, statemap)
+		}
+	}
+}
+
+func TestLikelihoodAcr(t *testing.T) {
+	treeString := "((A:0.1,B:0.2)n1:0.3,(C:0.2,D:0.4)n2:0.1,E:0.5)root;"
+	tipstates := map[string]string{"A": "X", "B": "X", "C": "Y", "D": "Z", "E": "Y"}
+	// Expected values computed by enumerating all combinations of ancestral states
+	expectedlnl := map[int]float64{MODEL_MK: -5.688635, MODEL_F81: -5.646787}
+	expectedpost := map[int]map[string][]float64{
+		MODEL_MK: {
+			"root": {0.201149, 0.699052, 0.099799},
+			"n1":   {0.969739, 0.023269, 0.006992},
+			"n2":   {0.121015, 0.745031, 0.133955},
+		},
+		MODEL_F81: {
+			"root": {0.228014, 0.676549, 0.095438},
+			"n1":   {0.965476, 0.028261, 0.006262},
+			"n2":   {0.139545, 0.726057, 0.134397},
+		},
+	}
+
+	for _, model := range []int{MODEL_MK, MODEL_F81} {
+		tr, err := newick.NewParser(strings.NewReader(treeString)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, statemap, err := LikelihoodAcr(tr, tipstates, model)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(res.LogLikelihood-expectedlnl[model]) > 1e-6 {
+			t.Errorf("Log likelihood is %f and should be %f", res.LogLikelihood, expectedlnl[model])
+		}
+		ni := tree.NewAllNodeIndex(tr)
+		for name, exp := range expectedpost[model] {
+			n, _ := ni.GetNode(name)
+			for i, p := range res.Posteriors[n.Id()] {
+				if math.Abs(p-exp[i]) > 1e-6 {
+					t.Errorf("Posteriors of node %s are %v and should be %v", name, res.Posteriors[n.Id()], exp)
+					break
+				}
+			}
+		}
+		for name, state := range tipstates {
+			n, _ := ni.GetNode(name)
+			if res.Alphabet[res.Joint[n.Id()]] != state || res.Posteriors[n.Id()][res.Joint[n.Id()]] != 1.0 {
+				t.Errorf("Tip %s should have state %s", name, state)
+			}
+		}
+		testCheckMap(t, "root", statemap, "Y")
+		testCheckMap(t, "n1", statemap, "X")
+		testCheckMap(t, "n2", statemap, "Y")
+	}
+}
+
+func TestLikelihoodAcrErrors(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("((A,B),C,D);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = LikelihoodAcr(tr, map[string]string{"A": "X", "B": "X", "C": "Y", "D": "Y"}, MODEL_MK); err == nil {
+		t.Error("Likelihood ACR should fail when branch lengths are not defined")
+	}
+	tr, err = newick.NewParser(strings.NewReader("((A:1,B:1):1,C:1,D:1);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = LikelihoodAcr(tr, map[string]string{"A": "X", "B": "X", "C": "Y"}, MODEL_MK); err == nil {
+		t.Error("Likelihood ACR should fail when a tip has no state")
+	}
+}
+
 func testCheckStates(t *testing.T, nstates int, ni tree.NodeIndex, nodename string,
 	states []AncestralState, stateIndices map[string]int, teststates ...string) {
 	n, ok := ni.GetNode(nodename)
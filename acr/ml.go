@@ -0,0 +1,291 @@
+package acr
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+const (
+	MODEL_MK  = iota // All states have the same equilibrium frequency
+	MODEL_F81        // Equilibrium frequencies are the frequencies of states at tips
+)
+
+// Result of a maximum likelihood ancestral character reconstruction
+type LikelihoodAcrResult struct {
+	Alphabet      []string         // Possible states, in alphanumeric order
+	Frequencies   []float64        // Equilibrium frequencies of each state
+	Posteriors    []AncestralState // Marginal posterior probabilities of each state for each node (indexed by node id)
+	Joint         []int            // Index of the state of each node (indexed by node id) in the joint reconstruction
+	LogLikelihood float64          // Log likelihood of the tree
+}
+
+// Reconstructs ancestral characters by maximum likelihood, under the
+// Mk (MODEL_MK) or F81-like (MODEL_F81) model of character evolution:
+// the probability that the state j is observed after a branch of length
+// l, starting from the state i is:
+// P(i->j) = exp(-b*l)*d(i,j) + (1-exp(-b*l))*pi(j)
+// with pi(j) the equilibrium frequency of state j, d(i,j)=1 if i==j (0 otherwise),
+// and b=1/(1-sum(pi(i)^2)) the normalization factor, such that branch lengths are
+// expressed in expected number of changes. Under MODEL_MK, all states have the same
+// frequency, and under MODEL_F81, frequencies are the frequencies of states at tips.
+//
+// Branch lengths of the tree must be defined. The tree is considered rooted at its
+// root node, but the root does not change the likelihood, nor the marginal posteriors.
+//
+// It computes the marginal posterior probabilities of each state at each node, and the joint
+// reconstruction (Pupko et al., 2000), i.e. the most likely combination of ancestral states.
+//
+// Nodes are annotated with their state in the joint reconstruction
+// (comment field, first index), and the function returns a map with the
+// joint states of all internal nodes: If a node has a name, key is its name,
+// if a node has no name, the key will be its id in the deep first traversal
+// of the tree (see ParsimonyAcr).
+func LikelihoodAcr(t *tree.Tree, tipCharacters map[string]string, model int) (res *LikelihoodAcrResult, nametostates map[string]string, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var down []AncestralState = make([]AncestralState, len(nodes))    // Conditional likelihoods of the subtree of each node
+	var up []AncestralState = make([]AncestralState, len(nodes))      // Conditional likelihoods of the rest of the tree, at each node
+	var scalers []float64 = make([]float64, len(nodes))               // Log scaling factors of down likelihoods
+	var jointlk []AncestralState = make([]AncestralState, len(nodes)) // Pupko's log likelihoods of each node given the state of its parent
+	var jointst [][]int = make([][]int, len(nodes))                   // Pupko's best state of each node given the state of its parent
+	var alphabet []string = make([]string, 0, 10)
+	var seenState map[string]bool = make(map[string]bool)
+	var stateIndices map[string]int
+	var tipstates []int = make([]int, len(nodes))
+	var parents []*tree.Node = make([]*tree.Node, len(nodes))
+	var pi []float64
+	var nstates int
+	var beta float64
+
+	for _, state := range tipCharacters {
+		if _, ok := seenState[state]; !ok {
+			alphabet = append(alphabet, state)
+		}
+		seenState[state] = true
+	}
+	sort.Strings(alphabet)
+	stateIndices = AncestralStateIndices(alphabet)
+	nstates = len(alphabet)
+	if nstates == 0 {
+		err = fmt.Errorf("no tip state given")
+		return
+	}
+
+	for i, n := range nodes {
+		n.SetId(i)
+		if n.Tip() {
+			state, ok := tipCharacters[n.Name()]
+			if !ok {
+				err = fmt.Errorf("Tip %s does not exist in the tip/state mapping file", n.Name())
+				return
+			}
+			tipstates[i] = stateIndices[state]
+		}
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == tree.NIL_LENGTH || e.Length() < 0 {
+			err = fmt.Errorf("all branch lengths must be defined and >= 0 for likelihood reconstruction")
+			return
+		}
+	}
+
+	// Equilibrium frequencies
+	pi = make([]float64, nstates)
+	switch model {
+	case MODEL_MK:
+		for i := range pi {
+			pi[i] = 1.0 / float64(nstates)
+		}
+	case MODEL_F81:
+		ntips := 0
+		for _, n := range nodes {
+			if n.Tip() {
+				pi[tipstates[n.Id()]]++
+				ntips++
+			}
+		}
+		for i := range pi {
+			pi[i] /= float64(ntips)
+		}
+	default:
+		err = fmt.Errorf("Likelihood model %d unknown", model)
+		return
+	}
+	beta = 1.0
+	for _, p := range pi {
+		beta -= p * p
+	}
+	if beta > 0 {
+		beta = 1.0 / beta
+	}
+
+	res = &LikelihoodAcrResult{
+		Alphabet:    alphabet,
+		Frequencies: pi,
+		Posteriors:  make([]AncestralState, len(nodes)),
+		Joint:       make([]int, len(nodes)),
+	}
+
+	// Probability of a change along an edge
+	pij := func(i, j int, l float64) float64 {
+		e := math.Exp(-beta * l)
+		if i == j {
+			return e + (1-e)*pi[j]
+		}
+		return (1 - e) * pi[j]
+	}
+
+	// Post order traversal: conditional likelihoods of subtrees, and joint
+	// reconstruction likelihoods
+	t.PostOrder(func(cur, prev *tree.Node, e *tree.Edge) bool {
+		d := make(AncestralState, nstates)
+		if cur.Tip() {
+			d[tipstates[cur.Id()]] = 1.0
+		} else {
+			for i := range d {
+				d[i] = 1.0
+			}
+			for k, child := range cur.Neigh() {
+				if child == prev {
+					continue
+				}
+				l := cur.Edges()[k].Length()
+				for i := range d {
+					s := 0.0
+					for j, v := range down[child.Id()] {
+						s += pij(i, j, l) * v
+					}
+					d[i] *= s
+				}
+				scalers[cur.Id()] += scalers[child.Id()]
+			}
+			max := 0.0
+			for _, v := range d {
+				max = math.Max(max, v)
+			}
+			if max > 0 {
+				for i := range d {
+					d[i] /= max
+				}
+				scalers[cur.Id()] += math.Log(max)
+			}
+		}
+		down[cur.Id()] = d
+
+		// Joint reconstruction (Pupko et al., 2000), in log space:
+		// For each state i of the parent, best state j of the current node
+		if e != nil {
+			jointlk[cur.Id()] = make(AncestralState, nstates)
+			jointst[cur.Id()] = make([]int, nstates)
+			for i := 0; i < nstates; i++ {
+				jointlk[cur.Id()][i] = math.Inf(-1)
+				for j := 0; j < nstates; j++ {
+					if cur.Tip() && j != tipstates[cur.Id()] {
+						continue
+					}
+					v := math.Log(pij(i, j, e.Length())) + childrenJointLikelihood(cur, prev, j, jointlk)
+					if v > jointlk[cur.Id()][i] {
+						jointlk[cur.Id()][i] = v
+						jointst[cur.Id()][i] = j
+					}
+				}
+			}
+		}
+		return true
+	})
+
+	root := t.Root()
+	lk := 0.0
+	for i, v := range down[root.Id()] {
+		lk += pi[i] * v
+	}
+	res.LogLikelihood = math.Log(lk) + scalers[root.Id()]
+
+	// Pre order traversal: conditional likelihoods of the rest of the tree,
+	// marginal posteriors, and joint states
+	t.PreOrder(func(cur, prev *tree.Node, e *tree.Edge) bool {
+		u := make(AncestralState, nstates)
+		parents[cur.Id()] = prev
+		if prev == nil {
+			copy(u, pi)
+			best := math.Inf(-1)
+			for i := range pi {
+				if cur.Tip() && i != tipstates[cur.Id()] {
+					continue
+				}
+				if v := math.Log(pi[i]) + childrenJointLikelihood(cur, nil, i, jointlk); v > best {
+					best = v
+					res.Joint[cur.Id()] = i
+				}
+			}
+		} else {
+			// Likelihood of the rest of the tree at the parent node
+			parentside := make(AncestralState, nstates)
+			copy(parentside, up[prev.Id()])
+			for k, sibling := range prev.Neigh() {
+				if sibling == cur || sibling == parents[prev.Id()] {
+					continue
+				}
+				l := prev.Edges()[k].Length()
+				for i := range parentside {
+					s := 0.0
+					for j, v := range down[sibling.Id()] {
+						s += pij(i, j, l) * v
+					}
+					parentside[i] *= s
+				}
+			}
+			for j := range u {
+				for i, v := range parentside {
+					u[j] += v * pij(i, j, e.Length())
+				}
+			}
+			normalize(u)
+			res.Joint[cur.Id()] = jointst[cur.Id()][res.Joint[prev.Id()]]
+		}
+		up[cur.Id()] = u
+
+		post := make(AncestralState, nstates)
+		for i := range post {
+			post[i] = u[i] * down[cur.Id()][i]
+		}
+		normalize(post)
+		res.Posteriors[cur.Id()] = post
+		return true
+	})
+
+	// Annotation of the tree with joint states
+	states := make([]AncestralState, len(nodes))
+	for i := range states {
+		states[i] = make(AncestralState, nstates)
+		states[i][res.Joint[i]] = 1
+	}
+	nametostates = buildInternalNamesToStatesMap(t, states, alphabet)
+	assignStatesToTree(t, states, alphabet)
+	return
+}
+
+// Sums the joint reconstruction log likelihoods of the children of the node
+// given its state
+func childrenJointLikelihood(cur, prev *tree.Node, state int, jointlk []AncestralState) (lk float64) {
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			lk += jointlk[child.Id()][state]
+		}
+	}
+	return
+}
+
+func normalize(v AncestralState) {
+	sum := 0.0
+	for _, p := range v {
+		sum += p
+	}
+	if sum > 0 {
+		for i := range v {
+			v[i] /= sum
+		}
+	}
+}
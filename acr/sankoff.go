@@ -0,0 +1,259 @@
+package acr
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Tolerance used to compare parsimony costs
+const SANKOFF_EPSILON = 1e-10
+
+// Will annotate the tree nodes with ancestral characters
+// computed using Sankoff weighted parsimony.
+//
+// alphabet: possible states, and costs: cost of a change from state
+// alphabet[i] (ancestor) to state alphabet[j] (descendant) is costs[i][j].
+// The cost matrix may be asymmetric (e.g. to penalize reversals).
+//
+// Characters will be located in the comment field of each node at the first index.
+// tipCharacters: mapping between tipnames and character state.
+// Algo: One of ALGO_DELTRAN, ALGO_ACCTRAN, ALGO_DOWNPASS, and ALGO_NONE : returns an error otherwise
+// If ALGO_NONE, just executes UPPASS, and each node gets the states having the
+// minimum cost for its subtree,
+// If ALGO_DOWNPASS, executes UPPASS then DOWNPASS: each node gets all the states
+// it has in at least one most parsimonious reconstruction,
+// If ALGO_DELTRAN or ALGO_ACCTRAN, executes UPPASS, then goes from the root to the tips,
+// and each node gets the states that are optimal given the states of its parent.
+// If one of these states is the state of the parent, then DELTRAN keeps only the states
+// of the parent (changes are delayed), whereas ACCTRAN discards them if possible
+// (changes are accelerated).
+// Returns a map with the states of all internal nodes. If a node has a name, key is its name,
+// if a node has no name, the key will be its id in the deep first traversal of the tree.
+// Also returns the parsimony cost of the tree.
+// if randomResolve is true, then in the second pass, each ambiguities will be resolved randomly
+func SankoffAcr(t *tree.Tree, tipCharacters map[string]string, alphabet []string, costs [][]float64, algo int, randomResolve bool) (nametostates map[string]string, cost float64, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var subcosts []AncestralState = make([]AncestralState, len(nodes)) // Cost of the subtree of each node, for each of its states
+	var upcosts []AncestralState = make([]AncestralState, len(nodes))  // Cost of the rest of the tree, for each state of each node
+	var states []AncestralState = make([]AncestralState, len(nodes))   // Reconstructed states of each node
+	var stateIndices map[string]int = AncestralStateIndices(alphabet)
+
+	if err = CheckCostMatrix(len(alphabet), costs); err != nil {
+		return
+	}
+
+	for i, n := range nodes {
+		n.SetId(i)
+		subcosts[i] = make(AncestralState, len(alphabet))
+		upcosts[i] = make(AncestralState, len(alphabet))
+		states[i] = make(AncestralState, len(alphabet))
+	}
+
+	if err = sankoffUPPASS(t.Root(), nil, tipCharacters, subcosts, costs, stateIndices); err != nil {
+		return
+	}
+	cost = math.Inf(1)
+	for _, c := range subcosts[t.Root().Id()] {
+		cost = math.Min(cost, c)
+	}
+
+	switch algo {
+	case ALGO_NONE:
+		for i := range nodes {
+			minCostStates(subcosts[i], states[i])
+		}
+	case ALGO_DOWNPASS:
+		sankoffDOWNPASS(t.Root(), nil, subcosts, upcosts, states, costs, randomResolve)
+	case ALGO_DELTRAN, ALGO_ACCTRAN:
+		minCostStates(subcosts[t.Root().Id()], states[t.Root().Id()])
+		if randomResolve {
+			randomlyResolveNodeStates(t.Root(), states)
+		}
+		sankoffTRAN(t.Root(), nil, subcosts, states, costs, algo == ALGO_DELTRAN, randomResolve)
+	default:
+		err = fmt.Errorf("Parsimony algorithm %d unknown", algo)
+		return
+	}
+
+	nametostates = buildInternalNamesToStatesMap(t, states, alphabet)
+	assignStatesToTree(t, states, alphabet)
+	return
+}
+
+// Checks that the Sankoff cost matrix is a square matrix of non negative
+// costs, with the same dimension as the number of states.
+//
+// Also used by the asr package for ancestral sequences.
+func CheckCostMatrix(nstates int, costs [][]float64) error {
+	if nstates == 0 {
+		return fmt.Errorf("Empty cost matrix")
+	}
+	if len(costs) != nstates {
+		return fmt.Errorf("Cost matrix has %d rows but there are %d states", len(costs), nstates)
+	}
+	for i, row := range costs {
+		if len(row) != nstates {
+			return fmt.Errorf("Row %d of the cost matrix has %d columns but there are %d states", i, len(row), nstates)
+		}
+		for _, c := range row {
+			if c < 0 || math.IsNaN(c) {
+				return fmt.Errorf("Costs must be >= 0")
+			}
+		}
+	}
+	return nil
+}
+
+// First step of the Sankoff parsimony: From tips to root, computes
+// the minimum cost of the subtree of each node, for each of its states
+func sankoffUPPASS(cur, prev *tree.Node, tipCharacters map[string]string, subcosts []AncestralState, costs [][]float64, stateIndices map[string]int) (err error) {
+	if cur.Tip() {
+		state, ok := tipCharacters[cur.Name()]
+		if !ok {
+			return fmt.Errorf("Tip %s does not exist in the tip/state mapping file", cur.Name())
+		}
+		stateindex, ok := stateIndices[state]
+		if !ok {
+			return fmt.Errorf("State %s of tip %s does not exist in the cost matrix", state, cur.Name())
+		}
+		for k := range subcosts[cur.Id()] {
+			subcosts[cur.Id()][k] = math.Inf(1)
+		}
+		subcosts[cur.Id()][stateindex] = 0
+		return
+	}
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			if err = sankoffUPPASS(child, cur, tipCharacters, subcosts, costs, stateIndices); err != nil {
+				return
+			}
+			for i := range subcosts[cur.Id()] {
+				subcosts[cur.Id()][i] += minTransitionCost(costs[i], subcosts[child.Id()])
+			}
+		}
+	}
+	return
+}
+
+// Second step of the Sankoff parsimony: From root to tips, computes the minimum
+// cost of the rest of the tree for each state of each node, and keeps
+// the states having the minimum total cost.
+//
+// If randomResolve is true, the state of each node is chosen randomly among them,
+// and the costs of its children are computed given this state, so that the
+// reconstruction is one of the most parsimonious reconstructions.
+func sankoffDOWNPASS(cur, prev *tree.Node, subcosts, upcosts, states []AncestralState, costs [][]float64, randomResolve bool) {
+	total := make(AncestralState, len(states[cur.Id()]))
+	for k := range total {
+		total[k] = subcosts[cur.Id()][k] + upcosts[cur.Id()][k]
+	}
+	minCostStates(total, states[cur.Id()])
+	if randomResolve && !cur.Tip() {
+		randomlyResolveNodeStates(cur, states)
+	}
+
+	for _, child := range cur.Neigh() {
+		if child == prev {
+			continue
+		}
+		// Cost of the tree without the subtree of child, for each state of cur
+		parentcosts := make(AncestralState, len(states[cur.Id()]))
+		for i := range parentcosts {
+			if randomResolve && states[cur.Id()][i] == 0 {
+				parentcosts[i] = math.Inf(1)
+				continue
+			}
+			parentcosts[i] = upcosts[cur.Id()][i]
+			for _, child2 := range cur.Neigh() {
+				if child2 != prev && child2 != child {
+					parentcosts[i] += minTransitionCost(costs[i], subcosts[child2.Id()])
+				}
+			}
+		}
+		for j := range upcosts[child.Id()] {
+			upcosts[child.Id()][j] = math.Inf(1)
+			for i, c := range parentcosts {
+				upcosts[child.Id()][j] = math.Min(upcosts[child.Id()][j], c+costs[i][j])
+			}
+		}
+		sankoffDOWNPASS(child, cur, subcosts, upcosts, states, costs, randomResolve)
+	}
+}
+
+// Second step of the Sankoff parsimony (instead of DOWNPASS) for resolving ambiguities:
+// From root to tips, each child gets the states that are optimal given at least one
+// of the states of its parent. If delayed is true (DELTRAN), and if the parent states
+// are among them, then they are kept. Otherwise (ACCTRAN), they are removed if possible
+func sankoffTRAN(cur, prev *tree.Node, subcosts, states []AncestralState, costs [][]float64, delayed bool, randomResolve bool) {
+	if cur.Tip() {
+		return
+	}
+	for _, child := range cur.Neigh() {
+		if child == prev {
+			continue
+		}
+		candidates := make(AncestralState, len(states[child.Id()]))
+		for i, c := range states[cur.Id()] {
+			if c > 0 {
+				min := minTransitionCost(costs[i], subcosts[child.Id()])
+				for j, sc := range subcosts[child.Id()] {
+					if costs[i][j]+sc <= min+SANKOFF_EPSILON {
+						candidates[j] = 1
+					}
+				}
+			}
+		}
+		shared, other := 0, 0
+		for k, c := range candidates {
+			if c > 0 {
+				if states[cur.Id()][k] > 0 {
+					shared++
+				} else {
+					other++
+				}
+			}
+		}
+		for k, c := range candidates {
+			states[child.Id()][k] = c
+			if c > 0 {
+				if delayed && shared > 0 && states[cur.Id()][k] == 0 {
+					states[child.Id()][k] = 0
+				}
+				if !delayed && other > 0 && states[cur.Id()][k] > 0 {
+					states[child.Id()][k] = 0
+				}
+			}
+		}
+		if randomResolve && !child.Tip() {
+			randomlyResolveNodeStates(child, states)
+		}
+		sankoffTRAN(child, cur, subcosts, states, costs, delayed, randomResolve)
+	}
+}
+
+// Minimum cost of a subtree given the state of its parent:
+// min_j(transitions[j] + subcosts[j])
+func minTransitionCost(transitions []float64, subcosts AncestralState) float64 {
+	min := math.Inf(1)
+	for j, c := range subcosts {
+		min = math.Min(min, transitions[j]+c)
+	}
+	return min
+}
+
+// Sets to 1 the states having the minimum cost, and to 0 the others
+func minCostStates(costs AncestralState, states AncestralState) {
+	min := math.Inf(1)
+	for _, c := range costs {
+		min = math.Min(min, c)
+	}
+	for k, c := range costs {
+		if c <= min+SANKOFF_EPSILON {
+			states[k] = 1
+		} else {
+			states[k] = 0
+		}
+	}
+}
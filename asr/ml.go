@@ -0,0 +1,70 @@
+package asr
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/likelihood"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Will annotate the tree nodes with ancestral sequences
+// computed using marginal maximum likelihood, under the given
+// substitution model, and taking branch lengths into account.
+//
+// At each internal node, the reconstructed sequence is made of the most
+// probable state at each site (marginal posterior probabilities). Sequences
+// will be located in the comment field of each node at the first index
+// (tips are annotated with their sequence in the alignment).
+//
+// Returns the posterior probabilities of each state, for each node (indexed
+// by node id), and each site: posteriors[node id][site][state], states being
+// in the order of AlphabetCharacters() of the alignment. Posteriors of tips are nil.
+// Also returns the log likelihood of the tree.
+func MarginalAsr(t *tree.Tree, a align.Alignment, m *likelihood.Model) (posteriors [][][]float64, lnl float64, err error) {
+	var l *likelihood.Likelihood
+	var nodes []*tree.Node
+	var alphabet []uint8 = a.AlphabetCharacters()
+	var buffer bytes.Buffer
+
+	if l, err = likelihood.NewLikelihood(t, a, m); err != nil {
+		return
+	}
+	lnl = l.LogLikelihood()
+
+	// Node ids have been set by NewLikelihood
+	nodes = t.Nodes()
+	posteriors = make([][][]float64, len(nodes))
+	for _, n := range nodes {
+		if n.Tip() {
+			seq, ok := a.GetSequence(n.Name())
+			if !ok {
+				err = fmt.Errorf("sequence %s does not exist in the alignment", n.Name())
+				return
+			}
+			n.AddComment(seq)
+			continue
+		}
+		if posteriors[n.Id()], err = l.NodePosteriors(n); err != nil {
+			return
+		}
+		buffer.Reset()
+		for _, post := range posteriors[n.Id()] {
+			buffer.WriteByte(alphabet[MaxPosterior(post)])
+		}
+		n.AddComment(buffer.String())
+	}
+	return
+}
+
+// Returns the index of the state having the maximum posterior
+// probability (the first one in case of ties)
+func MaxPosterior(post []float64) (max int) {
+	for i, p := range post {
+		if p > post[max] {
+			max = i
+		}
+	}
+	return
+}
@@ -15,6 +15,7 @@ const (
 	ALGO_ACCTRAN
 	ALGO_DOWNPASS
 	ALGO_NONE
+	ALGO_ML // Marginal maximum likelihood (see MarginalAsr)
 )
 
 // Will annotate the tree nodes with ancestral sequences
@@ -83,19 +84,7 @@ func parsimonyUPPASS(cur, prev *tree.Node, a align.Alignment, seqs []*AncestralS
 			return
 		}
 		for j, c := range seq {
-			possibilities := make([]uint8, 0)
-			if a.Alphabet() == align.NUCLEOTIDS {
-				possibilities = align.IupacCode[c]
-			} else {
-				if c == align.ALL_AMINO {
-					for k := range charToIndex {
-						possibilities = append(possibilities, k)
-					}
-					possibilities = possibilities[:len(possibilities)-2]
-				} else {
-					possibilities = append(possibilities, c)
-				}
-			}
+			possibilities := charPossibilities(a, c, charToIndex)
 			for _, c2 := range possibilities {
 				charindex, ok := charToIndex[c2]
 				if ok {
@@ -149,6 +138,26 @@ func parsimonyUPPASS(cur, prev *tree.Node, a align.Alignment, seqs []*AncestralS
 	return
 }
 
+// Returns the possible characters corresponding to the given
+// character of the alignment (e.g. IUPAC codes for nucleotides)
+func charPossibilities(a align.Alignment, c uint8, charToIndex map[uint8]int) (possibilities []uint8) {
+	possibilities = make([]uint8, 0)
+	if a.Alphabet() == align.NUCLEOTIDS {
+		possibilities = align.IupacCode[c]
+	} else {
+		if c == align.ALL_AMINO {
+			for k := range charToIndex {
+				if k != '-' && k != '*' {
+					possibilities = append(possibilities, k)
+				}
+			}
+		} else {
+			possibilities = append(possibilities, c)
+		}
+	}
+	return
+}
+
 // Second step of the parsimony computatation: From root to tips
 func parsimonyDOWNPASS(cur, prev *tree.Node, a align.Alignment,
 	seqs []*AncestralSequence, upseqs []*AncestralSequence,
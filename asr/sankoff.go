@@ -0,0 +1,259 @@
+package asr
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/acr"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Will annotate the tree nodes with ancestral sequences
+// computed using Sankoff weighted parsimony.
+//
+// alphabet: possible characters, and costs: cost of a change from character
+// alphabet[i] (ancestor) to character alphabet[j] (descendant) is costs[i][j].
+// The cost matrix may be asymmetric. Characters of the alignment that do not
+// correspond to any character of the alphabet (e.g. gaps, if not in the alphabet)
+// are considered as missing data (all characters possible). Ambiguous nucleotides
+// (IUPAC codes) correspond to all their possible nucleotides.
+//
+// Sequences will be located in the comment field of each node at the first index.
+// Algorithms are the same as in the acr package (see acr.SankoffAcr): ALGO_NONE,
+// ALGO_DOWNPASS, ALGO_DELTRAN, and ALGO_ACCTRAN.
+//
+// Returns the parsimony cost of each site.
+func SankoffAsr(t *tree.Tree, a align.Alignment, alphabet []uint8, costs [][]float64, algo int, randomResolve bool) (sitecosts []float64, err error) {
+	var nodes []*tree.Node = t.Nodes()
+	var subcosts []*AncestralSequence = make([]*AncestralSequence, len(nodes)) // Cost of the subtree of each node
+	var upcosts []*AncestralSequence = make([]*AncestralSequence, len(nodes))  // Cost of the rest of the tree
+	var seqs []*AncestralSequence = make([]*AncestralSequence, len(nodes))     // Reconstructed states
+
+	if err = acr.CheckCostMatrix(len(alphabet), costs); err != nil {
+		return
+	}
+
+	var charToIndex map[uint8]int = make(map[uint8]int)
+	for i, c := range alphabet {
+		charToIndex[c] = i
+	}
+
+	for i, n := range nodes {
+		n.SetId(i)
+		if subcosts[i], err = NewAncestralSequence(a.Length(), len(alphabet)); err != nil {
+			return
+		}
+		if upcosts[i], err = NewAncestralSequence(a.Length(), len(alphabet)); err != nil {
+			return
+		}
+		if seqs[i], err = NewAncestralSequence(a.Length(), len(alphabet)); err != nil {
+			return
+		}
+	}
+
+	if err = sankoffUPPASS(t.Root(), nil, a, subcosts, costs, charToIndex); err != nil {
+		return
+	}
+	sitecosts = make([]float64, a.Length())
+	for j, st := range subcosts[t.Root().Id()].seq {
+		sitecosts[j] = math.Inf(1)
+		for _, c := range st.counts {
+			sitecosts[j] = math.Min(sitecosts[j], c)
+		}
+	}
+
+	switch algo {
+	case ALGO_NONE:
+		for i := range nodes {
+			for j, st := range subcosts[i].seq {
+				minCostStates(st, seqs[i].seq[j])
+			}
+		}
+	case ALGO_DOWNPASS:
+		sankoffDOWNPASS(t.Root(), nil, subcosts, upcosts, seqs, costs, randomResolve)
+	case ALGO_DELTRAN, ALGO_ACCTRAN:
+		for j, st := range subcosts[t.Root().Id()].seq {
+			minCostStates(st, seqs[t.Root().Id()].seq[j])
+		}
+		if randomResolve {
+			randomlyResolveNodeStates(t.Root(), seqs)
+		}
+		sankoffTRAN(t.Root(), nil, subcosts, seqs, costs, algo == ALGO_DELTRAN, randomResolve)
+	default:
+		err = fmt.Errorf("parsimony algorithm %d unknown", algo)
+		return
+	}
+
+	assignSequencesToTree(t, seqs, alphabet)
+	return
+}
+
+// First step of the Sankoff parsimony: From tips to root, computes the minimum
+// cost of the subtree of each node, for each of its states, at each site
+func sankoffUPPASS(cur, prev *tree.Node, a align.Alignment, subcosts []*AncestralSequence, costs [][]float64, charToIndex map[uint8]int) (err error) {
+	if cur.Tip() {
+		seq, ok := a.GetSequenceChar(cur.Name())
+		if !ok {
+			return fmt.Errorf("sequence %s does not exist in the alignment", cur.Name())
+		}
+		for j, c := range seq {
+			st := subcosts[cur.Id()].seq[j]
+			known := false
+			for k := range st.counts {
+				st.counts[k] = math.Inf(1)
+			}
+			for _, c2 := range charPossibilities(a, c, charToIndex) {
+				if k, ok := charToIndex[c2]; ok {
+					st.counts[k] = 0
+					known = true
+				}
+			}
+			// Missing data
+			if !known {
+				for k := range st.counts {
+					st.counts[k] = 0
+				}
+			}
+		}
+		return
+	}
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			if err = sankoffUPPASS(child, cur, a, subcosts, costs, charToIndex); err != nil {
+				return
+			}
+			for j, st := range subcosts[cur.Id()].seq {
+				for i := range st.counts {
+					st.counts[i] += minTransitionCost(costs[i], subcosts[child.Id()].seq[j])
+				}
+			}
+		}
+	}
+	return
+}
+
+// Second step of the Sankoff parsimony: From root to tips, computes the minimum
+// cost of the rest of the tree for each state of each node, and keeps
+// the states having the minimum total cost (see acr.SankoffAcr for randomResolve)
+func sankoffDOWNPASS(cur, prev *tree.Node, subcosts, upcosts, seqs []*AncestralSequence, costs [][]float64, randomResolve bool) {
+	nstates := len(costs)
+	for j, st := range seqs[cur.Id()].seq {
+		total := AncestralState{make([]float64, nstates)}
+		for k := range total.counts {
+			total.counts[k] = subcosts[cur.Id()].seq[j].counts[k] + upcosts[cur.Id()].seq[j].counts[k]
+		}
+		minCostStates(total, st)
+	}
+	if randomResolve && !cur.Tip() {
+		randomlyResolveNodeStates(cur, seqs)
+	}
+
+	for _, child := range cur.Neigh() {
+		if child == prev {
+			continue
+		}
+		for j := range seqs[cur.Id()].seq {
+			// Cost of the tree without the subtree of child, for each state of cur
+			parentcosts := make([]float64, nstates)
+			for i := range parentcosts {
+				if randomResolve && seqs[cur.Id()].seq[j].counts[i] == 0 {
+					parentcosts[i] = math.Inf(1)
+					continue
+				}
+				parentcosts[i] = upcosts[cur.Id()].seq[j].counts[i]
+				for _, child2 := range cur.Neigh() {
+					if child2 != prev && child2 != child {
+						parentcosts[i] += minTransitionCost(costs[i], subcosts[child2.Id()].seq[j])
+					}
+				}
+			}
+			up := upcosts[child.Id()].seq[j].counts
+			for k := range up {
+				up[k] = math.Inf(1)
+				for i, c := range parentcosts {
+					up[k] = math.Min(up[k], c+costs[i][k])
+				}
+			}
+		}
+		sankoffDOWNPASS(child, cur, subcosts, upcosts, seqs, costs, randomResolve)
+	}
+}
+
+// Second step of the Sankoff parsimony (instead of DOWNPASS) for resolving ambiguities
+// (see acr.SankoffAcr)
+func sankoffTRAN(cur, prev *tree.Node, subcosts, seqs []*AncestralSequence, costs [][]float64, delayed bool, randomResolve bool) {
+	if cur.Tip() {
+		return
+	}
+	for _, child := range cur.Neigh() {
+		if child == prev {
+			continue
+		}
+		for j, ances := range seqs[cur.Id()].seq {
+			sub := subcosts[child.Id()].seq[j]
+			candidates := make([]float64, len(costs))
+			for i, c := range ances.counts {
+				if c > 0 {
+					min := minTransitionCost(costs[i], sub)
+					for k, sc := range sub.counts {
+						if costs[i][k]+sc <= min+acr.SANKOFF_EPSILON {
+							candidates[k] = 1
+						}
+					}
+				}
+			}
+			shared, other := 0, 0
+			for k, c := range candidates {
+				if c > 0 {
+					if ances.counts[k] > 0 {
+						shared++
+					} else {
+						other++
+					}
+				}
+			}
+			childstates := seqs[child.Id()].seq[j].counts
+			for k, c := range candidates {
+				childstates[k] = c
+				if c > 0 {
+					if delayed && shared > 0 && ances.counts[k] == 0 {
+						childstates[k] = 0
+					}
+					if !delayed && other > 0 && ances.counts[k] > 0 {
+						childstates[k] = 0
+					}
+				}
+			}
+		}
+		if randomResolve && !child.Tip() {
+			randomlyResolveNodeStates(child, seqs)
+		}
+		sankoffTRAN(child, cur, subcosts, seqs, costs, delayed, randomResolve)
+	}
+}
+
+// Minimum cost of a subtree given the state of its parent:
+// min_k(transitions[k] + subcosts[k])
+func minTransitionCost(transitions []float64, subcosts AncestralState) float64 {
+	min := math.Inf(1)
+	for k, c := range subcosts.counts {
+		min = math.Min(min, transitions[k]+c)
+	}
+	return min
+}
+
+// Sets to 1 the states having the minimum cost, and to 0 the others
+func minCostStates(costs AncestralState, states AncestralState) {
+	min := math.Inf(1)
+	for _, c := range costs.counts {
+		min = math.Min(min, c)
+	}
+	for k, c := range costs.counts {
+		if c <= min+acr.SANKOFF_EPSILON {
+			states.counts[k] = 1
+		} else {
+			states.counts[k] = 0
+		}
+	}
+}
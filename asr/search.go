@@ -0,0 +1,235 @@
+package asr
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Computes the parsimony score of the tree given the alignment:
+// the sum over all sites of the number of steps computed by the
+// UP-PASS of ParsimonyAsr.
+//
+// The topology of the tree is not modified, but the ids of its nodes are
+// reset (see tree.Node.SetId).
+func ParsimonyScore(t *tree.Tree, a align.Alignment) (score int, err error) {
+	var ps *parsimonyScorer
+	if ps, err = newParsimonyScorer(t, a); err != nil {
+		return
+	}
+	score = ps.score(t)
+	return
+}
+
+// Searches the most parsimonious tree by hill climbing, starting from the given tree.
+//
+// At each iteration, all rearrangements given by the Rearranger (e.g. NNI or SPR)
+// are evaluated, and the one giving the best improvement of the parsimony score is
+// applied. The search stops when no rearrangement improves the score.
+//
+// The input tree is modified in place, and the returned score is the parsimony
+// score of the final tree. Branch lengths are not meaningful after the search.
+func ParsimonySearch(t *tree.Tree, a align.Alignment, r tree.Rearranger) (score int, err error) {
+	var ps *parsimonyScorer
+	var best tree.Rearrangement
+	var bestscore int
+
+	if ps, err = newParsimonyScorer(t, a); err != nil {
+		return
+	}
+	score = ps.score(t)
+
+	for {
+		best = nil
+		bestscore = score
+		r.Rearrange(t, func(re tree.Rearrangement) bool {
+			if err = re.Apply(); err != nil {
+				return false
+			}
+			if s := ps.score(t); s < bestscore {
+				bestscore = s
+				best = re
+			}
+			if err = re.Undo(); err != nil {
+				return false
+			}
+			return true
+		})
+		if err != nil {
+			return
+		}
+		if best == nil {
+			break
+		}
+		if err = best.Apply(); err != nil {
+			return
+		}
+		score = bestscore
+	}
+
+	err = t.ReinitIndexes()
+	return
+}
+
+// Computes parsimony scores using states encoded as bits
+// (one bit per character of the alphabet used by ParsimonyAsr)
+// and identical sites grouped into weighted patterns.
+type parsimonyScorer struct {
+	tipstates map[string][]uint32 // tip name => State of each pattern
+	weights   []int               // Number of sites of each pattern
+	allstates uint32              // All characters of the alphabet
+	nodes     [][]uint32          // Working states of each node (indexed by node id)
+}
+
+func newParsimonyScorer(t *tree.Tree, a align.Alignment) (ps *parsimonyScorer, err error) {
+	var alphabet []uint8 = a.AlphabetCharacters()
+	var charToIndex map[uint8]int = make(map[uint8]int)
+	var tips []*tree.Node = t.Tips()
+	var nodes []*tree.Node = t.Nodes()
+	var seqs [][]uint32 = make([][]uint32, len(tips))
+	var patterns map[string]int = make(map[string]int)
+	var sb strings.Builder
+
+	alphabet = append(alphabet, '-')
+	alphabet = append(alphabet, '*')
+	for i, c := range alphabet {
+		charToIndex[c] = i
+	}
+	if len(alphabet) > 32 {
+		err = fmt.Errorf("alphabet too large for parsimony computation: %d characters", len(alphabet))
+		return
+	}
+
+	ps = &parsimonyScorer{
+		tipstates: make(map[string][]uint32),
+		weights:   make([]int, 0),
+		allstates: uint32(1)<<uint(len(alphabet)) - 1,
+		nodes:     make([][]uint32, len(nodes)),
+	}
+
+	for i, n := range tips {
+		seq, ok := a.GetSequenceChar(n.Name())
+		if !ok {
+			err = fmt.Errorf("sequence %s does not exist in the alignment", n.Name())
+			return
+		}
+		seqs[i] = make([]uint32, a.Length())
+		for j, c := range seq {
+			for _, c2 := range charPossibilities(a, c, charToIndex) {
+				if index, ok := charToIndex[c2]; ok {
+					seqs[i][j] |= 1 << uint(index)
+				}
+			}
+		}
+	}
+
+	// Identical sites are grouped
+	for j := 0; j < a.Length(); j++ {
+		sb.Reset()
+		for i := range tips {
+			fmt.Fprintf(&sb, "%d,", seqs[i][j])
+		}
+		if p, ok := patterns[sb.String()]; ok {
+			ps.weights[p]++
+			continue
+		}
+		patterns[sb.String()] = len(ps.weights)
+		ps.weights = append(ps.weights, 1)
+		for i, n := range tips {
+			ps.tipstates[n.Name()] = append(ps.tipstates[n.Name()], seqs[i][j])
+		}
+	}
+
+	for i, n := range nodes {
+		n.SetId(i)
+		ps.nodes[i] = make([]uint32, len(ps.weights))
+	}
+	return
+}
+
+// Computes the parsimony score of the tree. Node ids must
+// correspond to the ones given by newParsimonyScorer, which
+// is the case after SPR, TBR or NNI rearrangements.
+func (ps *parsimonyScorer) score(t *tree.Tree) (score int) {
+	var counts [32]int
+	t.PostOrder(func(cur, prev *tree.Node, e *tree.Edge) bool {
+		states := ps.nodes[cur.Id()]
+		if cur.Tip() {
+			copy(states, ps.tipstates[cur.Name()])
+			return true
+		}
+		if c1, c2, ok := binaryChildren(cur, prev); ok {
+			// Binary node: Fitch algorithm
+			s1, s2 := ps.nodes[c1.Id()], ps.nodes[c2.Id()]
+			for j := range states {
+				if inter := s1[j] & s2[j]; inter != 0 {
+					states[j] = inter
+				} else if union := s1[j] | s2[j]; union != 0 {
+					states[j] = union
+					score += ps.weights[j]
+				} else {
+					states[j] = ps.allstates
+					score += 2 * ps.weights[j]
+				}
+			}
+			return true
+		}
+		for j := range states {
+			// For each character, we count the number of children
+			// having it, and keep the characters shared by the max
+			// number of children (the others need a step)
+			nchild, max := 0, 0
+			for k := range counts {
+				counts[k] = 0
+			}
+			for _, child := range cur.Neigh() {
+				if child != prev {
+					nchild++
+					for s := ps.nodes[child.Id()][j]; s != 0; s &= s - 1 {
+						counts[bits.TrailingZeros32(s)]++
+					}
+				}
+			}
+			for _, c := range counts {
+				if c > max {
+					max = c
+				}
+			}
+			states[j] = 0
+			if max == 0 {
+				states[j] = ps.allstates
+			} else {
+				for k, c := range counts {
+					if c == max {
+						states[j] |= 1 << uint(k)
+					}
+				}
+			}
+			score += (nchild - max) * ps.weights[j]
+		}
+		return true
+	})
+	return
+}
+
+// Returns the two children of the node if it has exactly
+// two children (ok=false otherwise)
+func binaryChildren(cur, prev *tree.Node) (c1, c2 *tree.Node, ok bool) {
+	if (prev == nil && cur.Nneigh() != 2) || (prev != nil && cur.Nneigh() != 3) {
+		return
+	}
+	for _, child := range cur.Neigh() {
+		if child != prev {
+			if c1 == nil {
+				c1 = child
+			} else {
+				c2 = child
+			}
+		}
+	}
+	ok = true
+	return
+}
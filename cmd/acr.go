@@ -8,6 +8,7 @@ import (
 	goio "io"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/evolbioinfo/gotree/acr"
@@ -19,14 +20,16 @@ import (
 var acrstates string
 var acrrandomresolve bool // Resolve ambiguities randomly in the downpass/deltran/acctran algo
 var outstepfile string
+var outprobasfile string
+var acrcostmatrix string
 
 // acrCmd represents the acr command
 var acrCmd = &cobra.Command{
 	Use:   "acr",
-	Short: "Reconstructs most parsimonious ancestral characters",
-	Long: `Reconstructs most parsimonious ancestral characters.
+	Short: "Reconstructs ancestral characters",
+	Long: `Reconstructs ancestral characters, by parsimony or maximum likelihood.
 
-Depending on the chosen algorithm, it will run:
+For parsimony algorithms (acctran, deltran, downpass, none), it will run:
 1) UP-PASS and
 2) Either
    a) DOWN-PASS or
@@ -39,9 +42,37 @@ Should work on multifurcated trees.
 If --random-resolve is given then, during the last pass, each time 
 a node with several possible states still exists, one state is chosen 
 randomly before going deeper in the tree.
+
+If --cost-matrix is given, then Sankoff weighted parsimony is used instead,
+with the given (possibly asymmetric) costs of state changes, and the
+parsimony cost of the tree is written in the --out-steps file. The cost
+matrix file has a header line with the states, and one line per ancestral
+state, giving the cost of a change to each descendant state (tab, space, or
+comma separated). For example, to penalize reversals from B to A:
+   A  B
+A  0  1
+B  3  0
+With --algo downpass, each node gets all the states it has in at least one
+most parsimonious reconstruction. With --algo deltran and acctran, from the
+root to the tips, each node gets its optimal states given the states of its
+parent, the state of the parent being preferred (deltran) or avoided
+(acctran) in case of ties.
+
+For likelihood algorithms (mk, f81), branch lengths are taken into account,
+under a Mk model (all states have the same equilibrium frequency) or an
+F81-like model (equilibrium frequencies are the frequencies of the states
+at tips). Nodes are annotated with their state in the joint reconstruction
+(most likely combination of ancestral states), the log likelihood is written
+in the --out-steps file, and if --out-probas is given, the marginal posterior
+probabilities of each state at each node are written in this file
+(tab separated):
+tree  node  state1  state2 ...
+Nodes are identified by their name, or by their id if they have no name
+(as in the --out-states file).
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var algo int
+		var ml bool
 		var statemap map[string]string
 		var tipstates map[string]string
 		var resfile *os.File
@@ -50,6 +81,11 @@ randomly before going deeper in the tree.
 		var nsteps int
 		var f *os.File
 		var outstepsf *os.File
+		var probasf *os.File
+		var mlres *acr.LikelihoodAcrResult
+		var costalphabet []string
+		var costs [][]float64
+		var cost float64
 
 		switch strings.ToLower(parsimonyAlgo) {
 		case "acctran":
@@ -60,10 +96,26 @@ randomly before going deeper in the tree.
 			algo = acr.ALGO_DOWNPASS
 		case "none":
 			algo = acr.ALGO_NONE
+		case "mk":
+			algo, ml = acr.MODEL_MK, true
+		case "f81":
+			algo, ml = acr.MODEL_F81, true
 		default:
-			io.LogError(fmt.Errorf("Unkown parsimony algorithm: %s", parsimonyAlgo))
+			err = fmt.Errorf("Unknown acr algorithm: %s", parsimonyAlgo)
+			io.LogError(err)
 			return
 		}
+		if acrcostmatrix != "none" {
+			if ml {
+				err = fmt.Errorf("--cost-matrix is only available with parsimony algorithms")
+				io.LogError(err)
+				return
+			}
+			if costalphabet, costs, err = parseCostMatrix(acrcostmatrix); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
 		// Reading tip state in an input file
 		if tipstates, err = parseTipStates(acrstates); err != nil {
 			io.LogError(err)
@@ -96,14 +148,46 @@ randomly before going deeper in the tree.
 			}
 			defer closeWriteFile(resfile, outresfile)
 		}
+		if outprobasfile != "none" {
+			if !ml {
+				err = fmt.Errorf("--out-probas is only available with likelihood algorithms (mk, f81)")
+				io.LogError(err)
+				return
+			}
+			if probasf, err = openWriteFile(outprobasfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(probasf, outprobasfile)
+		}
 		for t := range treechan {
-			statemap, nsteps, err = acr.ParsimonyAcr(t.Tree, tipstates, algo, acrrandomresolve)
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if ml {
+				mlres, statemap, err = acr.LikelihoodAcr(t.Tree, tipstates, algo)
+			} else if costs != nil {
+				statemap, cost, err = acr.SankoffAcr(t.Tree, tipstates, costalphabet, costs, algo, acrrandomresolve)
+			} else {
+				statemap, nsteps, err = acr.ParsimonyAcr(t.Tree, tipstates, algo, acrrandomresolve)
+			}
 			if err != nil {
 				io.LogError(err)
 				return
 			}
 			f.WriteString(t.Tree.Newick() + "\n")
-			fmt.Fprintf(outstepsf, "steps %d\n", nsteps)
+			if ml {
+				fmt.Fprintf(outstepsf, "lnl %f\n", mlres.LogLikelihood)
+				if probasf != nil {
+					writeAcrProbas(probasf, t.Id, t.Tree, mlres)
+				}
+			} else if costs != nil {
+				fmt.Fprintf(outstepsf, "cost %g\n", cost)
+			} else {
+				fmt.Fprintf(outstepsf, "steps %d\n", nsteps)
+			}
 			if outresfile != "none" {
 				for k, v := range statemap {
 					resfile.WriteString(fmt.Sprintf("%s,%s\n", k, v))
@@ -120,11 +204,32 @@ func init() {
 	acrCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
 	acrCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
 	acrCmd.PersistentFlags().StringVar(&outresfile, "out-states", "none", "Output mapping file between node names and states")
-	acrCmd.PersistentFlags().StringVar(&outstepfile, "out-steps", "stdout", "Output file with number of parsimony steps")
-	acrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass")
+	acrCmd.PersistentFlags().StringVar(&outstepfile, "out-steps", "stdout", "Output file with number of parsimony steps (or log likelihood)")
+	acrCmd.PersistentFlags().StringVar(&outprobasfile, "out-probas", "none", "Output file with marginal posterior probabilities of states at each node (mk and f81 only)")
+	acrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "ACR algorithm: acctran, deltran, downpass (parsimony), mk, or f81 (likelihood)")
+	acrCmd.PersistentFlags().StringVar(&acrcostmatrix, "cost-matrix", "none", "Cost matrix file of state changes, for Sankoff parsimony")
 	acrCmd.PersistentFlags().BoolVar(&acrrandomresolve, "random-resolve", false, "Random resolve states when several possibilities in: acctran, deltran, or downpass")
 }
 
+// Writes the marginal posterior probabilities of each state, for each node of the tree
+// (and the header line before the first tree)
+func writeAcrProbas(f *os.File, treeid int, t *tree.Tree, res *acr.LikelihoodAcrResult) {
+	if treeid == 0 {
+		f.WriteString("tree\tnode\t" + strings.Join(res.Alphabet, "\t") + "\n")
+	}
+	for _, n := range t.Nodes() {
+		id := fmt.Sprintf("%d", n.Id())
+		if n.Name() != "" {
+			id = n.Name()
+		}
+		fmt.Fprintf(f, "%d\t%s", treeid, id)
+		for _, p := range res.Posteriors[n.Id()] {
+			fmt.Fprintf(f, "\t%f", p)
+		}
+		f.WriteString("\n")
+	}
+}
+
 func parseTipStates(file string) (states map[string]string, err error) {
 	var f *os.File
 	var r *bufio.Reader
@@ -162,3 +267,67 @@ func parseTipStates(file string) (states map[string]string, err error) {
 	}
 	return
 }
+
+// Parses a cost matrix file: first line contains the states,
+// and following lines the costs of changes from each ancestral
+// state (first column) to each descendant state.
+// Columns are separated by tabs, spaces or commas, and
+// empty lines as well as lines starting with # are ignored.
+func parseCostMatrix(file string) (states []string, costs [][]float64, err error) {
+	var f *os.File
+	var r *bufio.Reader
+	var indices map[string]int = make(map[string]int)
+	var seen map[string]bool = make(map[string]bool)
+
+	if file == "stdin" || file == "-" {
+		f = os.Stdin
+	} else {
+		if f, err = os.Open(file); err != nil {
+			return
+		}
+		defer f.Close()
+	}
+	r = bufio.NewReader(f)
+
+	re := regexp.MustCompile("[\\s,]+")
+	l, e := Readln(r)
+	for ; e == nil; l, e = Readln(r) {
+		l = strings.Trim(l, " \t,")
+		if l == "" || strings.HasPrefix(l, "#") {
+			continue
+		}
+		cols := re.Split(l, -1)
+		if states == nil {
+			states = cols
+			for i, s := range states {
+				if _, ok := indices[s]; ok {
+					err = fmt.Errorf("Bad format for cost matrix: state %s is duplicated", s)
+					return
+				}
+				indices[s] = i
+			}
+			costs = make([][]float64, len(states))
+			continue
+		}
+		if len(cols) != len(states)+1 {
+			err = fmt.Errorf("Bad format for cost matrix: Wrong number of columns for state %s", cols[0])
+			return
+		}
+		i, ok := indices[cols[0]]
+		if !ok || seen[cols[0]] {
+			err = fmt.Errorf("Bad format for cost matrix: Unexpected row %s", cols[0])
+			return
+		}
+		seen[cols[0]] = true
+		costs[i] = make([]float64, len(states))
+		for j, c := range cols[1:] {
+			if costs[i][j], err = strconv.ParseFloat(c, 64); err != nil {
+				return
+			}
+		}
+	}
+	if len(seen) != len(states) || len(states) == 0 {
+		err = fmt.Errorf("Bad format for cost matrix: %d rows for %d states", len(seen), len(states))
+	}
+	return
+}
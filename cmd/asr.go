@@ -1,18 +1,15 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
 	goio "io"
 	"os"
 	"strings"
 
 	"github.com/evolbioinfo/goalign/align"
-	"github.com/evolbioinfo/goalign/io/fasta"
-	"github.com/evolbioinfo/goalign/io/phylip"
 	"github.com/evolbioinfo/gotree/asr"
 	"github.com/evolbioinfo/gotree/io"
-	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/likelihood"
 	"github.com/evolbioinfo/gotree/tree"
 	"github.com/spf13/cobra"
 )
@@ -22,12 +19,14 @@ var asrphylip bool
 var asrinputstrict bool
 var asrrandomresolve bool // Resolve ambiguities randomly in the downpass/deltran/acctran algo
 var outlogfile string
+var asrposteriorfile string
+var asrcostmatrix string
 
 // asrCmd represents the asr command
 var asrCmd = &cobra.Command{
 	Use:   "asr",
-	Short: "Reconstructs most parsimonious ancestral sequences",
-	Long: `Reconstructs most parsimonious ancestral sequences.
+	Short: "Reconstructs most parsimonious or most likely ancestral sequences",
+	Long: `Reconstructs most parsimonious or most likely ancestral sequences.
 
 Depending on the chosen algorithm, it will run:
 1) UP-PASS and
@@ -42,17 +41,41 @@ Should work on multifurcated trees
 If --random-resolve is given then, during the last pass, each time 
 a node with several possible states still exists, one state is chosen 
 randomly before going deeper in the tree.
+
+If --cost-matrix is given, then Sankoff weighted parsimony is used instead,
+with the given (possibly asymmetric) costs of character changes (see gotree
+acr for the format of the cost matrix and the algorithms), and the parsimony
+cost of each site is written in the log file. Alignment characters that are
+not in the cost matrix (e.g. gaps) are considered as missing data.
+
+If --algo ml is given, then a marginal maximum likelihood reconstruction
+is done, under the given substitution model (see gotree compute likelihood
+for model options), taking branch lengths into account (they must all be
+defined). The sequence of each internal node is made of the most probable
+state at each site, and the log likelihood of the tree is written in the
+log file. If --posteriors is given, then the marginal posterior probability
+of each state, at each site of each internal node, is written in this file
+(tab separated):
+tree  node  site  state  A  C  G  T
+where node is the node name, or its index if it has no name, and state the
+most probable state.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var align align.Alignment
-		var fi goio.Closer
-		var r *bufio.Reader
 		var algo int
 		var treefile goio.Closer
 		var treechan <-chan tree.Trees
 		var f *os.File
 		var logf *os.File
 		var nsteps []int
+		var model *likelihood.Model
+		var posteriors [][][]float64
+		var postf *os.File
+		var lnl float64
+		var coststates []string
+		var costalphabet []uint8
+		var costs [][]float64
+		var sitecosts []float64
 
 		switch strings.ToLower(parsimonyAlgo) {
 		case "acctran":
@@ -63,32 +86,58 @@ randomly before going deeper in the tree.
 			algo = asr.ALGO_DOWNPASS
 		case "none":
 			algo = asr.ALGO_NONE
+		case "ml":
+			algo = asr.ALGO_ML
 		default:
 			err = fmt.Errorf("unkown parsimony algorithm: %s", parsimonyAlgo)
 			io.LogError(err)
 			return
 		}
 
+		if asrcostmatrix != "none" {
+			if algo == asr.ALGO_ML {
+				err = fmt.Errorf("--cost-matrix is only available with parsimony algorithms")
+				io.LogError(err)
+				return
+			}
+			if coststates, costs, err = parseCostMatrix(asrcostmatrix); err != nil {
+				io.LogError(err)
+				return
+			}
+			for _, s := range coststates {
+				if len(s) != 1 {
+					err = fmt.Errorf("states of the cost matrix must be single characters: %s", s)
+					io.LogError(err)
+					return
+				}
+				costalphabet = append(costalphabet, s[0])
+			}
+		}
+
 		// Reading the alignment
-		fi, r, err = utils.GetReader(asralign)
-		if err != nil {
+		if align, err = readAlignment(asralign, asrphylip, asrinputstrict); err != nil {
 			io.LogError(err)
 			return
 		}
-		if asrphylip {
-			align, err = phylip.NewParser(r, asrinputstrict).Parse()
-			if err != nil {
+
+		if algo == asr.ALGO_ML {
+			if model, err = newLikelihoodModel(align); err != nil {
 				io.LogError(err)
 				return
 			}
-		} else {
-			align, err = fasta.NewParser(r).Parse()
-			if err != nil {
-				io.LogError(err)
-				return
+			if asrposteriorfile != "none" {
+				if postf, err = openWriteFile(asrposteriorfile); err != nil {
+					io.LogError(err)
+					return
+				}
+				defer closeWriteFile(postf, asrposteriorfile)
+				postf.WriteString("tree\tnode\tsite\tstate")
+				for _, c := range align.AlphabetCharacters() {
+					fmt.Fprintf(postf, "\t%c", c)
+				}
+				postf.WriteString("\n")
 			}
 		}
-		fi.Close()
 
 		// Reading the trees
 		if treefile, treechan, err = readTrees(intreefile); err != nil {
@@ -110,6 +159,36 @@ randomly before going deeper in the tree.
 		defer closeWriteFile(logf, outlogfile)
 
 		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if algo == asr.ALGO_ML {
+				if posteriors, lnl, err = asr.MarginalAsr(t.Tree, align, model); err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(logf, "lnl %f\n", lnl)
+				if postf != nil {
+					writePosteriors(postf, t.Id, t.Tree, posteriors, align.AlphabetCharacters())
+				}
+				f.WriteString(t.Tree.Newick() + "\n")
+				continue
+			}
+			if costs != nil {
+				if sitecosts, err = asr.SankoffAsr(t.Tree, align, costalphabet, costs, algo, asrrandomresolve); err != nil {
+					io.LogError(err)
+					return
+				}
+				fmt.Fprintf(logf, "cost")
+				for _, c := range sitecosts {
+					fmt.Fprintf(logf, " %g", c)
+				}
+				fmt.Fprintf(logf, "\n")
+				f.WriteString(t.Tree.Newick() + "\n")
+				continue
+			}
 			nsteps, err = asr.ParsimonyAsr(t.Tree, align, algo, asrrandomresolve)
 			if err != nil {
 				io.LogError(err)
@@ -134,6 +213,30 @@ func init() {
 	asrCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
 	asrCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
 	asrCmd.PersistentFlags().StringVar(&outlogfile, "log", "stdout", "Output log file")
-	asrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass")
+	asrCmd.PersistentFlags().StringVar(&parsimonyAlgo, "algo", "acctran", "Parsimony algorithm for resolving ambiguities: acctran, deltran, or downpass, or ml for marginal maximum likelihood")
 	asrCmd.PersistentFlags().BoolVar(&asrrandomresolve, "random-resolve", false, "Random resolve states when several possibilities in: acctran, deltran, or downpass")
+	asrCmd.PersistentFlags().StringVar(&asrcostmatrix, "cost-matrix", "none", "Cost matrix file of character changes, for Sankoff parsimony")
+	asrCmd.PersistentFlags().StringVar(&asrposteriorfile, "posteriors", "none", "Output file of marginal posterior probabilities of states at internal nodes (only with --algo ml)")
+	addLikelihoodModelFlags(asrCmd)
+}
+
+// Writes the posterior probabilities of each state, at each site
+// of each internal node of the tree, as given by asr.MarginalAsr
+func writePosteriors(f *os.File, treeid int, t *tree.Tree, posteriors [][][]float64, alphabet []uint8) {
+	for _, n := range t.Nodes() {
+		if n.Tip() {
+			continue
+		}
+		id := fmt.Sprintf("%d", n.Id())
+		if n.Name() != "" {
+			id = n.Name()
+		}
+		for site, post := range posteriors[n.Id()] {
+			fmt.Fprintf(f, "%d\t%s\t%d\t%c", treeid, id, site, alphabet[asr.MaxPosterior(post)])
+			for _, p := range post {
+				fmt.Fprintf(f, "\t%f", p)
+			}
+			f.WriteString("\n")
+		}
+	}
 }
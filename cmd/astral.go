@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var astraloutfile string
+
+// astralCmd represents the supertree astral command
+var astralCmd = &cobra.Command{
+	Use:   "astral",
+	Short: "Estimates a species tree maximizing the quartet agreement with gene trees",
+	Long: `Estimates a species tree maximizing the quartet agreement with gene trees.
+
+As in ASTRAL (Mirarab et al., 2014), the species tree maximizes the number of
+quartets of the input gene trees (-i) that it displays. The search space is
+constrained to the species trees whose bipartitions are all present in the gene
+trees, and the optimal tree of this space is found by dynamic programming.
+
+Gene trees are considered unrooted, and may be multifurcated and have missing
+taxa.
+
+The output species tree is unrooted, without branch lengths. The support of each
+internal branch is its local quartet support: among the gene tree quartets made
+of one taxon of each of the four subtrees around the branch, the proportion that
+agree with the species tree. The normalized quartet score of the species tree
+(proportion of resolved gene tree quartets that it displays) is written on
+stderr.
+
+Quartet scores are computed over --threads threads. Running time grows roughly
+as the cube of the size of the input (number of gene trees and of taxa): it is
+suited to a few hundred gene trees of a few hundred taxa, not to larger datasets.
+
+Example:
+gotree supertree astral -i genetrees.nw -o species.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var species *tree.Tree
+		var score float64
+		var f *os.File
+
+		maxcpus := runtime.NumCPU()
+		if rootCpus > maxcpus {
+			rootCpus = maxcpus
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if species, score, err = tree.QuartetSpeciesTree(treechan, rootCpus); err != nil {
+			io.LogError(err)
+			return
+		}
+		io.LogInfo(fmt.Sprintf("Normalized quartet score: %f", score))
+
+		if f, err = openWriteFile(astraloutfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, astraloutfile)
+		f.WriteString(species.Newick() + "\n")
+		return
+	},
+}
+
+func init() {
+	supertreeCmd.AddCommand(astralCmd)
+	astralCmd.Flags().StringVarP(&astraloutfile, "output", "o", "stdout", "Output species tree file")
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var bdtreeNbTips int
+var bdtreeCrownAge float64
+var bdtreeLambda, bdtreeMu, bdtreeRho float64
+
+func birthDeathTree(nbtrees int, nbtips int, crownage, lambda, mu, rho float64, output string) error {
+	var f *os.File
+	var err error
+	var t *tree.Tree
+
+	if output != "stdout" && output != "-" {
+		f, err = os.Create(output)
+		defer f.Close()
+	} else {
+		f = os.Stdout
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < nbtrees; i++ {
+		t, err = tree.RandomBirthDeathTree(nbtips, crownage, lambda, mu, rho)
+		if err != nil {
+			return err
+		}
+		f.WriteString(t.Newick() + "\n")
+	}
+	return nil
+}
+
+// bdtreeCmd represents the bdtree command
+var bdtreeCmd = &cobra.Command{
+	Use:   "bdtree",
+	Short: "Generates a random birth-death tree",
+	Long: `Generates a random birth-death tree.
+
+Trees are simulated under a constant rate birth-death process, with speciation
+rate --lambda, extinction rate --mu, and sampling fraction --rho of the extant
+lineages. Output trees are reconstructed trees: extinct and unsampled lineages
+are pruned. They are rooted, and branch lengths are in units of time.
+
+Trees are conditioned on:
+- their number of tips (-l) if --crown-age is not given: the crown age follows its
+  distribution under a uniform prior;
+- their crown age (--crown-age), i.e. the age of the root, if -l is not given;
+- both, if both are given.
+
+Example:
+
+gotree generate bdtree -l 100 --lambda 2 --mu 1 --rho 0.5 -n 10 > trees.nw
+gotree generate bdtree --crown-age 5 --lambda 1 --mu 0.5 > tree.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		nbtips := bdtreeNbTips
+		if cmd.Flags().Changed("crown-age") && !cmd.Flags().Changed("nbtips") {
+			nbtips = 0
+		}
+		if err = birthDeathTree(generateNbTrees, nbtips, bdtreeCrownAge, bdtreeLambda, bdtreeMu, bdtreeRho, generateOutputfile); err != nil {
+			io.LogError(err)
+		}
+		return
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(bdtreeCmd)
+	bdtreeCmd.PersistentFlags().IntVarP(&bdtreeNbTips, "nbtips", "l", 10, "Number of tips/leaves of the tree to generate")
+	bdtreeCmd.PersistentFlags().Float64Var(&bdtreeCrownAge, "crown-age", 0, "Crown age (age of the root) of the tree to generate")
+	bdtreeCmd.PersistentFlags().Float64Var(&bdtreeLambda, "lambda", 1, "Speciation rate")
+	bdtreeCmd.PersistentFlags().Float64Var(&bdtreeMu, "mu", 0, "Extinction rate")
+	bdtreeCmd.PersistentFlags().Float64Var(&bdtreeRho, "rho", 1, "Sampling fraction of extant lineages")
+}
@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/likelihood"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var optimizephylip bool
+var optimizeinputstrict bool
+var optimizemaxiter int
+var optimizeepsilon float64
+var optimizelogfile string
+
+// brlenOptimizeCmd represents the brlen optimize command
+var brlenOptimizeCmd = &cobra.Command{
+	Use:   "optimize",
+	Short: "Optimizes branch lengths of input trees by maximum likelihood",
+	Long: `Optimizes branch lengths of input trees by maximum likelihood.
+
+Given an alignment and a substitution model (see gotree compute likelihood
+for model options), all branch lengths of the input trees are optimized
+(Brent's method for each branch in turn). Rounds of optimization over all
+branches are iterated until the log likelihood improves by less than
+--epsilon, or until --max-iter rounds.
+
+The topology of the trees is not modified. Undefined branch lengths are
+initialized to 0.1.
+
+If --log is given, the initial and final log likelihoods of each tree are
+written in this file (tab separated):
+tree  initial  lnl
+
+Example, to re-fit branch lengths after removing tips:
+gotree prune -i tree.nw -f tips.txt | gotree brlen optimize -a align.fa --model gtr -o pruned.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var al align.Alignment
+		var model *likelihood.Model
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var f, logf *os.File
+		var l *likelihood.Likelihood
+		var initlnl, lnl float64
+
+		if al, err = readAlignment(inalignfile, optimizephylip, optimizeinputstrict); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if model, err = newLikelihoodModel(al); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if optimizelogfile != "none" {
+			if logf, err = openWriteFile(optimizelogfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(logf, optimizelogfile)
+			logf.WriteString("tree\tinitial\tlnl\n")
+		}
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			likelihood.InitBranchLengths(t.Tree)
+			if l, err = likelihood.NewLikelihood(t.Tree, al, model); err != nil {
+				io.LogError(err)
+				return
+			}
+			initlnl = l.LogLikelihood()
+			lnl = l.OptimizeBranchLengths(optimizemaxiter, optimizeepsilon)
+			if logf != nil {
+				fmt.Fprintf(logf, "%d\t%f\t%f\n", t.Id, initlnl, lnl)
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+		}
+		return
+	},
+}
+
+func init() {
+	brlenCmd.AddCommand(brlenOptimizeCmd)
+	brlenOptimizeCmd.PersistentFlags().StringVarP(&inalignfile, "align", "a", "stdin", "Alignment input file")
+	brlenOptimizeCmd.PersistentFlags().BoolVarP(&optimizephylip, "phylip", "p", false, "Alignment is in phylip? default : false (Fasta)")
+	brlenOptimizeCmd.PersistentFlags().BoolVar(&optimizeinputstrict, "input-strict", false, "Strict phylip input format (only used with -p)")
+	brlenOptimizeCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Optimized tree output file")
+	brlenOptimizeCmd.PersistentFlags().IntVar(&optimizemaxiter, "max-iter", 100, "Maximum number of optimization rounds over all branches")
+	brlenOptimizeCmd.PersistentFlags().Float64Var(&optimizeepsilon, "epsilon", 1e-4, "Stop when the log likelihood improves by less than epsilon after a round")
+	brlenOptimizeCmd.PersistentFlags().StringVar(&optimizelogfile, "log", "none", "Log likelihood output file")
+	addLikelihoodModelFlags(brlenOptimizeCmd)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var coaltreeNbTips int
+var coaltreeDateFile, coaltreeDemeFile string
+var coaltreePopSize, coaltreeGrowth, coaltreeMigration float64
+var coaltreeNbDemes int
+
+// Builds the samples of the genealogy, from the date file and the deme file
+// if given. Sampling ages are computed from the most recent sampling date.
+func coalescentSamples(nbtips int, datefile, demefile string, ndemes int) (samples []tree.CoalescentSample, nbdemes int, err error) {
+	var dates map[string]float64
+	var demes map[string]string
+
+	if datefile != "none" {
+		if dates, err = readDateFile(datefile); err != nil {
+			return
+		}
+		names := make([]string, 0, len(dates))
+		maxdate := 0.0
+		for name, date := range dates {
+			if len(names) == 0 || date > maxdate {
+				maxdate = date
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		samples = make([]tree.CoalescentSample, len(names))
+		for i, name := range names {
+			samples[i] = tree.CoalescentSample{Name: name, Age: maxdate - dates[name]}
+		}
+	} else {
+		samples = make([]tree.CoalescentSample, nbtips)
+		for i := range samples {
+			samples[i] = tree.CoalescentSample{Name: fmt.Sprintf("Tip%d", i)}
+		}
+	}
+
+	nbdemes = ndemes
+	if demefile != "none" {
+		if demes, err = readMapFile(demefile, false); err != nil {
+			return
+		}
+		names := make([]string, 0)
+		indices := make(map[string]int)
+		for _, deme := range demes {
+			if _, ok := indices[deme]; !ok {
+				indices[deme] = 0
+				names = append(names, deme)
+			}
+		}
+		sort.Strings(names)
+		for i, deme := range names {
+			indices[deme] = i
+		}
+		if len(names) > nbdemes {
+			nbdemes = len(names)
+		}
+		for i, s := range samples {
+			deme, ok := demes[s.Name]
+			if !ok {
+				err = errors.New("Sample " + s.Name + " is not present in the deme file")
+				return
+			}
+			samples[i].Deme = indices[deme]
+		}
+	}
+	return
+}
+
+func coalescentTree(nbtrees int, samples []tree.CoalescentSample, popsize, growth float64, ndemes int, migration float64, output string) error {
+	var f *os.File
+	var err error
+	var t *tree.Tree
+
+	if output != "stdout" && output != "-" {
+		f, err = os.Create(output)
+		defer f.Close()
+	} else {
+		f = os.Stdout
+	}
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < nbtrees; i++ {
+		t, err = tree.RandomCoalescentTree(samples, popsize, growth, ndemes, migration)
+		if err != nil {
+			return err
+		}
+		f.WriteString(t.Newick() + "\n")
+	}
+	return nil
+}
+
+// coaltreeCmd represents the coaltree command
+var coaltreeCmd = &cobra.Command{
+	Use:   "coaltree",
+	Short: "Generates a random coalescent genealogy",
+	Long: `Generates a random coalescent genealogy.
+
+Genealogies are simulated under the coalescent, with an effective population
+size --popsize at the time of the most recent sample. The population may grow
+exponentially with rate --growth (forward in time): its size at age t (backward
+in time) is popsize.exp(-growth.t). Output trees are rooted, and branch lengths
+are in units of time.
+
+Samples are either:
+- -l tips named Tip0..Tipn, all sampled at the same time;
+- the tips of the date file (-d), with heterochronous sampling dates. The date
+  file contains one tab separated line per tip: tip name, and sampling date.
+
+With several demes (--demes > 1), genealogies are simulated under the structured
+coalescent (island model): each deme has the population size given above, and
+each lineage migrates backward in time to any other deme at rate --migration.
+The deme of each sample is given by the deme file (--deme-file), which contains
+one tab separated line per tip: tip name, and deme name. Without deme file, all
+samples are in the first deme. The number of demes is at least the number of
+distinct demes of the deme file.
+
+Example:
+
+gotree generate coaltree -l 100 --popsize 10 --growth 0.5 -n 10 > trees.nw
+gotree generate coaltree -d dates.txt --popsize 2 > tree.nw
+gotree generate coaltree -d dates.txt --deme-file demes.txt --migration 0.1 > tree.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var samples []tree.CoalescentSample
+		var ndemes int
+
+		if samples, ndemes, err = coalescentSamples(coaltreeNbTips, coaltreeDateFile, coaltreeDemeFile, coaltreeNbDemes); err != nil {
+			io.LogError(err)
+			return
+		}
+		if err = coalescentTree(generateNbTrees, samples, coaltreePopSize, coaltreeGrowth, ndemes, coaltreeMigration, generateOutputfile); err != nil {
+			io.LogError(err)
+		}
+		return
+	},
+}
+
+func init() {
+	generateCmd.AddCommand(coaltreeCmd)
+	coaltreeCmd.PersistentFlags().IntVarP(&coaltreeNbTips, "nbtips", "l", 10, "Number of tips/leaves of the tree to generate (if no date file is given)")
+	coaltreeCmd.PersistentFlags().StringVarP(&coaltreeDateFile, "dates", "d", "none", "Tip sampling date file (tab separated: tip name, date)")
+	coaltreeCmd.PersistentFlags().StringVar(&coaltreeDemeFile, "deme-file", "none", "Tip deme file (tab separated: tip name, deme name)")
+	coaltreeCmd.PersistentFlags().Float64Var(&coaltreePopSize, "popsize", 1, "Effective population size (of each deme) at the time of the most recent sample")
+	coaltreeCmd.PersistentFlags().Float64Var(&coaltreeGrowth, "growth", 0, "Exponential growth rate of the population")
+	coaltreeCmd.PersistentFlags().IntVar(&coaltreeNbDemes, "demes", 1, "Number of demes")
+	coaltreeCmd.PersistentFlags().Float64Var(&coaltreeMigration, "migration", 0, "Backward migration rate of each lineage to any other deme")
+}
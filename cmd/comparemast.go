@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var comparemastrooted bool
+var comparemastoutfile string
+var comparemastremovedfile string
+
+// compareMastCmd represents the compare mast command
+var compareMastCmd = &cobra.Command{
+	Use:   "mast",
+	Short: "Computes a maximum agreement subtree of a reference tree and a set of trees",
+	Long: `Computes a maximum agreement subtree of a reference tree and a set of trees.
+
+For each tree of the compared tree file, it computes a maximum agreement subtree
+(MAST) with the reference tree: a tree having the largest possible set of tips,
+such that the reference and the compared trees restricted to these tips have the
+same topology. The MAST is computed on the tips common to both trees.
+
+By default, trees are considered unrooted. If --rooted is given, both trees must
+be rooted, and the rooted topologies are compared. Multifurcations must be present
+in both trees to be kept in the MAST.
+
+MAST are written in Newick format in the output file (-o), with the branch lengths
+of the reference tree.
+
+If --out-removed is given, it writes in the given file, for each compared tree, tab
+separated values with:
+1) The index of the compared tree in the file
+2) The comma separated list of tips removed from the reference tree
+3) The comma separated list of tips removed from the compared tree
+
+Example:
+gotree compare mast -i species.nw -c genes.nw -o mast.nw --out-removed removed.txt
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var refTree, mast *tree.Tree
+		var removed1, removed2 []string
+		var f, removedf *os.File
+
+		if intree2file == "none" {
+			err = errors.New("You must provide a file containing compared trees")
+			io.LogError(err)
+			return
+		}
+
+		if refTree, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(comparemastoutfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, comparemastoutfile)
+
+		if comparemastremovedfile != "none" {
+			if removedf, err = openWriteFile(comparemastremovedfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(removedf, comparemastremovedfile)
+			fmt.Fprintf(removedf, "tree\tremoved_ref\tremoved_comp\n")
+		}
+
+		if treefile, treechan, err = readTrees(intree2file); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if mast, removed1, removed2, err = tree.MaximumAgreementSubtree(refTree, t.Tree, comparemastrooted); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(mast.Newick() + "\n")
+			if removedf != nil {
+				fmt.Fprintf(removedf, "%d\t%s\t%s\n", t.Id, strings.Join(removed1, ","), strings.Join(removed2, ","))
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	compareCmd.AddCommand(compareMastCmd)
+	compareMastCmd.Flags().BoolVar(&comparemastrooted, "rooted", false, "Considers trees as rooted")
+	compareMastCmd.Flags().StringVarP(&comparemastoutfile, "output", "o", "stdout", "MAST output tree file")
+	compareMastCmd.Flags().StringVar(&comparemastremovedfile, "out-removed", "none", "Output file of the tips removed from the trees")
+}
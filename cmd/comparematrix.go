@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/support"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var comparematrixmetric string
+var comparematrixformat string
+var comparematrixoutfile string
+
+// compareMatrixCmd represents the compare matrix command
+var compareMatrixCmd = &cobra.Command{
+	Use:   "matrix",
+	Short: "Computes the distance matrix between all pairs of input trees",
+	Long: `Computes the distance matrix between all pairs of input trees.
+
+All trees of the input file (-i) must have the same set of tips, and are
+compared with each other using one of the following metrics (--metric):
+- rf      : Robinson-Foulds distance: number of internal bipartitions present in
+            only one of the two trees;
+- nrf     : Normalized Robinson-Foulds distance: Robinson-Foulds distance divided
+            by the total number of internal bipartitions of the two trees;
+- quartet : Number of quartets that are not resolved the same way in the two
+            trees (see gotree compare quartets), i.e. the total number of quartets
+            minus the number of shared quartets and of quartets unresolved in both
+            trees;
+- transfer: Sum of the minimum transfer distances of the internal bipartitions of
+            each tree to the other tree.
+
+Computations are distributed over --threads threads.
+
+Output formats (--output-format):
+- phylip: the number of trees, and then one line per tree, with the index of the
+          tree in the input file, followed by its distances to all the trees;
+- tsv   : a header line with the indexes of the trees, and then one line per tree,
+          with the index of the tree, followed by its distances to all the trees.
+
+The resulting matrix may be used for example to draw an MDS of the tree space.
+
+Example:
+gotree compare matrix -i trees.nw --metric nrf -t 4 --output-format tsv
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var trees []*tree.Tree = make([]*tree.Tree, 0)
+		var dists [][]float64
+		var f *os.File
+
+		if comparematrixformat != "phylip" && comparematrixformat != "tsv" {
+			err = fmt.Errorf("Unknown output format: %s", comparematrixformat)
+			io.LogError(err)
+			return
+		}
+		if _, err = treeDistance(comparematrixmetric, nil, nil); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		maxcpus := runtime.NumCPU()
+		if rootCpus > maxcpus {
+			rootCpus = maxcpus
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if err = t.Tree.ReinitIndexes(); err != nil {
+				io.LogError(err)
+				return
+			}
+			trees = append(trees, t.Tree)
+		}
+
+		if dists, err = treeDistanceMatrix(trees, comparematrixmetric, rootCpus); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(comparematrixoutfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, comparematrixoutfile)
+
+		if comparematrixformat == "phylip" {
+			fmt.Fprintf(f, "%d\n", len(dists))
+		} else {
+			fmt.Fprintf(f, "tree")
+			for i := range dists {
+				fmt.Fprintf(f, "\t%d", i)
+			}
+			fmt.Fprintf(f, "\n")
+		}
+		for i, row := range dists {
+			fmt.Fprintf(f, "%d", i)
+			for _, d := range row {
+				fmt.Fprintf(f, "\t%g", d)
+			}
+			fmt.Fprintf(f, "\n")
+		}
+		return
+	},
+}
+
+// Computes the distance between the two trees using the given metric.
+// If trees are nil, only checks that the metric exists.
+func treeDistance(metric string, t1, t2 *tree.Tree) (dist float64, err error) {
+	var rf, td int
+	var nrf float64
+	var qstats tree.QuartetStats
+
+	switch metric {
+	case "rf", "nrf", "quartet", "transfer":
+	default:
+		err = fmt.Errorf("Unknown metric: %s", metric)
+		return
+	}
+	if t1 == nil || t2 == nil {
+		return
+	}
+
+	switch metric {
+	case "rf", "nrf":
+		if rf, nrf, err = tree.RobinsonFoulds(t1, t2); err != nil {
+			return
+		}
+		dist = float64(rf)
+		if metric == "nrf" {
+			dist = nrf
+		}
+	case "quartet":
+		if qstats, err = tree.QuartetDistance(t1, t2); err != nil {
+			return
+		}
+		dist = float64(qstats.Total - qstats.Shared - qstats.UnresolvedBoth)
+	case "transfer":
+		if td, err = support.TransferDistance(t1, t2); err != nil {
+			return
+		}
+		dist = float64(td)
+	}
+	return
+}
+
+// Computes the distance matrix between all pairs of trees, using
+// the given number of threads. Each thread works on its own copy
+// of the trees, since distance computations may modify them.
+func treeDistanceMatrix(trees []*tree.Tree, metric string, cpus int) (dists [][]float64, err error) {
+	var wg sync.WaitGroup
+	var mux sync.Mutex
+
+	dists = make([][]float64, len(trees))
+	for i := range trees {
+		dists[i] = make([]float64, len(trees))
+	}
+
+	rows := make(chan int, len(trees))
+	for i := range trees {
+		rows <- i
+	}
+	close(rows)
+
+	for cpu := 0; cpu < cpus; cpu++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			copies := make([]*tree.Tree, len(trees))
+			for i, t := range trees {
+				copies[i] = t.Clone()
+				if err2 := copies[i].ReinitIndexes(); err2 != nil {
+					mux.Lock()
+					err = err2
+					mux.Unlock()
+					return
+				}
+			}
+			for i := range rows {
+				for j := i + 1; j < len(trees); j++ {
+					d, err2 := treeDistance(metric, copies[i], copies[j])
+					if err2 != nil {
+						mux.Lock()
+						err = fmt.Errorf("Trees %d and %d: %v", i, j, err2)
+						mux.Unlock()
+						return
+					}
+					dists[i][j] = d
+					dists[j][i] = d
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	return
+}
+
+func init() {
+	compareCmd.AddCommand(compareMatrixCmd)
+	compareMatrixCmd.Flags().StringVar(&comparematrixmetric, "metric", "rf", "Distance metric: rf, nrf, quartet, or transfer")
+	compareMatrixCmd.Flags().StringVar(&comparematrixformat, "output-format", "phylip", "Output format: phylip or tsv")
+	compareMatrixCmd.Flags().StringVarP(&comparematrixoutfile, "output", "o", "stdout", "Distance matrix output file")
+}
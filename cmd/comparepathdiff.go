@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var comparepathdiffweighted bool
+
+// comparePathDiffCmd represents the compare pathdiff command
+var comparePathDiffCmd = &cobra.Command{
+	Use:   "pathdiff",
+	Short: "Computes path difference distances between all pairs of input trees",
+	Long: `Computes path difference distances between all pairs of input trees.
+
+The path difference distance (Steel & Penny, 1993) between two trees is the
+euclidean distance between the vectors of the distances between all pairs of
+tips in the two trees. It is computed over the common tips of the two trees
+(other tips are removed before computation), and trees are considered unrooted.
+
+By default, the distance between two tips is the number of branches separating
+them (topological path difference). If --weighted is given, it is the sum of
+the lengths of these branches (cophenetic distance), and all branch lengths
+must be defined.
+
+All trees of the input file (-i) are compared with each other, and the output
+is a distance matrix in the same format as the one of gotree matrix: the
+number of trees, and then one line per tree, with the index of the tree in
+the input file, followed by its distances to all the trees.
+
+Example:
+gotree compare pathdiff -i trees.nw --weighted
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var trees []*tree.Tree = make([]*tree.Tree, 0)
+		var dists [][]float64
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			trees = append(trees, t.Tree)
+		}
+
+		if dists, err = tree.PathDifferenceMatrix(trees, comparepathdiffweighted); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		fmt.Printf("%d\n", len(dists))
+		for i, row := range dists {
+			fmt.Printf("%d", i)
+			for _, d := range row {
+				fmt.Printf("\t%.12f", d)
+			}
+			fmt.Printf("\n")
+		}
+		return
+	},
+}
+
+func init() {
+	compareCmd.AddCommand(comparePathDiffCmd)
+	comparePathDiffCmd.Flags().BoolVar(&comparepathdiffweighted, "weighted", false, "Uses branch lengths (cophenetic distances) instead of numbers of branches")
+}
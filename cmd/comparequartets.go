@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// compareQuartetsCmd represents the compare quartets command
+var compareQuartetsCmd = &cobra.Command{
+	Use:   "quartets",
+	Short: "Compare quartets of a reference tree with a set of trees",
+	Long: `Compare quartets of a reference tree with a set of trees.
+
+Trees must have the same set of tips. A quartet of tips {a,b,c,d} is resolved
+in a tree (e.g. ab|cd) if an edge separates a and b from c and d, and is
+unresolved otherwise (multifurcation).
+
+For each tree in the compared tree file, it will print tab separated values with:
+1) The index of the compared tree in the file
+2) The number of quartets resolved the same way in both trees
+3) The number of quartets resolved differently in both trees
+4) The number of quartets unresolved in the reference tree only
+5) The number of quartets unresolved in the compared tree only
+6) The number of quartets unresolved in both trees
+7) The total number of quartets
+
+Quartets are counted without being enumerated, for each pair of internal
+nodes of the two trees. The running time is quadratic in the number of tips
+(sub-quadratic algorithms such as the one of tqDist are not implemented),
+which limits it to trees of up to about ten thousand tips.
+Memory usage is linear in the number of tips times the maximum node degree.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var refTree *tree.Tree
+		var stats tree.QuartetStats
+
+		if intree2file == "none" {
+			err = errors.New("You must provide a file containing compared trees")
+			io.LogError(err)
+			return
+		}
+
+		if refTree, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intree2file); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		fmt.Printf("tree\tshared\tdiff\tunresolved_ref\tunresolved_comp\tunresolved_both\ttotal\n")
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if stats, err = tree.QuartetDistance(refTree, t.Tree); err != nil {
+				io.LogError(err)
+				return
+			}
+			fmt.Printf("%d\t%d\t%d\t%d\t%d\t%d\t%d\n", t.Id, stats.Shared, stats.Diff,
+				stats.Unresolved1, stats.Unresolved2, stats.UnresolvedBoth, stats.Total)
+		}
+		return
+	},
+}
+
+func init() {
+	compareCmd.AddCommand(compareQuartetsCmd)
+}
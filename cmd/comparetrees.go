@@ -14,6 +14,7 @@ import (
 
 var comparetreeidentical bool
 var comparetreerf bool
+var comparetreemetric string
 
 // compareCmd represents the compare command
 var compareTreesCmd = &cobra.Command{
@@ -36,6 +37,27 @@ For each trees in the compared tree file, it will print tab separated values wit
 
 If --rf is given, it only computes the Robinson-Foulds distance, as the sum of 
 reference + compared specific branches.
+
+If --metric is given, it computes the given distance between the reference tree
+and each compared tree, and prints tab separated values with the index of the
+compared tree and the distance. Available metrics:
+- rf  : Robinson-Foulds distance (same as --rf);
+- msd : Matching split distance (Bogdanowicz & Giaro, 2012): internal branches
+        of both trees are matched, minimizing the sum of the number of tips to
+        move to transform each branch into its match. Unmatched branches
+        (multifurcations) cost the number of tips on their lightest side;
+- cid : Clustering information distance (Smith, 2020), in bits: sum of the
+        entropies of the bipartitions of both trees, minus twice their mutual
+        clustering information;
+- ncid: Clustering information distance divided by the sum of the entropies
+        of the bipartitions of both trees (between 0 and 1);
+- wrf : Weighted Robinson-Foulds distance: sum of the absolute differences of
+        branch lengths of all branches (a branch absent from one of the trees
+        having a length of 0);
+- kf  : Branch score distance (Kuhner & Felsenstein, 1994): square root of the
+        sum of the squared differences of branch lengths of all branches.
+For wrf and kf, tip branches are taken into account and all branch lengths
+must be defined.
 `,
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
 		var treefile goio.Closer
@@ -49,6 +71,14 @@ reference + compared specific branches.
 			return
 		}
 
+		switch comparetreemetric {
+		case "none":
+		case "rf":
+			comparetreerf = true
+		default:
+			return compareTreesMetric(comparetreemetric)
+		}
+
 		maxcpus := runtime.NumCPU()
 		if rootCpus > maxcpus {
 			rootCpus = maxcpus
@@ -117,4 +147,71 @@ func init() {
 	compareTreesCmd.Flags().BoolVarP(&compareTips, "tips", "l", false, "Include tips in the comparison")
 	compareTreesCmd.Flags().BoolVar(&comparetreeidentical, "binary", false, "If true, then just print true (identical tree) or false (different tree) for each compared tree")
 	compareTreesCmd.Flags().BoolVar(&comparetreerf, "rf", false, "If true, outputs Robinson-Foulds distance, as the sum of reference + compared specific branches")
+	compareTreesCmd.Flags().StringVar(&comparetreemetric, "metric", "none", "Distance to compute: rf, msd, cid, ncid, wrf, or kf")
+}
+
+// Computes the given distance metric between the reference
+// tree and all the compared trees
+func compareTreesMetric(metric string) (err error) {
+	var treefile goio.Closer
+	var treechan <-chan tree.Trees
+	var refTree *tree.Tree
+	var dist float64
+	var distfunc func(t1, t2 *tree.Tree) (float64, error)
+
+	switch metric {
+	case "msd":
+		distfunc = tree.MatchingSplitDistance
+	case "cid":
+		distfunc = func(t1, t2 *tree.Tree) (d float64, err error) {
+			d, _, err = tree.ClusteringInformationDistance(t1, t2)
+			return
+		}
+	case "ncid":
+		distfunc = func(t1, t2 *tree.Tree) (d float64, err error) {
+			_, d, err = tree.ClusteringInformationDistance(t1, t2)
+			return
+		}
+	case "wrf":
+		distfunc = tree.WeightedRobinsonFoulds
+	case "kf":
+		distfunc = tree.BranchScoreDistance
+	default:
+		err = fmt.Errorf("Unknown metric: %s", metric)
+		io.LogError(err)
+		return
+	}
+
+	if refTree, err = readTree(intreefile); err != nil {
+		io.LogError(err)
+		return
+	}
+	if err = refTree.ReinitIndexes(); err != nil {
+		io.LogError(err)
+		return
+	}
+	if treefile, treechan, err = readTrees(intree2file); err != nil {
+		io.LogError(err)
+		return
+	}
+	defer treefile.Close()
+
+	fmt.Printf("tree\t%s\n", metric)
+	for t := range treechan {
+		if t.Err != nil {
+			err = t.Err
+			io.LogError(err)
+			return
+		}
+		if err = t.Tree.ReinitIndexes(); err != nil {
+			io.LogError(err)
+			return
+		}
+		if dist, err = distfunc(refTree, t.Tree); err != nil {
+			io.LogError(err)
+			return
+		}
+		fmt.Printf("%d\t%f\n", t.Id, dist)
+	}
+	return
 }
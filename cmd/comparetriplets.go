@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// compareTripletsCmd represents the compare triplets command
+var compareTripletsCmd = &cobra.Command{
+	Use:   "triplets",
+	Short: "Compare rooted triplets of a reference tree with a set of trees",
+	Long: `Compare rooted triplets of a reference tree with a set of trees.
+
+Trees must be rooted, and must have the same set of tips. A triplet of tips
+{a,b,c} is resolved in a tree (e.g. ab|c) if the most recent common ancestor
+of a and b is a descendant of the most recent common ancestor of a, b and c,
+and is unresolved otherwise (multifurcation).
+
+For each tree in the compared tree file, it will print tab separated values with:
+1) The index of the compared tree in the file
+2) The number of triplets resolved the same way in both trees
+3) The number of triplets resolved differently in both trees
+4) The number of triplets unresolved in the reference tree only
+5) The number of triplets unresolved in the compared tree only
+6) The number of triplets unresolved in both trees
+7) The total number of triplets
+
+An error is returned if one of the trees is not rooted.
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var refTree *tree.Tree
+		var stats tree.TripletStats
+
+		if intree2file == "none" {
+			err = errors.New("You must provide a file containing compared trees")
+			io.LogError(err)
+			return
+		}
+
+		if refTree, err = readTree(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		if !refTree.Rooted() {
+			err = errors.New("The reference tree is not rooted")
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intree2file); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		fmt.Printf("tree\tshared\tdiff\tunresolved_ref\tunresolved_comp\tunresolved_both\ttotal\n")
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if stats, err = tree.TripletDistance(refTree, t.Tree); err != nil {
+				io.LogError(err)
+				return
+			}
+			fmt.Printf("%d\t%d\t%d\t%d\t%d\t%d\t%d\n", t.Id, stats.Shared, stats.Diff,
+				stats.Unresolved1, stats.Unresolved2, stats.UnresolvedBoth, stats.Total)
+		}
+		return
+	},
+}
+
+func init() {
+	compareCmd.AddCommand(compareTripletsCmd)
+}
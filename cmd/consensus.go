@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	goio "io"
 	"os"
 
@@ -9,6 +10,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var consensusmode string
+var consensuscutoff float64
+
 // consensusCmd represents the consensus command
 var consensusCmd = &cobra.Command{
 	Use:   "consensus",
@@ -16,10 +20,18 @@ var consensusCmd = &cobra.Command{
 	Long: `Computes the consensus of a set of input trees
 Trees must have the same tip names.
 
-Two parameters:
--i : Input file containing several trees
--f : Percentage threshold to keep a bipartition in the consensus 
-     It must be >=0.5 && <=1
+Parameters:
+-i     : Input file containing several trees
+--mode : Consensus method:
+         - majority: Bipartitions present in more than -f of the trees are kept (default)
+         - strict  : Only bipartitions present in all the trees are kept
+         - greedy  : Extended majority rule consensus: bipartitions are added by
+                     decreasing frequency if they are compatible with the ones
+                     already added, until the consensus is fully resolved
+         - adams   : Adams consensus, input trees must be rooted. It keeps the
+                     nestings shared by all trees, and the consensus is rooted
+-f     : Percentage threshold to keep a bipartition in the consensus (majority mode)
+         It must be >=0.5 && <=1
 
 In the output consensus tree:
 1) Branch supports are computed as the proportion of trees in which
@@ -45,7 +57,18 @@ In the output consensus tree:
 			return
 		}
 		defer treefile.Close()
-		consensus, err = tree.Consensus(treechan, cutoff)
+		switch consensusmode {
+		case "majority":
+			consensus, err = tree.Consensus(treechan, consensuscutoff)
+		case "strict":
+			consensus, err = tree.StrictConsensus(treechan)
+		case "greedy":
+			consensus, err = tree.GreedyConsensus(treechan)
+		case "adams":
+			consensus, err = tree.AdamsConsensus(treechan)
+		default:
+			err = fmt.Errorf("Unknown consensus mode: %s", consensusmode)
+		}
 		if err != nil {
 			io.LogError(err)
 			return
@@ -59,5 +82,6 @@ func init() {
 	computeCmd.AddCommand(consensusCmd)
 	consensusCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree")
 	consensusCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output file")
-	consensusCmd.PersistentFlags().Float64VarP(&cutoff, "freq-min", "f", 0.5, "Minimum frequency to keep the bipartitions")
+	consensusCmd.PersistentFlags().Float64VarP(&consensuscutoff, "freq-min", "f", 0.5, "Minimum frequency to keep the bipartitions (majority mode)")
+	consensusCmd.PersistentFlags().StringVar(&consensusmode, "mode", "majority", "Consensus method: majority, strict, greedy, or adams")
 }
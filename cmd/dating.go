@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io/fileutils"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var datingdatefile string
+
+// datingCmd represents the dating command
+var datingCmd = &cobra.Command{
+	Use:   "dating",
+	Short: "Analyzes and dates trees using tip dates",
+	Long: `Analyzes and dates trees using tip dates.
+
+Tip dates are given in a tab separated file (-d), with columns:
+1) Tip name
+2) Date of the tip (e.g. in decimal years)
+
+gotree dating lsd also accepts date intervals and constraints on internal
+nodes (see gotree dating lsd --help).
+`,
+}
+
+// Reads the tip date file: tip name => date
+func readDateFile(file string) (dates map[string]float64, err error) {
+	var datemap map[string]string
+	var d float64
+
+	if datemap, err = readMapFile(file, false); err != nil {
+		return
+	}
+	dates = make(map[string]float64, len(datemap))
+	for name, date := range datemap {
+		if d, err = strconv.ParseFloat(date, 64); err != nil {
+			err = fmt.Errorf("Wrong date for tip %s: %s", name, date)
+			return
+		}
+		dates[name] = d
+	}
+	return
+}
+
+// Reads the date constraint file. Each line contains tab separated columns:
+// 1) Tip name, or comma separated tip names (constraint on their MRCA)
+// 2) Date, or minimum date if a third column is given
+// 3) Optional maximum date
+// Dates may be -inf or inf for one sided constraints.
+func readDateConstraintFile(file string) (constraints []tree.DateConstraint, err error) {
+	var datefile *os.File
+	var reader *bufio.Reader
+	var gr *gzip.Reader
+	var min, max float64
+
+	if datefile, err = os.Open(file); err != nil {
+		return
+	}
+	defer datefile.Close()
+
+	if strings.HasSuffix(file, ".gz") {
+		if gr, err = gzip.NewReader(datefile); err != nil {
+			return
+		}
+		defer gr.Close()
+		reader = bufio.NewReader(gr)
+	} else {
+		reader = bufio.NewReader(datefile)
+	}
+
+	constraints = make([]tree.DateConstraint, 0)
+	line, e := fileutils.Readln(reader)
+	nl := 1
+	for e == nil {
+		cols := strings.Split(line, "\t")
+		if len(cols) != 2 && len(cols) != 3 {
+			err = fmt.Errorf("Date file does not have 2 or 3 fields at line: %d", nl)
+			return
+		}
+		if min, err = strconv.ParseFloat(cols[1], 64); err != nil {
+			err = fmt.Errorf("Wrong date at line %d: %s", nl, cols[1])
+			return
+		}
+		max = min
+		if len(cols) == 3 {
+			if max, err = strconv.ParseFloat(cols[2], 64); err != nil {
+				err = fmt.Errorf("Wrong date at line %d: %s", nl, cols[2])
+				return
+			}
+		}
+		constraints = append(constraints, tree.DateConstraint{
+			Tips: strings.Split(cols[0], ","),
+			Min:  min,
+			Max:  max,
+		})
+		line, e = fileutils.Readln(reader)
+		nl++
+	}
+	return
+}
+
+func init() {
+	RootCmd.AddCommand(datingCmd)
+	datingCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree file")
+	datingCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output tree file")
+	datingCmd.PersistentFlags().StringVarP(&datingdatefile, "dates", "d", "none", "Tip date file")
+}
@@ -0,0 +1,26 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+var inmatrixfile string
+
+// inferCmd represents the infer command
+var inferCmd = &cobra.Command{
+	Use:   "infer",
+	Short: "Infers trees from distance matrices",
+	Long: `Infers trees from distance matrices.
+
+Input distance matrices are in PHYLIP format: the first line gives the number
+of taxa, and each following line gives the name of a taxon followed by its 
+distances to the other taxa (square or lower triangular matrix). This is the 
+format produced by gotree matrix.
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(inferCmd)
+	inferCmd.PersistentFlags().StringVarP(&inmatrixfile, "input", "i", "stdin", "Input distance matrix (PHYLIP format)")
+	inferCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "Output tree file")
+}
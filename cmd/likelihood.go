@@ -0,0 +1,198 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/likelihood"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var likelihoodphylip bool
+var likelihoodinputstrict bool
+var likelihoodoutfile string
+
+// Substitution model options, shared by commands using likelihood
+var likelihoodmodel string
+var likelihoodkappa float64
+var likelihoodrates string
+var likelihoodfreqs string
+var likelihoodalpha float64
+var likelihoodncat int
+
+// likelihoodCmd represents the likelihood command
+var likelihoodCmd = &cobra.Command{
+	Use:   "likelihood",
+	Short: "Computes log likelihoods of input trees given an alignment",
+	Long: `Computes log likelihoods of input trees given an alignment.
+
+The likelihood is computed using the Felsenstein pruning algorithm,
+under the given substitution model (--model):
+- Nucleotides: jc69, k80, hky or gtr
+- Amino acids: dayhoff, jtt, mtrev, lg, wag or hivb
+
+Branch lengths are expressed in expected number of substitutions per site,
+and must all be defined in the input trees.
+
+Model parameters:
+--kappa: transition/transversion rate ratio (k80 and hky)
+--rates: relative rates A<->C,A<->G,A<->T,C<->G,C<->T,G<->T (gtr)
+--freqs: equilibrium frequencies (hky, gtr and protein models), either
+	"empirical" (computed from the alignment), "model" (protein models),
+	or comma separated frequencies (ACGT order for nucleotides,
+	ARNDCQEGHILKMFPSTWYV order for amino acids). Default: empirical for
+	nucleotide models and model for protein models.
+--alpha and --ncat: Discrete gamma rate heterogeneity (if ncat > 1)
+
+Gaps and unknown characters are considered as missing data, and ambiguous
+characters (IUPAC codes for nucleotides, B, Z and J for amino acids) as
+any of the compatible characters.
+
+Output file (-o) is tab separated:
+tree  lnl
+
+Example:
+gotree compute likelihood -i tree.nw -a align.fa --model gtr --ncat 4 --alpha 0.5
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var al align.Alignment
+		var model *likelihood.Model
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var f *os.File
+		var lnl float64
+
+		if al, err = readAlignment(inalignfile, likelihoodphylip, likelihoodinputstrict); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if model, err = newLikelihoodModel(al); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if f, err = openWriteFile(likelihoodoutfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, likelihoodoutfile)
+
+		f.WriteString("tree\tlnl\n")
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if lnl, err = likelihood.LogLikelihood(t.Tree, al, model); err != nil {
+				io.LogError(err)
+				return
+			}
+			fmt.Fprintf(f, "%d\t%f\n", t.Id, lnl)
+		}
+		return
+	},
+}
+
+// Adds substitution model options to the given command
+func addLikelihoodModelFlags(c *cobra.Command) {
+	c.PersistentFlags().StringVar(&likelihoodmodel, "model", "jc69", "Substitution model: jc69, k80, hky, gtr, dayhoff, jtt, mtrev, lg, wag, or hivb")
+	c.PersistentFlags().Float64Var(&likelihoodkappa, "kappa", 1.0, "Transition/transversion rate ratio (k80 and hky)")
+	c.PersistentFlags().StringVar(&likelihoodrates, "rates", "1,1,1,1,1,1", "Relative rates A<->C,A<->G,A<->T,C<->G,C<->T,G<->T (gtr)")
+	c.PersistentFlags().StringVar(&likelihoodfreqs, "freqs", "", "Equilibrium frequencies: empirical, model, or comma separated list (default: empirical for nucleotides, model for amino acids)")
+	c.PersistentFlags().Float64Var(&likelihoodalpha, "alpha", 1.0, "Alpha parameter of the discrete gamma rate heterogeneity")
+	c.PersistentFlags().IntVar(&likelihoodncat, "ncat", 1, "Number of discrete gamma rate categories (1: no rate heterogeneity)")
+}
+
+// Builds the substitution model given by the command line options
+// (see addLikelihoodModelFlags)
+func newLikelihoodModel(al align.Alignment) (model *likelihood.Model, err error) {
+	var freqs, rates []float64
+	var modelcode int
+
+	if modelcode = likelihood.ModelStringToInt(likelihoodmodel); modelcode == -1 {
+		err = fmt.Errorf("unknown substitution model: %s", likelihoodmodel)
+		return
+	}
+
+	if likelihood.IsNucleotideModel(modelcode) != (al.Alphabet() == align.NUCLEOTIDS) {
+		err = fmt.Errorf("substitution model %s is not compatible with the alignment alphabet (%s)", likelihoodmodel, al.AlphabetStr())
+		return
+	}
+
+	switch strings.ToLower(likelihoodfreqs) {
+	case "":
+		if al.Alphabet() == align.NUCLEOTIDS {
+			freqs = likelihood.EmpiricalFrequencies(al)
+		}
+	case "empirical":
+		freqs = likelihood.EmpiricalFrequencies(al)
+	case "model":
+		if al.Alphabet() == align.NUCLEOTIDS {
+			err = fmt.Errorf("model frequencies are only available for protein models")
+			return
+		}
+	default:
+		if freqs, err = parseFloatList(likelihoodfreqs); err != nil {
+			return
+		}
+	}
+
+	switch modelcode {
+	case likelihood.MODEL_JC69:
+		model, err = likelihood.NewJC69Model()
+	case likelihood.MODEL_K80:
+		model, err = likelihood.NewK80Model(likelihoodkappa)
+	case likelihood.MODEL_HKY:
+		model, err = likelihood.NewHKYModel(likelihoodkappa, freqs)
+	case likelihood.MODEL_GTR:
+		if rates, err = parseFloatList(likelihoodrates); err != nil {
+			return
+		}
+		model, err = likelihood.NewGTRModel(rates, freqs)
+	default:
+		model, err = likelihood.NewProteinModel(modelcode, freqs)
+	}
+	if err != nil {
+		return
+	}
+
+	err = model.SetGamma(likelihoodalpha, likelihoodncat)
+	return
+}
+
+// Parses a comma separated list of floats
+func parseFloatList(list string) (values []float64, err error) {
+	var v float64
+	for _, s := range strings.Split(list, ",") {
+		if v, err = strconv.ParseFloat(strings.TrimSpace(s), 64); err != nil {
+			err = fmt.Errorf("invalid value in list %s: %v", list, err)
+			return
+		}
+		values = append(values, v)
+	}
+	return
+}
+
+func init() {
+	computeCmd.AddCommand(likelihoodCmd)
+	likelihoodCmd.PersistentFlags().StringVarP(&inalignfile, "align", "a", "stdin", "Alignment input file")
+	likelihoodCmd.PersistentFlags().BoolVarP(&likelihoodphylip, "phylip", "p", false, "Alignment is in phylip? default : false (Fasta)")
+	likelihoodCmd.PersistentFlags().BoolVar(&likelihoodinputstrict, "input-strict", false, "Strict phylip input format (only used with -p)")
+	likelihoodCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree(s)")
+	likelihoodCmd.PersistentFlags().StringVarP(&likelihoodoutfile, "output", "o", "stdout", "Log likelihood output file")
+	addLikelihoodModelFlags(likelihoodCmd)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var lsdrate float64
+var lsdseqlen int
+var lsdstatsfile string
+
+// lsdCmd represents the dating lsd command
+var lsdCmd = &cobra.Command{
+	Use:   "lsd",
+	Short: "Dates rooted trees by least-squares",
+	Long: `Dates rooted trees by least-squares.
+
+For each input rooted tree, it converts substitution branch lengths into time
+durations, as in LSD (To et al., 2016): dates of the nodes and substitution
+rate minimize the weighted sum over all the branches of
+(b - rate * (date(child) - date(parent)))², where b is the substitution length
+of the branch, under the given date constraints, and such that a node is not
+older than its parent.
+
+Dates are given in a tab separated file (-d), with columns:
+1) Tip name, or comma separated tip names for a constraint on the date of
+   their most recent common ancestor
+2) Date of the node, or lower bound of its date if a third column is given
+3) Optional upper bound of the date of the node
+Bounds may be -inf or inf. Tips that are not in the file have their date
+estimated.
+
+If --rate is > 0, the substitution rate is fixed, otherwise it is estimated.
+If --seq-length is > 0, branches are weighted by 1/(b + 1/seq-length), otherwise
+all branches have the same weight.
+
+Rooted time trees (e.g. rooted with gotree dating rtt) are written in the output
+file (-o), and each node is annotated with a comment [date=<date>].
+
+If --out-stats is given, it writes in the given file, for each tree, tab
+separated values with:
+1) The index of the tree in the input file
+2) The substitution rate
+3) The date of the root
+4) The weighted sum of squares of the branch residuals
+
+Example:
+gotree dating rtt -i tree.nw -d dates.txt | gotree dating lsd -d dates.txt -o timetree.nw --out-stats stats.txt
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var constraints []tree.DateConstraint
+		var stats tree.LSDStats
+		var f, statsf *os.File
+
+		if datingdatefile == "none" {
+			err = errors.New("You must provide a date file")
+			io.LogError(err)
+			return
+		}
+		if constraints, err = readDateConstraintFile(datingdatefile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if lsdstatsfile != "none" {
+			if statsf, err = openWriteFile(lsdstatsfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, lsdstatsfile)
+			fmt.Fprintf(statsf, "tree\trate\troot_date\tobjective\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if stats, err = t.Tree.LSDDating(constraints, lsdrate, lsdseqlen); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if statsf != nil {
+				fmt.Fprintf(statsf, "%d\t%g\t%g\t%g\n", t.Id, stats.Rate, stats.RootDate, stats.Objective)
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	datingCmd.AddCommand(lsdCmd)
+	lsdCmd.Flags().Float64Var(&lsdrate, "rate", 0, "Substitution rate (estimated if <= 0)")
+	lsdCmd.Flags().IntVar(&lsdseqlen, "seq-length", 0, "Sequence length used to weight branches (no weighting if <= 0)")
+	lsdCmd.Flags().StringVar(&lsdstatsfile, "out-stats", "none", "Output file of the dating statistics")
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var madstatsfile string
+
+// madCmd represents the reroot mad command
+var madCmd = &cobra.Command{
+	Use:   "mad",
+	Short: "Reroot trees using the Minimal Ancestor Deviation method",
+	Long: `Reroot trees using the Minimal Ancestor Deviation method (MAD, Tria et al., 2017).
+
+For a given root, the relative deviation of the ancestor a of each pair of tips
+(x,y) is 2*d(x,a)/d(x,y) - 1. The tree is rerooted at the position, over all
+the positions of all the branches, minimizing the ancestor deviation (root mean
+square of the relative deviations of all pairs of tips).
+
+It does not need an outgroup, nor a molecular clock. All branch lengths must be
+defined.
+
+If --out-stats is given, it writes in the given file, for each tree, tab
+separated values with:
+1) The index of the tree in the input file
+2) The minimal ancestor deviation
+3) The root ambiguity index: ratio between the minimal ancestor deviation and
+   the minimal ancestor deviation of the second best branch (close to 1 if the
+   root position is ambiguous)
+
+Example:
+
+gotree reroot mad -i tree.nw --out-stats stats.txt > reroot.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, statsf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var mad, ai float64
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if madstatsfile != "none" {
+			if statsf, err = openWriteFile(madstatsfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, madstatsfile)
+			fmt.Fprintf(statsf, "tree\tmad\tambiguity_index\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if mad, ai, err = t.Tree.RerootMAD(); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if statsf != nil {
+				fmt.Fprintf(statsf, "%d\t%g\t%g\n", t.Id, mad, ai)
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	rerootCmd.AddCommand(madCmd)
+	madCmd.Flags().StringVar(&madstatsfile, "out-stats", "none", "Output file of the ancestor deviations and root ambiguity indexes")
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var minvarstatsfile string
+
+// minvarCmd represents the reroot minvar command
+var minvarCmd = &cobra.Command{
+	Use:   "minvar",
+	Short: "Reroot trees using the Minimum Variance method",
+	Long: `Reroot trees using the Minimum Variance method (MinVar, Mai et al., 2017).
+
+The tree is rerooted at the position, over all the positions of all the
+branches, minimizing the variance of root-to-tip distances.
+
+All branch lengths must be defined.
+
+If --out-stats is given, it writes in the given file, for each tree, tab
+separated values with:
+1) The index of the tree in the input file
+2) The variance of root-to-tip distances of the rerooted tree
+
+Example:
+
+gotree reroot minvar -i tree.nw --out-stats stats.txt > reroot.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f, statsf *os.File
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var variance float64
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if minvarstatsfile != "none" {
+			if statsf, err = openWriteFile(minvarstatsfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, minvarstatsfile)
+			fmt.Fprintf(statsf, "tree\tvariance\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				io.LogError(t.Err)
+				return t.Err
+			}
+			if variance, err = t.Tree.RerootMinVar(); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if statsf != nil {
+				fmt.Fprintf(statsf, "%d\t%g\n", t.Id, variance)
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	rerootCmd.AddCommand(minvarCmd)
+	minvarCmd.Flags().StringVar(&minvarstatsfile, "out-stats", "none", "Output file of the root-to-tip distance variances")
+}
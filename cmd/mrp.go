@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+	"strings"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/goalign/io/nexus"
+	"github.com/evolbioinfo/goalign/io/phylip"
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var mrpoutfile string
+var mrpformat string
+
+// mrpCmd represents the supertree mrp command
+var mrpCmd = &cobra.Command{
+	Use:   "mrp",
+	Short: "Builds the Matrix Representation with Parsimony of a set of trees",
+	Long: `Builds the Matrix Representation with Parsimony of a set of trees.
+
+Each distinct internal bipartition of each input tree is coded as a binary
+character (Baum 1992; Ragan 1992). For each taxon of the union of the tips of
+all the trees, the character is:
+- 1 if the taxon is on one side of the bipartition (in the clade if the tree
+  is rooted);
+- 0 if the taxon is on the other side;
+- ? if the taxon is not present in the tree.
+
+The resulting matrix may then be analyzed with a parsimony tool to build a
+supertree.
+
+Output formats (--output-format):
+- phylip: PHYLIP alignment, one sequence per line;
+- nexus : Nexus data block (standard datatype, symbols "01", missing ?).
+
+Example:
+gotree supertree mrp -i trees.nw --output-format nexus -o mrp.nex
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var taxa, matrix []string
+		var f *os.File
+
+		if mrpformat != "phylip" && mrpformat != "nexus" {
+			err = fmt.Errorf("Unknown output format: %s", mrpformat)
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if taxa, matrix, err = tree.MRPMatrix(treechan); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		al := align.NewAlign(align.UNKNOWN)
+		for i, name := range taxa {
+			if err = al.AddSequence(name, matrix[i], ""); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
+
+		var out string
+		if mrpformat == "phylip" {
+			out = phylip.WriteAlignment(al, false, true, true)
+		} else {
+			// goalign writes dna datatype by default
+			out = nexus.WriteAlignment(al)
+			if !strings.Contains(out, "format datatype=dna;") {
+				err = fmt.Errorf("Unexpected Nexus format line: cannot set the standard datatype")
+				io.LogError(err)
+				return
+			}
+			out = strings.Replace(out, "format datatype=dna;", "format datatype=standard symbols=\"01\" missing=?;", 1)
+		}
+
+		if f, err = openWriteFile(mrpoutfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, mrpoutfile)
+		f.WriteString(out)
+		return
+	},
+}
+
+func init() {
+	supertreeCmd.AddCommand(mrpCmd)
+	mrpCmd.Flags().StringVarP(&mrpoutfile, "output", "o", "stdout", "MRP matrix output file")
+	mrpCmd.Flags().StringVar(&mrpformat, "output-format", "phylip", "Output format: phylip or nexus")
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var njalgo string
+
+// njCmd represents the nj command
+var njCmd = &cobra.Command{
+	Use:   "nj",
+	Short: "Infers a tree from a distance matrix using NJ or BIONJ",
+	Long: `Infers a tree from a distance matrix using NJ or BIONJ.
+
+--algo nj   : Neighbor-Joining (Saitou & Nei, 1987)
+--algo bionj: BIONJ (Gascuel, 1997)
+
+The output tree is unrooted. Negative branch lengths are set to 0.
+
+Example:
+
+gotree matrix -i tree.nw | gotree infer nj --algo bionj > inferred.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var names []string
+		var matrix [][]float64
+		var t *tree.Tree
+
+		if names, matrix, err = utils.ReadDistanceMatrix(inmatrixfile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		switch strings.ToLower(njalgo) {
+		case "nj":
+			t, err = tree.NeighborJoining(names, matrix)
+		case "bionj":
+			t, err = tree.BioNJ(names, matrix)
+		default:
+			err = fmt.Errorf("unknown distance algorithm: %s", njalgo)
+		}
+		if err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+		f.WriteString(t.Newick() + "\n")
+		return
+	},
+}
+
+func init() {
+	inferCmd.AddCommand(njCmd)
+	njCmd.PersistentFlags().StringVar(&njalgo, "algo", "nj", "Distance algorithm: nj or bionj")
+}
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"fmt"
 	goio "io"
 	"os"
 
@@ -9,33 +10,68 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var rearrangeunique bool
+var rearrangecount bool
+
 // rerootCmd represents the reroot command
 var nniCmd = &cobra.Command{
 	Use:   "nni",
 	Short: "Generates all NNI neighbors from a given tree",
 	Long: `Generates all NNI neighbors from a given tree.
+
+If --unique is given, only distinct topologies are output.
+If --count is given, only the number of distinct topologies is output
+(one line per input tree).
 `,
 
 	RunE: func(cmd *cobra.Command, args []string) (err error) {
-		var f *os.File
-		var treefile goio.Closer
-		var treechan <-chan tree.Trees
-
-		if treefile, treechan, err = readTrees(intreefile); err != nil {
+		if err = writeNeighbors(&tree.NNIRearranger{}); err != nil {
 			io.LogError(err)
-			return
 		}
-		defer treefile.Close()
+		return
+	},
+}
 
-		if f, err = openWriteFile(outtreefile); err != nil {
-			io.LogError(err)
-			return
-		}
-		defer closeWriteFile(f, outtreefile)
+// Writes all neighbors of the input trees, given the rearranger
+// (or only distinct neighbors, or the number of distinct neighbors,
+// depending on --unique and --count options)
+func writeNeighbors(r tree.Rearranger) (err error) {
+	var f *os.File
+	var treefile goio.Closer
+	var treechan <-chan tree.Trees
 
-		r := &tree.NNIRearranger{}
+	if treefile, treechan, err = readTrees(intreefile); err != nil {
+		return
+	}
+	defer treefile.Close()
 
-		for t := range treechan {
+	if f, err = openWriteFile(outtreefile); err != nil {
+		return
+	}
+	defer closeWriteFile(f, outtreefile)
+
+	for t := range treechan {
+		if t.Err != nil {
+			return t.Err
+		}
+		if rearrangecount {
+			var nb int
+			if nb, err = t.Tree.CountNeighbors(r); err != nil {
+				return
+			}
+			fmt.Fprintf(f, "%d\n", nb)
+		} else if rearrangeunique {
+			err2 := t.Tree.Neighbors(r, func(nt *tree.Tree) bool {
+				if err = nt.CheckTreePostOrder(); err != nil {
+					return false
+				}
+				f.WriteString(nt.Newick() + "\n")
+				return true
+			})
+			if err2 != nil {
+				return err2
+			}
+		} else {
 			r.Rearrange(t.Tree, func(re tree.Rearrangement) bool {
 				if err = re.Apply(); err != nil {
 					return false
@@ -54,19 +90,18 @@ var nniCmd = &cobra.Command{
 				}
 				return true
 			})
-
-			if err != nil {
-				io.LogError(err)
-				return
-			}
 		}
-
-		return
-	},
+		if err != nil {
+			return
+		}
+	}
+	return
 }
 
 func init() {
 	RootCmd.AddCommand(nniCmd)
 	nniCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input Tree")
 	nniCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "NNI output tree file")
+	nniCmd.PersistentFlags().BoolVar(&rearrangeunique, "unique", false, "Only output distinct topologies")
+	nniCmd.PersistentFlags().BoolVar(&rearrangecount, "count", false, "Only output the number of distinct topologies")
 }
@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	goio "io"
+	"os"
+	"strings"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/asr"
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var parsimonyphylip bool
+var parsimonyinputstrict bool
+var parsimonysearch string
+var parsimonyradius int
+var parsimonyouttree string
+var parsimonyoutfile string
+
+// parsimonyCmd represents the parsimony command
+var parsimonyCmd = &cobra.Command{
+	Use:   "parsimony",
+	Short: "Computes parsimony scores of input trees and searches most parsimonious trees",
+	Long: `Computes parsimony scores of input trees given an alignment.
+
+The parsimony score is the number of steps computed by the UP-PASS of
+gotree asr (Fitch algorithm on binary nodes), summed over all sites.
+
+If --search nni or --search spr is given, then a hill climbing search is
+run from each input tree: at each iteration, all NNI (or SPR, with a
+maximum regraft radius given by --radius) rearrangements are evaluated,
+and the best one is applied, until no rearrangement improves the score.
+The most parsimonious trees found are written to --out-tree file
+(branch lengths are not meaningful).
+
+Output file (-o) is tab separated:
+tree  parsimony
+or, with --search:
+tree  initial  parsimony
+
+Example:
+gotree compute parsimony -i tree.nw -a align.fa
+gotree compute parsimony -i tree.nw -a align.fa --search spr --radius 5 --out-tree best.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var al align.Alignment
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var f, treef *os.File
+		var rearranger tree.Rearranger
+		var score, initscore int
+
+		switch strings.ToLower(parsimonysearch) {
+		case "none":
+			rearranger = nil
+		case "nni":
+			rearranger = &tree.NNIRearranger{}
+		case "spr":
+			rearranger = &tree.SPRRearranger{MaxRadius: parsimonyradius}
+		default:
+			err = fmt.Errorf("unknown search algorithm: %s", parsimonysearch)
+			io.LogError(err)
+			return
+		}
+
+		if al, err = readAlignment(inalignfile, parsimonyphylip, parsimonyinputstrict); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		if f, err = openWriteFile(parsimonyoutfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, parsimonyoutfile)
+
+		if rearranger != nil && parsimonyouttree != "none" {
+			if treef, err = openWriteFile(parsimonyouttree); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(treef, parsimonyouttree)
+		}
+
+		if rearranger == nil {
+			f.WriteString("tree\tparsimony\n")
+		} else {
+			f.WriteString("tree\tinitial\tparsimony\n")
+		}
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if initscore, err = asr.ParsimonyScore(t.Tree, al); err != nil {
+				io.LogError(err)
+				return
+			}
+			if rearranger == nil {
+				fmt.Fprintf(f, "%d\t%d\n", t.Id, initscore)
+				continue
+			}
+			if score, err = asr.ParsimonySearch(t.Tree, al, rearranger); err != nil {
+				io.LogError(err)
+				return
+			}
+			fmt.Fprintf(f, "%d\t%d\t%d\n", t.Id, initscore, score)
+			if treef != nil {
+				treef.WriteString(t.Tree.Newick() + "\n")
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	computeCmd.AddCommand(parsimonyCmd)
+	parsimonyCmd.PersistentFlags().StringVarP(&inalignfile, "align", "a", "stdin", "Alignment input file")
+	parsimonyCmd.PersistentFlags().BoolVarP(&parsimonyphylip, "phylip", "p", false, "Alignment is in phylip? default : false (Fasta)")
+	parsimonyCmd.PersistentFlags().BoolVar(&parsimonyinputstrict, "input-strict", false, "Strict phylip input format (only used with -p)")
+	parsimonyCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree(s)")
+	parsimonyCmd.PersistentFlags().StringVarP(&parsimonyoutfile, "output", "o", "stdout", "Parsimony score output file")
+	parsimonyCmd.PersistentFlags().StringVar(&parsimonysearch, "search", "none", "Tree search: none, nni, or spr")
+	parsimonyCmd.PersistentFlags().IntVar(&parsimonyradius, "radius", 0, "Maximum regraft radius of SPR search (0: no limit)")
+	parsimonyCmd.PersistentFlags().StringVar(&parsimonyouttree, "out-tree", "none", "Output file of the most parsimonious trees found (with --search)")
+}
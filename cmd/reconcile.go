@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var reconcilespeciesfile string
+var reconcilemapfile string
+var reconcileoutfile string
+var reconcilesummaryfile string
+
+// reconcileCmd represents the reconcile command
+var reconcileCmd = &cobra.Command{
+	Use:   "reconcile",
+	Short: "Reconciles gene trees with a species tree",
+	Long: `Reconciles gene trees with a species tree.
+
+Each input rooted gene tree (-i) is reconciled with the rooted species tree (-s)
+using the LCA mapping: each gene tree tip is mapped to the species tree tip of its
+species, and each internal gene tree node is mapped to the most recent common
+ancestor of the species of its children. An internal gene tree node is a
+duplication if it is mapped to the same species node as one of its children, and
+a speciation otherwise. Gene losses are deduced from the species tree nodes skipped
+along each gene tree branch.
+
+The species of gene tree tips are given in a tab separated map file (-m), with
+columns:
+1) Gene tree tip name
+2) Species name (name of a tip of the species tree)
+
+This is the same format as the map file of gotree rename. Gene tree tips that
+are not in the map file are considered to have the species of their own name.
+
+Output gene trees (-o) have a comment on each internal node: D for duplications
+and S for speciations.
+
+If --out-summary is given, it writes in the given file, for each gene tree, tab
+separated values with:
+1) The index of the gene tree in the input file
+2) The number of duplications
+3) The number of speciations
+4) The number of losses
+
+Example:
+gotree reconcile -i genes.nw -s species.nw -m map.txt -o reconciled.nw --out-summary summary.txt
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var species *tree.Tree
+		var speciesmap map[string]string = make(map[string]string)
+		var stats tree.ReconciliationStats
+		var f, summaryf *os.File
+
+		if reconcilespeciesfile == "none" {
+			err = errors.New("You must provide a species tree")
+			io.LogError(err)
+			return
+		}
+		if species, err = readTree(reconcilespeciesfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		if reconcilemapfile != "none" {
+			if speciesmap, err = readMapFile(reconcilemapfile, false); err != nil {
+				io.LogError(err)
+				return
+			}
+		}
+
+		if f, err = openWriteFile(reconcileoutfile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, reconcileoutfile)
+
+		if reconcilesummaryfile != "none" {
+			if summaryf, err = openWriteFile(reconcilesummaryfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(summaryf, reconcilesummaryfile)
+			fmt.Fprintf(summaryf, "tree\tduplications\tspeciations\tlosses\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if stats, err = tree.Reconcile(t.Tree, species, speciesmap); err != nil {
+				err = fmt.Errorf("Gene tree %d: %v", t.Id, err)
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if summaryf != nil {
+				fmt.Fprintf(summaryf, "%d\t%d\t%d\t%d\n", t.Id, stats.Duplications, stats.Speciations, stats.Losses)
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(reconcileCmd)
+	reconcileCmd.Flags().StringVarP(&intreefile, "input", "i", "stdin", "Input gene tree file")
+	reconcileCmd.Flags().StringVarP(&reconcilespeciesfile, "species", "s", "none", "Input species tree file")
+	reconcileCmd.Flags().StringVarP(&reconcilemapfile, "map", "m", "none", "Gene tip name to species map file")
+	reconcileCmd.Flags().StringVarP(&reconcileoutfile, "output", "o", "stdout", "Reconciled gene tree output file")
+	reconcileCmd.Flags().StringVar(&reconcilesummaryfile, "out-summary", "none", "Output file of the numbers of duplications, speciations and losses")
+}
@@ -7,8 +7,8 @@ import (
 // rerootCmd represents the reroot command
 var rerootCmd = &cobra.Command{
 	Use:   "reroot",
-	Short: "Reroot trees using an outgroup or at midpoint",
-	Long: `Reroot trees using an outgroup or at midpoint.
+	Short: "Reroot trees using an outgroup, at midpoint, or with MAD or MinVar",
+	Long: `Reroot trees using an outgroup, at midpoint, or with MAD or MinVar.
 `,
 }
 
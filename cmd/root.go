@@ -13,6 +13,9 @@ import (
 	"strings"
 	"time"
 
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/goalign/io/fasta"
+	"github.com/evolbioinfo/goalign/io/phylip"
 	"github.com/evolbioinfo/gotree/io/fileutils"
 	"github.com/evolbioinfo/gotree/io/utils"
 	"github.com/evolbioinfo/gotree/tree"
@@ -263,3 +266,21 @@ func readIdenticalGroupFile(file string) (groups [][]string, err error) {
 
 	return
 }
+
+// Reads an alignment in Fasta format, or in Phylip format
+// if phylip is true (strict: strict phylip format)
+func readAlignment(file string, phylipformat, strict bool) (al align.Alignment, err error) {
+	var fi goio.Closer
+	var r *bufio.Reader
+
+	if fi, r, err = utils.GetReader(file); err != nil {
+		return
+	}
+	defer fi.Close()
+	if phylipformat {
+		al, err = phylip.NewParser(r, strict).Parse()
+	} else {
+		al, err = fasta.NewParser(r).Parse()
+	}
+	return
+}
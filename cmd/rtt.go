@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	goio "io"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+var rttcriterion string
+var rttstatsfile string
+var rttresidualsfile string
+
+// rttCmd represents the dating rtt command
+var rttCmd = &cobra.Command{
+	Use:   "rtt",
+	Short: "Reroots trees using the root-to-tip regression",
+	Long: `Reroots trees using the root-to-tip regression.
+
+For each input tree, it searches the root position, over all the positions of
+all the branches, that gives the best regression of root-to-tip distances
+against tip dates (-d), as in TempEst. The criterion (--criterion) is either:
+- r2 : maximizes the coefficient of determination R² (among the positions
+       giving a positive clock rate, if any);
+- rms: minimizes the residual mean square.
+
+All tips must have a date, and all branch lengths must be defined.
+
+The trees, rerooted at the best position, are written in the output file (-o).
+
+If --out-stats is given, it writes in the given file, for each tree, tab
+separated values with:
+1) The index of the tree in the input file
+2) The clock rate (slope of the regression)
+3) The TMRCA (date at which the regression line reaches a distance of 0)
+4) The R² of the regression
+5) The residual mean square of the regression
+
+If --out-residuals is given, it writes in the given file, for each tip of each
+tree, tab separated values with:
+1) The index of the tree in the input file
+2) The name of the tip
+3) The date of the tip
+4) The root-to-tip distance of the tip
+5) The residual of the tip in the regression
+
+Example:
+gotree dating rtt -i tree.nw -d dates.txt -o rooted.nw --out-stats stats.txt --out-residuals residuals.txt
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var treefile goio.Closer
+		var treechan <-chan tree.Trees
+		var dates map[string]float64
+		var criterion int
+		var stats tree.RootToTipStats
+		var f, statsf, residualsf *os.File
+
+		switch rttcriterion {
+		case "r2":
+			criterion = tree.RTT_R2
+		case "rms":
+			criterion = tree.RTT_RMS
+		default:
+			err = fmt.Errorf("Unknown criterion: %s", rttcriterion)
+			io.LogError(err)
+			return
+		}
+
+		if datingdatefile == "none" {
+			err = errors.New("You must provide a tip date file")
+			io.LogError(err)
+			return
+		}
+		if dates, err = readDateFile(datingdatefile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+
+		if rttstatsfile != "none" {
+			if statsf, err = openWriteFile(rttstatsfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(statsf, rttstatsfile)
+			fmt.Fprintf(statsf, "tree\trate\ttmrca\tr2\trms\n")
+		}
+		if rttresidualsfile != "none" {
+			if residualsf, err = openWriteFile(rttresidualsfile); err != nil {
+				io.LogError(err)
+				return
+			}
+			defer closeWriteFile(residualsf, rttresidualsfile)
+			fmt.Fprintf(residualsf, "tree\ttip\tdate\tdistance\tresidual\n")
+		}
+
+		if treefile, treechan, err = readTrees(intreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer treefile.Close()
+
+		for t := range treechan {
+			if t.Err != nil {
+				err = t.Err
+				io.LogError(err)
+				return
+			}
+			if stats, err = t.Tree.RootToTipRegression(dates, criterion); err != nil {
+				io.LogError(err)
+				return
+			}
+			f.WriteString(t.Tree.Newick() + "\n")
+			if statsf != nil {
+				fmt.Fprintf(statsf, "%d\t%g\t%g\t%g\t%g\n", t.Id, stats.Rate, stats.TMRCA, stats.R2, stats.RMS)
+			}
+			if residualsf != nil {
+				for _, r := range stats.Residuals {
+					fmt.Fprintf(residualsf, "%d\t%s\t%g\t%g\t%g\n", t.Id, r.Name, r.Date, r.Distance, r.Residual)
+				}
+			}
+		}
+		return
+	},
+}
+
+func init() {
+	datingCmd.AddCommand(rttCmd)
+	rttCmd.Flags().StringVar(&rttcriterion, "criterion", "r2", "Regression criterion: r2 or rms")
+	rttCmd.Flags().StringVar(&rttstatsfile, "out-stats", "none", "Output file of the regression statistics")
+	rttCmd.Flags().StringVar(&rttresidualsfile, "out-residuals", "none", "Output file of the tip residuals")
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var sprradius int
+
+// sprCmd represents the spr command
+var sprCmd = &cobra.Command{
+	Use:   "spr",
+	Short: "Generates all SPR neighbors from a given tree",
+	Long: `Generates all SPR (Subtree Prune and Regraft) neighbors from a given tree.
+
+Each subtree attached to a node having 3 neighbors is pruned, and regrafted
+on every edge of the remaining tree located at most --radius edges away
+from the pruning point (--radius 0: no limit).
+
+The same topology may be output several times (e.g. SPRs of radius 1 are NNIs),
+except if --unique is given. If --count is given, only the number of distinct
+topologies is output (one line per input tree).
+
+Example:
+gotree spr -i tree.nw --radius 2
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if err = writeNeighbors(&tree.SPRRearranger{MaxRadius: sprradius}); err != nil {
+			io.LogError(err)
+		}
+		return
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(sprCmd)
+	sprCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input Tree")
+	sprCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "SPR output tree file")
+	sprCmd.PersistentFlags().BoolVar(&rearrangeunique, "unique", false, "Only output distinct topologies")
+	sprCmd.PersistentFlags().BoolVar(&rearrangecount, "count", false, "Only output the number of distinct topologies")
+	sprCmd.PersistentFlags().IntVar(&sprradius, "radius", 0, "Maximum regraft radius, in number of edges from the pruning point (0: no limit)")
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+)
+
+// supertreeCmd represents the supertree command
+var supertreeCmd = &cobra.Command{
+	Use:   "supertree",
+	Short: "Supertree construction from trees with overlapping sets of tips",
+	Long: `Supertree construction from trees with overlapping sets of tips.
+
+Input trees (-i) may have different, partially overlapping, sets of tips.
+`,
+}
+
+func init() {
+	RootCmd.AddCommand(supertreeCmd)
+	supertreeCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input tree file")
+}
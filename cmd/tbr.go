@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var tbrradius int
+
+// tbrCmd represents the tbr command
+var tbrCmd = &cobra.Command{
+	Use:   "tbr",
+	Short: "Generates all TBR neighbors from a given tree",
+	Long: `Generates all TBR (Tree Bisection and Reconnection) neighbors from a given tree.
+
+For each edge, the tree is bisected into two subtrees, which are reconnected
+by an edge joining any edge of the first subtree to any edge of the second
+subtree. Reconnection edges are located at most --radius edges away from the
+bisection point, on each side (--radius 0: no limit).
+
+The same topology may be output several times, except if --unique is given.
+If --count is given, only the number of distinct topologies is output (one
+line per input tree).
+
+Example:
+gotree tbr -i tree.nw --radius 2
+`,
+
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		if err = writeNeighbors(&tree.TBRRearranger{MaxRadius: tbrradius}); err != nil {
+			io.LogError(err)
+		}
+		return
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(tbrCmd)
+	tbrCmd.PersistentFlags().StringVarP(&intreefile, "input", "i", "stdin", "Input Tree")
+	tbrCmd.PersistentFlags().StringVarP(&outtreefile, "output", "o", "stdout", "TBR output tree file")
+	tbrCmd.PersistentFlags().BoolVar(&rearrangeunique, "unique", false, "Only output distinct topologies")
+	tbrCmd.PersistentFlags().BoolVar(&rearrangecount, "count", false, "Only output the number of distinct topologies")
+	tbrCmd.PersistentFlags().IntVar(&tbrradius, "radius", 0, "Maximum reconnection radius, in number of edges from the bisection point (0: no limit)")
+}
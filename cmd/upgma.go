@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io"
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
+	"github.com/spf13/cobra"
+)
+
+var upgmaalgo string
+
+// upgmaCmd represents the upgma command
+var upgmaCmd = &cobra.Command{
+	Use:   "upgma",
+	Short: "Infers a rooted ultrametric tree from a distance matrix using UPGMA or WPGMA",
+	Long: `Infers a rooted ultrametric tree from a distance matrix using UPGMA or WPGMA.
+
+--algo upgma: Unweighted Pair Group Method with Arithmetic mean
+--algo wpgma: Weighted Pair Group Method with Arithmetic mean
+
+The output tree is rooted, and the height of each internal node
+is half the distance between the two clusters it joins.
+
+Example:
+
+gotree infer upgma -i matrix.txt --algo wpgma > inferred.nw
+`,
+	RunE: func(cmd *cobra.Command, args []string) (err error) {
+		var f *os.File
+		var names []string
+		var matrix [][]float64
+		var t *tree.Tree
+
+		if names, matrix, err = utils.ReadDistanceMatrix(inmatrixfile); err != nil {
+			io.LogError(err)
+			return
+		}
+
+		switch strings.ToLower(upgmaalgo) {
+		case "upgma":
+			t, err = tree.UPGMA(names, matrix)
+		case "wpgma":
+			t, err = tree.WPGMA(names, matrix)
+		default:
+			err = fmt.Errorf("unknown clustering algorithm: %s", upgmaalgo)
+		}
+		if err != nil {
+			io.LogError(err)
+			return
+		}
+
+		if f, err = openWriteFile(outtreefile); err != nil {
+			io.LogError(err)
+			return
+		}
+		defer closeWriteFile(f, outtreefile)
+		f.WriteString(t.Newick() + "\n")
+		return
+	},
+}
+
+func init() {
+	inferCmd.AddCommand(upgmaCmd)
+	upgmaCmd.PersistentFlags().StringVar(&upgmaalgo, "algo", "upgma", "Clustering algorithm: upgma or wpgma")
+}
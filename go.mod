@@ -13,4 +13,5 @@ require (
 	github.com/llgcode/draw2d v0.0.0-20210313082411-577c1ead272a
 	github.com/spf13/cobra v1.1.3
 	golang.org/x/image v0.0.0-20210504121937-7319ad40d33e
+	gonum.org/v1/gonum v0.9.1-0.20210325102323-76f2be9ab53e
 )
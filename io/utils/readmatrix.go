@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/evolbioinfo/gotree/io/fileutils"
+)
+
+// Reads a distance matrix in PHYLIP format from the given file.
+//
+// See ReadDistanceMatrixReader for the accepted formats
+func ReadDistanceMatrix(inputfile string) (names []string, matrix [][]float64, err error) {
+	var f io.Closer
+	var r *bufio.Reader
+
+	if f, r, err = GetReader(inputfile); err != nil {
+		return
+	}
+	if names, matrix, err = ReadDistanceMatrixReader(r); err != nil {
+		f.Close()
+		return
+	}
+	err = f.Close()
+	return
+}
+
+// Reads a distance matrix in PHYLIP format from the given reader.
+//
+// The first line contains the number of taxa, and each following line
+// contains the name of a taxon followed by its distances (separated by
+// spaces or tabs). Three layouts are accepted:
+//	* Square: each line has n distances (same format as gotree matrix)
+//	* Lower triangular: line i has i distances (no diagonal)
+//	* Lower triangular with diagonal: line i has i+1 distances
+// The returned matrix is always square and symmetric.
+func ReadDistanceMatrixReader(r *bufio.Reader) (names []string, matrix [][]float64, err error) {
+	var line string
+	var n, nl int
+	var e error
+
+	// Skip empty lines before the number of taxa
+	for line, e = fileutils.Readln(r); e == nil && strings.TrimSpace(line) == ""; line, e = fileutils.Readln(r) {
+		nl++
+	}
+	if e != nil {
+		err = fmt.Errorf("Empty distance matrix")
+		return
+	}
+	nl++
+	if n, err = strconv.Atoi(strings.TrimSpace(line)); err != nil {
+		err = fmt.Errorf("Wrong number of taxa at line %d of the distance matrix: %s", nl, line)
+		return
+	}
+	if n <= 0 {
+		err = fmt.Errorf("Number of taxa in the distance matrix must be > 0")
+		return
+	}
+
+	names = make([]string, 0, n)
+	matrix = make([][]float64, n)
+	for i := range matrix {
+		matrix[i] = make([]float64, n)
+	}
+
+	// Number of distances on the first line: 0 (lower triangular),
+	// 1 (lower triangular with diagonal) or n (square)
+	// The layout is given by the first line
+	offset := -1
+	i := 0
+	for line, e = fileutils.Readln(r); e == nil && i < n; line, e = fileutils.Readln(r) {
+		nl++
+		cols := strings.Fields(line)
+		if len(cols) == 0 {
+			continue
+		}
+		names = append(names, cols[0])
+		values := cols[1:]
+		if offset == -1 {
+			if len(values) == n {
+				offset = n
+			} else if len(values) == 0 || len(values) == 1 {
+				offset = len(values)
+			}
+		}
+		if (offset == n && len(values) != n) || (offset != n && len(values) != i+offset) {
+			err = fmt.Errorf("Wrong number of distances at line %d of the distance matrix: %d", nl, len(values))
+			return
+		}
+		for j, v := range values {
+			var d float64
+			if d, err = strconv.ParseFloat(v, 64); err != nil {
+				err = fmt.Errorf("Wrong distance value at line %d of the distance matrix: %s", nl, v)
+				return
+			}
+			matrix[i][j] = d
+			// Lower triangular matrix: we fill the upper part
+			if offset != n {
+				matrix[j][i] = d
+			}
+		}
+		i++
+	}
+	if i != n {
+		err = fmt.Errorf("Distance matrix has %d rows, but %d taxa are declared", i, n)
+		return
+	}
+	return
+}
@@ -0,0 +1,384 @@
+package likelihood
+
+import (
+	"fmt"
+	"math"
+	"unicode"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+const (
+	// Conditional likelihoods are rescaled when their
+	// maximum value falls below this threshold
+	SCALE_THRESHOLD = 1e-100
+)
+
+// Computes the likelihood of a tree given an alignment and a substitution model,
+// using the Felsenstein pruning algorithm.
+//
+// Conditional likelihood vectors are stored for both directions of each edge,
+// so that the likelihood may be computed at any edge, and are recomputed only
+// when needed: if branch lengths of the tree are modified, only the affected
+// vectors are recomputed. The topology of the tree must not be modified after
+// the construction of the Likelihood.
+type Likelihood struct {
+	t       *tree.Tree
+	m       *Model
+	ns      int         // Number of states
+	ncat    int         // Number of rate categories
+	npat    int         // Number of site patterns
+	weights []float64   // Number of sites of each pattern
+	sites   []int       // Pattern index of each site of the alignment
+	tips    [][]float64 // Conditional likelihoods of each tip (indexed by node id): npat*ns
+	edges   []*tree.Edge
+	lengths []float64      // Branch length used to compute transition matrices of each edge
+	pmats   [][]float64    // Transition matrices of each edge: ncat*ns*ns
+	partial [][2][]float64 // Conditional likelihoods of each edge, at the left (0) and right (1) node: npat*ncat*ns
+	scalers [][2][]float64 // Log scaling factors of partials of each edge: npat
+	valid   [][2]bool      // Whether partials are up to date
+	tmppmat []float64      // Transition matrices for trial branch lengths
+}
+
+// Computes the log likelihood of the tree given the alignment and the model.
+//
+// All branch lengths of the tree must be defined, and all
+// tips of the tree must be present in the alignment.
+func LogLikelihood(t *tree.Tree, a align.Alignment, m *Model) (lnl float64, err error) {
+	var l *Likelihood
+	if l, err = NewLikelihood(t, a, m); err != nil {
+		return
+	}
+	lnl = l.LogLikelihood()
+	return
+}
+
+// Initializes a likelihood computation for the tree, the alignment and the model.
+//
+// All branch lengths of the tree must be defined, and all tips of the tree must be
+// present in the alignment. Node and edge ids of the tree are modified.
+func NewLikelihood(t *tree.Tree, a align.Alignment, m *Model) (l *Likelihood, err error) {
+	var nodes []*tree.Node
+	var tipseqs [][]uint8
+	var tipnodes []*tree.Node
+	var patterns map[string]int
+	var key []byte
+	var indices map[uint8]int
+
+	if a.Alphabet() != m.Alphabet() {
+		err = fmt.Errorf("alphabet of the alignment does not correspond to the substitution model")
+		return
+	}
+
+	nodes = t.Nodes()
+	l = &Likelihood{
+		t:       t,
+		m:       m,
+		ns:      m.NState(),
+		ncat:    m.NCat(),
+		weights: make([]float64, 0),
+		sites:   make([]int, a.Length()),
+		tips:    make([][]float64, len(nodes)),
+		edges:   t.Edges(),
+	}
+
+	for i, n := range nodes {
+		n.SetId(i)
+		if n.Tip() {
+			seq, ok := a.GetSequenceChar(n.Name())
+			if !ok {
+				err = fmt.Errorf("sequence %s does not exist in the alignment", n.Name())
+				return
+			}
+			tipseqs = append(tipseqs, seq)
+			tipnodes = append(tipnodes, n)
+		}
+	}
+
+	// Identical sites are grouped into weighted patterns
+	indices = stateIndices(a)
+	patterns = make(map[string]int)
+	key = make([]byte, len(tipseqs))
+	for j := 0; j < a.Length(); j++ {
+		for i, seq := range tipseqs {
+			key[i] = seq[j]
+		}
+		p, ok := patterns[string(key)]
+		if !ok {
+			p = len(l.weights)
+			patterns[string(key)] = p
+			l.weights = append(l.weights, 0)
+			for i, n := range tipnodes {
+				l.tips[n.Id()] = append(l.tips[n.Id()], l.tipLikelihoods(a, indices, tipseqs[i][j])...)
+			}
+		}
+		l.weights[p]++
+		l.sites[j] = p
+	}
+	l.npat = len(l.weights)
+
+	l.lengths = make([]float64, len(l.edges))
+	l.pmats = make([][]float64, len(l.edges))
+	l.partial = make([][2][]float64, len(l.edges))
+	l.scalers = make([][2][]float64, len(l.edges))
+	l.valid = make([][2]bool, len(l.edges))
+	l.tmppmat = make([]float64, l.ncat*l.ns*l.ns)
+	for i, e := range l.edges {
+		if e.Length() == tree.NIL_LENGTH {
+			err = fmt.Errorf("all branch lengths must be defined to compute the likelihood")
+			return
+		}
+		if e.Length() < 0 {
+			err = fmt.Errorf("negative branch lengths are not allowed to compute the likelihood: %f", e.Length())
+			return
+		}
+		e.SetId(i)
+		l.pmats[i] = make([]float64, l.ncat*l.ns*l.ns)
+		l.lengths[i] = e.Length()
+		l.updateTransitionMatrices(e)
+		for side := 0; side < 2; side++ {
+			l.partial[i][side] = make([]float64, l.npat*l.ncat*l.ns)
+			l.scalers[i][side] = make([]float64, l.npat)
+		}
+	}
+	return
+}
+
+// Returns the conditional likelihoods of a tip having the given character:
+// 1 for all the states compatible with the character (IUPAC codes for nucleotides,
+// B, Z and J for amino-acids), and 1 for all the states for gaps and unknown characters.
+func (l *Likelihood) tipLikelihoods(a align.Alignment, indices map[uint8]int, c uint8) (v []float64) {
+	v = make([]float64, l.ns)
+	if idx, ok := indices[c]; ok {
+		v[idx] = 1.0
+		return
+	}
+	var possibilities []uint8
+	if a.Alphabet() == align.NUCLEOTIDS {
+		possibilities = align.IupacCode[uint8(unicode.ToUpper(rune(c)))]
+	} else {
+		switch c {
+		case 'B', 'b':
+			possibilities = []uint8{'N', 'D'}
+		case 'Z', 'z':
+			possibilities = []uint8{'Q', 'E'}
+		case 'J', 'j':
+			possibilities = []uint8{'I', 'L'}
+		}
+	}
+	for _, c2 := range possibilities {
+		if idx, ok := indices[c2]; ok {
+			v[idx] = 1.0
+		}
+	}
+	for _, p := range v {
+		if p > 0 {
+			return
+		}
+	}
+	for i := range v {
+		v[i] = 1.0
+	}
+	return
+}
+
+// Returns the log likelihood of the tree, taking into account
+// the current branch lengths of the tree.
+func (l *Likelihood) LogLikelihood() float64 {
+	l.update()
+	return l.edgeLogLikelihood(l.edges[0], l.edges[0].Length())
+}
+
+// Returns the number of site patterns
+func (l *Likelihood) NPatterns() int {
+	return l.npat
+}
+
+// Checks branch lengths of the tree, and updates transition matrices
+// and invalidates partials of edges whose length has been modified
+func (l *Likelihood) update() {
+	for i, e := range l.edges {
+		if e.Length() != l.lengths[i] {
+			l.setLength(e, e.Length())
+		}
+	}
+}
+
+// Sets the length used to compute the likelihood for the given edge,
+// and invalidates the conditional likelihoods that depend on it.
+//
+// The length of the edge in the tree is not modified.
+func (l *Likelihood) setLength(e *tree.Edge, length float64) {
+	if length == l.lengths[e.Id()] {
+		return
+	}
+	l.lengths[e.Id()] = length
+	l.updateTransitionMatrices(e)
+	l.invalidate(e.Left(), e)
+	l.invalidate(e.Right(), e)
+}
+
+// Invalidates the partials of all edges, at the side of node cur, for which
+// the subtree at the side of cur contains the edge prev
+func (l *Likelihood) invalidate(cur *tree.Node, prev *tree.Edge) {
+	for _, e := range cur.Edges() {
+		if e == prev {
+			continue
+		}
+		side, next := sideOf(e, cur)
+		if !l.valid[e.Id()][side] {
+			// Partials further away are already invalid
+			continue
+		}
+		l.valid[e.Id()][side] = false
+		l.invalidate(next, e)
+	}
+}
+
+// Returns the side index of node n on edge e (0: left, 1: right),
+// and the node at the other side of the edge
+func sideOf(e *tree.Edge, n *tree.Node) (side int, other *tree.Node) {
+	if e.Left() == n {
+		return 0, e.Right()
+	}
+	return 1, e.Left()
+}
+
+func (l *Likelihood) updateTransitionMatrices(e *tree.Edge) {
+	ns2 := l.ns * l.ns
+	for c, r := range l.m.rates {
+		l.m.transitionMatrix(r*l.lengths[e.Id()], l.pmats[e.Id()][c*ns2:(c+1)*ns2])
+	}
+}
+
+// Returns the conditional likelihoods (and their log scalers) of the
+// subtree at the given side of the edge, computing them if needed
+func (l *Likelihood) partials(e *tree.Edge, side int) (partial, scaler []float64) {
+	partial, scaler = l.partial[e.Id()][side], l.scalers[e.Id()][side]
+	if l.valid[e.Id()][side] {
+		return
+	}
+
+	var cur *tree.Node
+	if side == 0 {
+		cur = e.Left()
+	} else {
+		cur = e.Right()
+	}
+
+	block := l.ncat * l.ns
+	if cur.Tip() {
+		tip := l.tips[cur.Id()]
+		for p := 0; p < l.npat; p++ {
+			for c := 0; c < l.ncat; c++ {
+				copy(partial[p*block+c*l.ns:p*block+(c+1)*l.ns], tip[p*l.ns:(p+1)*l.ns])
+			}
+			scaler[p] = 0
+		}
+		l.valid[e.Id()][side] = true
+		return
+	}
+
+	for i := range partial {
+		partial[i] = 1.0
+	}
+	for p := range scaler {
+		scaler[p] = 0
+	}
+	for _, f := range cur.Edges() {
+		if f == e {
+			continue
+		}
+		fside, _ := sideOf(f, cur)
+		child, childscaler := l.partials(f, 1-fside)
+		l.multiplyPartial(partial, child, l.pmats[f.Id()])
+		for p := range scaler {
+			scaler[p] += childscaler[p]
+		}
+	}
+	l.scale(partial, scaler)
+	l.valid[e.Id()][side] = true
+	return
+}
+
+// Multiplies partial by the conditional likelihoods of the child
+// subtree, propagated along a branch with the given transition matrices
+func (l *Likelihood) multiplyPartial(partial, child, pmat []float64) {
+	ns, ns2, block := l.ns, l.ns*l.ns, l.ncat*l.ns
+	for p := 0; p < l.npat; p++ {
+		for c := 0; c < l.ncat; c++ {
+			v := partial[p*block+c*ns : p*block+(c+1)*ns]
+			cv := child[p*block+c*ns : p*block+(c+1)*ns]
+			pm := pmat[c*ns2 : (c+1)*ns2]
+			for i := 0; i < ns; i++ {
+				s := 0.0
+				for j := 0; j < ns; j++ {
+					s += pm[i*ns+j] * cv[j]
+				}
+				v[i] *= s
+			}
+		}
+	}
+}
+
+// Rescales the conditional likelihoods of each pattern if they are too small
+func (l *Likelihood) scale(partial, scaler []float64) {
+	block := l.ncat * l.ns
+	for p := 0; p < l.npat; p++ {
+		v := partial[p*block : (p+1)*block]
+		max := 0.0
+		for _, x := range v {
+			if x > max {
+				max = x
+			}
+		}
+		if max > 0 && max < SCALE_THRESHOLD {
+			for i := range v {
+				v[i] /= max
+			}
+			scaler[p] += math.Log(max)
+		}
+	}
+}
+
+// Computes the log likelihood of the tree at the given edge,
+// with the given length for this edge
+func (l *Likelihood) edgeLogLikelihood(e *tree.Edge, length float64) (lnl float64) {
+	var pmat []float64
+	ns, ns2, block := l.ns, l.ns*l.ns, l.ncat*l.ns
+
+	left, leftscaler := l.partials(e, 0)
+	right, rightscaler := l.partials(e, 1)
+
+	if length == l.lengths[e.Id()] {
+		pmat = l.pmats[e.Id()]
+	} else {
+		pmat = l.tmppmat
+		for c, r := range l.m.rates {
+			l.m.transitionMatrix(r*length, pmat[c*ns2:(c+1)*ns2])
+		}
+	}
+
+	for p := 0; p < l.npat; p++ {
+		site := 0.0
+		for c := 0; c < l.ncat; c++ {
+			lv := left[p*block+c*ns : p*block+(c+1)*ns]
+			rv := right[p*block+c*ns : p*block+(c+1)*ns]
+			pm := pmat[c*ns2 : (c+1)*ns2]
+			for i := 0; i < ns; i++ {
+				if lv[i] == 0 {
+					continue
+				}
+				s := 0.0
+				for j := 0; j < ns; j++ {
+					s += pm[i*ns+j] * rv[j]
+				}
+				site += l.m.pi[i] * lv[i] * s
+			}
+		}
+		site /= float64(l.ncat)
+		lnl += l.weights[p] * (math.Log(site) + leftscaler[p] + rightscaler[p])
+	}
+	return
+}
@@ -0,0 +1,394 @@
+package likelihood
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/goalign/models"
+	"github.com/evolbioinfo/goalign/models/dna"
+	"github.com/evolbioinfo/goalign/models/protein"
+	"gonum.org/v1/gonum/mathext"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+const (
+	MODEL_JC69 = iota
+	MODEL_K80
+	MODEL_HKY
+	MODEL_GTR
+	MODEL_DAYHOFF
+	MODEL_JTT
+	MODEL_MTREV
+	MODEL_LG
+	MODEL_WAG
+	MODEL_HIVB
+
+	// Minimum value of transition probabilities
+	DBL_MIN = 2.2250738585072014e-308
+)
+
+// Substitution model used to compute likelihoods:
+// a time reversible model of character substitution (nucleotides or amino-acids),
+// with optional discrete gamma rate heterogeneity across sites.
+//
+// Substitution matrices are normalized such that branch lengths
+// are expressed in expected number of substitutions per site.
+type Model struct {
+	model    int          // Model code (MODEL_JC69, etc.)
+	alphabet int          // align.NUCLEOTIDS or align.AMINOACIDS
+	ns       int          // Number of states
+	sub      models.Model // Underlying substitution model
+	pi       []float64    // Equilibrium frequencies
+	val      []float64    // Eigen values of the substitution matrix
+	left     []float64    // Left eigen vectors (ns*ns, row-major)
+	right    []float64    // Right eigen vectors (ns*ns, row-major)
+	alpha    float64      // Gamma shape parameter (if ncat > 1)
+	rates    []float64    // Rate of each gamma category ({1.0} without gamma)
+}
+
+// Returns the code of the model given its name:
+// jc69 (or jc), k80 (or k2p), hky, gtr, dayhoff, jtt, mtrev, lg, wag or hivb.
+// If the model does not exist, returns -1
+func ModelStringToInt(model string) int {
+	switch strings.ToLower(model) {
+	case "jc69", "jc":
+		return MODEL_JC69
+	case "k80", "k2p":
+		return MODEL_K80
+	case "hky", "hky85":
+		return MODEL_HKY
+	case "gtr":
+		return MODEL_GTR
+	case "dayhoff":
+		return MODEL_DAYHOFF
+	case "jtt":
+		return MODEL_JTT
+	case "mtrev":
+		return MODEL_MTREV
+	case "lg":
+		return MODEL_LG
+	case "wag":
+		return MODEL_WAG
+	case "hivb":
+		return MODEL_HIVB
+	default:
+		return -1
+	}
+}
+
+// Returns true if the given model code corresponds to a nucleotide model
+func IsNucleotideModel(model int) bool {
+	return model == MODEL_JC69 || model == MODEL_K80 || model == MODEL_HKY || model == MODEL_GTR
+}
+
+// Initializes a new Jukes-Cantor (1969) model
+func NewJC69Model() (m *Model, err error) {
+	sub := dna.NewJCModel()
+	if err = sub.InitModel(); err != nil {
+		return
+	}
+	return newModel(MODEL_JC69, align.NUCLEOTIDS, sub, []float64{0.25, 0.25, 0.25, 0.25})
+}
+
+// Initializes a new Kimura (1980) model, with the given
+// transition/transversion rate ratio kappa (kappa=1: JC69)
+func NewK80Model(kappa float64) (m *Model, err error) {
+	if kappa <= 0 {
+		err = fmt.Errorf("kappa must be > 0: %f", kappa)
+		return
+	}
+	sub := dna.NewK2PModel()
+	sub.InitModel(kappa)
+	return newModel(MODEL_K80, align.NUCLEOTIDS, sub, []float64{0.25, 0.25, 0.25, 0.25})
+}
+
+// Initializes a new Hasegawa-Kishino-Yano (1985) model, with the given
+// transition/transversion rate ratio kappa and the equilibrium
+// frequencies of A, C, G and T
+func NewHKYModel(kappa float64, pi []float64) (m *Model, err error) {
+	if kappa <= 0 {
+		err = fmt.Errorf("kappa must be > 0: %f", kappa)
+		return
+	}
+	if pi, err = normalizeFrequencies(pi, 4); err != nil {
+		return
+	}
+	sub := dna.NewTN93Model()
+	if err = sub.InitModel(kappa, kappa, pi[0], pi[1], pi[2], pi[3]); err != nil {
+		return
+	}
+	return newModel(MODEL_HKY, align.NUCLEOTIDS, sub, pi)
+}
+
+// Initializes a new General Time Reversible model, with the given relative
+// substitution rates: A<->C, A<->G, A<->T, C<->G, C<->T and G<->T, and the
+// equilibrium frequencies of A, C, G and T
+func NewGTRModel(rates []float64, pi []float64) (m *Model, err error) {
+	if len(rates) != 6 {
+		err = fmt.Errorf("GTR model needs 6 substitution rates, %d given", len(rates))
+		return
+	}
+	for _, r := range rates {
+		if r < 0 {
+			err = fmt.Errorf("GTR substitution rates must be >= 0: %f", r)
+			return
+		}
+	}
+	if pi, err = normalizeFrequencies(pi, 4); err != nil {
+		return
+	}
+	sub := dna.NewGTRModel()
+	if err = sub.InitModel(rates[0], rates[1], rates[2], rates[3], rates[4], rates[5], pi[0], pi[1], pi[2], pi[3]); err != nil {
+		return
+	}
+	return newModel(MODEL_GTR, align.NUCLEOTIDS, sub, pi)
+}
+
+// Initializes a new empirical amino-acid model: MODEL_DAYHOFF, MODEL_JTT,
+// MODEL_MTREV, MODEL_LG, MODEL_WAG or MODEL_HIVB.
+//
+// If pi is nil, then the equilibrium frequencies of the model are used,
+// otherwise the given frequencies are used (in the order of
+// align.AlphabetCharacters(): A R N D C Q E G H I L K M F P S T W Y V).
+func NewProteinModel(model int, pi []float64) (m *Model, err error) {
+	var protmodel int
+	var sub *protein.ProtModel
+
+	switch model {
+	case MODEL_DAYHOFF:
+		protmodel = protein.MODEL_DAYHOFF
+	case MODEL_JTT:
+		protmodel = protein.MODEL_JTT
+	case MODEL_MTREV:
+		protmodel = protein.MODEL_MTREV
+	case MODEL_LG:
+		protmodel = protein.MODEL_LG
+	case MODEL_WAG:
+		protmodel = protein.MODEL_WAG
+	case MODEL_HIVB:
+		protmodel = protein.MODEL_HIVB
+	default:
+		err = fmt.Errorf("unknown protein model")
+		return
+	}
+	if pi != nil {
+		if pi, err = normalizeFrequencies(pi, 20); err != nil {
+			return
+		}
+	}
+	if sub, err = protein.NewProtModel(protmodel, false, 1.0); err != nil {
+		return
+	}
+	if err = sub.InitModel(pi); err != nil {
+		return
+	}
+	pi = make([]float64, 20)
+	for i := range pi {
+		pi[i] = sub.Pi(i)
+	}
+	if pi, err = normalizeFrequencies(pi, 20); err != nil {
+		return
+	}
+	return newModel(model, align.AMINOACIDS, sub, pi)
+}
+
+func newModel(model, alphabet int, sub models.Model, pi []float64) (m *Model, err error) {
+	ns := sub.NState()
+	m = &Model{
+		model:    model,
+		alphabet: alphabet,
+		ns:       ns,
+		sub:      sub,
+		pi:       pi,
+		left:     make([]float64, ns*ns),
+		right:    make([]float64, ns*ns),
+		alpha:    -1,
+		rates:    []float64{1.0},
+	}
+
+	if !sub.Analytical() {
+		val, left, right, err2 := sub.Eigens()
+		if err = err2; err != nil {
+			return
+		}
+		m.val = val
+		for i := 0; i < ns; i++ {
+			for j := 0; j < ns; j++ {
+				m.left[i*ns+j] = left.At(i, j)
+				m.right[i*ns+j] = right.At(i, j)
+			}
+		}
+	}
+	return
+}
+
+// Adds discrete gamma rate heterogeneity across sites to the model,
+// with the given shape parameter alpha and ncat categories.
+//
+// If ncat < 2, then rate heterogeneity is removed.
+func (m *Model) SetGamma(alpha float64, ncat int) (err error) {
+	if ncat < 2 {
+		m.alpha = -1
+		m.rates = []float64{1.0}
+		return
+	}
+	if alpha <= 0 {
+		err = fmt.Errorf("gamma alpha parameter must be > 0: %f", alpha)
+		return
+	}
+	m.alpha = alpha
+	m.rates = discreteGamma(alpha, ncat)
+	return
+}
+
+// Computes the mean rate of each of the ncat categories of equal probability
+// of a gamma distribution with shape alpha and mean 1 (Yang, 1994).
+//
+// models.DiscreteGamma is not used because it is not numerically
+// stable for large values of alpha.
+func discreteGamma(alpha float64, ncat int) (rates []float64) {
+	var prev, cur float64
+	g := distuv.Gamma{Alpha: alpha, Beta: alpha}
+	rates = make([]float64, ncat)
+	for i := 0; i < ncat; i++ {
+		cur = 1.0
+		if i < ncat-1 {
+			// Mean rate of the category is computed using the
+			// incomplete gamma function of parameter alpha+1
+			cur = mathext.GammaIncReg(alpha+1, g.Quantile(float64(i+1)/float64(ncat))*alpha)
+		}
+		rates[i] = (cur - prev) * float64(ncat)
+		prev = cur
+	}
+	return
+}
+
+// Returns the model code (MODEL_JC69, etc.)
+func (m *Model) Model() int {
+	return m.model
+}
+
+// Returns the alphabet of the model: align.NUCLEOTIDS or align.AMINOACIDS
+func (m *Model) Alphabet() int {
+	return m.alphabet
+}
+
+// Returns the number of states of the model
+func (m *Model) NState() int {
+	return m.ns
+}
+
+// Returns the equilibrium frequency of the ith state
+func (m *Model) Pi(i int) float64 {
+	return m.pi[i]
+}
+
+// Returns the gamma alpha parameter, or -1 if there is
+// no rate heterogeneity
+func (m *Model) Alpha() float64 {
+	return m.alpha
+}
+
+// Returns the number of rate categories (1 if no gamma)
+func (m *Model) NCat() int {
+	return len(m.rates)
+}
+
+// Fills p (of size NState()*NState(), row-major) with the
+// transition probabilities P(i->j) along a branch of length l
+func (m *Model) transitionMatrix(l float64, p []float64) {
+	ns := m.ns
+	if m.sub.Analytical() {
+		for i := 0; i < ns; i++ {
+			for j := 0; j < ns; j++ {
+				p[i*ns+j] = m.sub.Pij(i, j, l)
+			}
+		}
+		return
+	}
+	expt := make([]float64, ns)
+	for k := 0; k < ns; k++ {
+		expt[k] = math.Exp(m.val[k] * l)
+	}
+	for i := 0; i < ns; i++ {
+		for j := 0; j < ns; j++ {
+			v := 0.0
+			for k := 0; k < ns; k++ {
+				v += m.right[i*ns+k] * expt[k] * m.left[k*ns+j]
+			}
+			if v < DBL_MIN {
+				v = DBL_MIN
+			}
+			p[i*ns+j] = v
+		}
+	}
+}
+
+// Computes the frequencies of each character of the alphabet
+// in the alignment (in the order of AlphabetCharacters()).
+//
+// Ambiguous characters, gaps and unknown characters are not taken
+// into account. If no character is counted, then frequencies are equal.
+func EmpiricalFrequencies(a align.Alignment) (pi []float64) {
+	var total float64
+	var indices map[uint8]int = stateIndices(a)
+	ns := len(a.AlphabetCharacters())
+	pi = make([]float64, ns)
+	a.IterateChar(func(name string, seq []uint8) bool {
+		for _, c := range seq {
+			if idx, ok := indices[c]; ok {
+				pi[idx]++
+				total++
+			}
+		}
+		return false
+	})
+	for i := range pi {
+		if total == 0 {
+			pi[i] = 1.0 / float64(ns)
+		} else {
+			pi[i] /= total
+		}
+	}
+	return
+}
+
+// Checks that the frequencies are valid (ns positive values), and
+// returns normalized frequencies (summing to 1)
+func normalizeFrequencies(pi []float64, ns int) (norm []float64, err error) {
+	var sum float64
+	if len(pi) != ns {
+		err = fmt.Errorf("%d frequencies are expected, %d given", ns, len(pi))
+		return
+	}
+	for _, f := range pi {
+		if f < 0 {
+			err = fmt.Errorf("frequencies must be >= 0: %f", f)
+			return
+		}
+		sum += f
+	}
+	if sum == 0 {
+		err = fmt.Errorf("sum of frequencies must be > 0")
+		return
+	}
+	norm = make([]float64, ns)
+	for i, f := range pi {
+		norm[i] = f / sum
+	}
+	return
+}
+
+// Returns the index of each character of the alphabet of the alignment
+// (upper and lower case), in the order of AlphabetCharacters()
+func stateIndices(a align.Alignment) (indices map[uint8]int) {
+	indices = make(map[uint8]int)
+	for i, c := range a.AlphabetCharacters() {
+		indices[c] = i
+		indices[uint8(unicode.ToLower(rune(c)))] = i
+	}
+	return
+}
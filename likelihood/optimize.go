@@ -0,0 +1,186 @@
+package likelihood
+
+import (
+	"math"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+const (
+	BL_MIN = 1.e-08 // Minimum branch length during optimization
+	BL_MAX = 100.0  // Maximum branch length during optimization
+
+	// Default initial length of branches without length
+	BL_INIT = 0.1
+)
+
+// Optimizes all the branch lengths of the tree by maximum likelihood, given the
+// alignment and the substitution model. The topology of the tree is not modified.
+//
+// Each branch length is optimized in turn using Brent's method, and rounds of
+// optimization over all branches are iterated until the log likelihood improves
+// by less than epsilon, or until maxiter rounds (if maxiter > 0).
+//
+// Branch lengths are first initialized with InitBranchLengths. The branch lengths
+// of the tree are updated in place, and the final log likelihood is returned.
+func OptimizeBranchLengths(t *tree.Tree, a align.Alignment, m *Model, maxiter int, epsilon float64) (lnl float64, err error) {
+	var l *Likelihood
+
+	InitBranchLengths(t)
+	if l, err = NewLikelihood(t, a, m); err != nil {
+		return
+	}
+	lnl = l.OptimizeBranchLengths(maxiter, epsilon)
+	return
+}
+
+// Prepares branch lengths of the tree for optimization: undefined branch
+// lengths are set to BL_INIT, and branch lengths outside [BL_MIN,BL_MAX]
+// are set to the closest bound.
+func InitBranchLengths(t *tree.Tree) {
+	for _, e := range t.Edges() {
+		if e.Length() == tree.NIL_LENGTH {
+			e.SetLength(BL_INIT)
+		} else if e.Length() < BL_MIN {
+			e.SetLength(BL_MIN)
+		} else if e.Length() > BL_MAX {
+			e.SetLength(BL_MAX)
+		}
+	}
+}
+
+// Optimizes all the branch lengths of the tree, as described in the
+// OptimizeBranchLengths function, and returns the final log likelihood.
+//
+// Branch lengths of the tree are updated in place. They should be
+// in [BL_MIN,BL_MAX] (see InitBranchLengths).
+func (l *Likelihood) OptimizeBranchLengths(maxiter int, epsilon float64) (lnl float64) {
+	lnl = l.LogLikelihood()
+	for iter := 0; maxiter <= 0 || iter < maxiter; iter++ {
+		prev := lnl
+		for _, e := range l.edges {
+			lnl = l.optimizeEdge(e)
+		}
+		if lnl-prev < epsilon {
+			break
+		}
+	}
+	return
+}
+
+// Optimizes the length of the given edge, the other branch lengths
+// being fixed, and returns the log likelihood with the new length.
+func (l *Likelihood) optimizeEdge(e *tree.Edge) (lnl float64) {
+	var x, fx float64
+	var f func(length float64) float64 = func(length float64) float64 {
+		return -l.edgeLogLikelihood(e, length)
+	}
+
+	cur := l.lengths[e.Id()]
+	fcur := f(cur)
+	upper := math.Min(BL_MAX, math.Max(10*cur, 1.0))
+	for {
+		x, fx = brent(f, BL_MIN, upper, cur, 1.e-6)
+		// The optimum may be larger than the upper bound
+		if upper >= BL_MAX || x < upper*0.99 {
+			break
+		}
+		upper = math.Min(BL_MAX, upper*10)
+	}
+
+	if fx > fcur {
+		// No improvement
+		x, fx = cur, fcur
+	}
+	l.setLength(e, x)
+	e.SetLength(x)
+	return -fx
+}
+
+// Searches the minimum of the function f on the interval [a,b] using Brent's
+// method (golden section search and successive parabolic interpolation),
+// starting from x0, with the given relative tolerance.
+//
+// Adapted from R. P. Brent, Algorithms for Minimization without
+// Derivatives (1973).
+func brent(f func(float64) float64, a, b, x0, tol float64) (x, fx float64) {
+	const cgold = 0.3819660112501051
+	const zeps = 1.0e-10
+	var d, e float64
+
+	if x0 <= a || x0 >= b {
+		x0 = a + cgold*(b-a)
+	}
+	x = x0
+	w, v := x, x
+	fx = f(x)
+	fw, fv := fx, fx
+
+	for iter := 0; iter < 100; iter++ {
+		xm := 0.5 * (a + b)
+		tol1 := tol*math.Abs(x) + zeps
+		tol2 := 2.0 * tol1
+		if math.Abs(x-xm) <= tol2-0.5*(b-a) {
+			break
+		}
+		golden := true
+		if math.Abs(e) > tol1 {
+			// Parabolic interpolation
+			r := (x - w) * (fx - fv)
+			q := (x - v) * (fx - fw)
+			p := (x-v)*q - (x-w)*r
+			q = 2.0 * (q - r)
+			if q > 0.0 {
+				p = -p
+			}
+			q = math.Abs(q)
+			etemp := e
+			e = d
+			if math.Abs(p) < math.Abs(0.5*q*etemp) && p > q*(a-x) && p < q*(b-x) {
+				d = p / q
+				u := x + d
+				if u-a < tol2 || b-u < tol2 {
+					d = math.Copysign(tol1, xm-x)
+				}
+				golden = false
+			}
+		}
+		if golden {
+			if x >= xm {
+				e = a - x
+			} else {
+				e = b - x
+			}
+			d = cgold * e
+		}
+		u := x + d
+		if math.Abs(d) < tol1 {
+			u = x + math.Copysign(tol1, d)
+		}
+		fu := f(u)
+		if fu <= fx {
+			if u >= x {
+				a = x
+			} else {
+				b = x
+			}
+			v, w, x = w, x, u
+			fv, fw, fx = fw, fx, fu
+		} else {
+			if u < x {
+				a = u
+			} else {
+				b = u
+			}
+			if fu <= fw || w == x {
+				v, w = w, u
+				fv, fw = fw, fu
+			} else if fu <= fv || v == x || v == w {
+				v = u
+				fv = fu
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,64 @@
+package likelihood
+
+import (
+	"fmt"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Computes the marginal posterior probabilities of each state at the given node
+// of the tree, for each site of the alignment, taking into account the current
+// branch lengths of the tree.
+//
+// Returns a [nsites][nstates] slice, states being in the order of
+// AlphabetCharacters() of the alignment. Sites having the same pattern
+// share the same underlying slice of probabilities.
+func (l *Likelihood) NodePosteriors(n *tree.Node) (posteriors [][]float64, err error) {
+	var patposts [][]float64
+
+	if n.Nneigh() == 0 {
+		err = fmt.Errorf("cannot compute posteriors of a node without neighbor")
+		return
+	}
+	l.update()
+
+	// Posteriors are computed at the first edge of the node, given the
+	// conditional likelihoods of both sides of the edge
+	ns, ns2, block := l.ns, l.ns*l.ns, l.ncat*l.ns
+	e := n.Edges()[0]
+	side, _ := sideOf(e, n)
+	cur, _ := l.partials(e, side)
+	other, _ := l.partials(e, 1-side)
+	pmat := l.pmats[e.Id()]
+
+	patposts = make([][]float64, l.npat)
+	for p := 0; p < l.npat; p++ {
+		post := make([]float64, ns)
+		total := 0.0
+		for c := 0; c < l.ncat; c++ {
+			cv := cur[p*block+c*ns : p*block+(c+1)*ns]
+			ov := other[p*block+c*ns : p*block+(c+1)*ns]
+			pm := pmat[c*ns2 : (c+1)*ns2]
+			for i := 0; i < ns; i++ {
+				s := 0.0
+				for j := 0; j < ns; j++ {
+					s += pm[i*ns+j] * ov[j]
+				}
+				post[i] += l.m.pi[i] * cv[i] * s
+			}
+		}
+		for _, v := range post {
+			total += v
+		}
+		for i := range post {
+			post[i] /= total
+		}
+		patposts[p] = post
+	}
+
+	posteriors = make([][]float64, len(l.sites))
+	for i, p := range l.sites {
+		posteriors[i] = patposts[p]
+	}
+	return
+}
@@ -67,3 +67,66 @@ func TestMinDist(t *testing.T) {
 		}
 	}
 }
+
+// Naive transfer dissimilarity: for each distinct internal bipartition
+// of t1, minimum transfer distance to all bipartitions of t2
+func naiveTransferDissimilarity(t1, t2 *tree.Tree) (dist int) {
+	n := uint(len(t1.Tips()))
+	seen := tree.NewEdgeIndex(128, 0.75)
+	for _, e1 := range t1.Edges() {
+		if p, _ := e1.TopoDepth(); p <= 1 {
+			continue
+		}
+		if _, ok := seen.Value(e1); ok {
+			continue
+		}
+		seen.PutEdgeValue(e1, 0, 0)
+		min := n
+		for _, e2 := range t2.Edges() {
+			d := e1.Bitset().Count() + e2.Bitset().Count() - 2*e1.Bitset().IntersectionCardinality(e2.Bitset())
+			if n-d < d {
+				d = n - d
+			}
+			if d < min {
+				min = d
+			}
+		}
+		dist += int(min)
+	}
+	return
+}
+
+func TestTransferDistance(t *testing.T) {
+	trees := []string{
+		"(a,b,(c,d,(e,f,(g,h))));",
+		"((a,b),d,((f,c),((g,e),h)));",
+		"(((a,b),(c,d)),((e,f),(g,h)));",
+		"(a,b,c,d,e,f,g,h);",
+		"((a,h),(b,g),((c,f),(d,e)));",
+	}
+	parsed := make([]*tree.Tree, len(trees))
+	for i, nw := range trees {
+		var err error
+		if parsed[i], err = newick.NewParser(strings.NewReader(nw)).Parse(); err != nil {
+			t.Fatal(err)
+		}
+		if err = parsed[i].ReinitIndexes(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, t1 := range parsed {
+		for j, t2 := range parsed {
+			expected := naiveTransferDissimilarity(t1, t2) + naiveTransferDissimilarity(t2, t1)
+			dist, err := support.TransferDistance(t1, t2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dist != expected {
+				t.Errorf("Trees %d and %d: transfer distance is %d and should be %d", i, j, dist, expected)
+			}
+			if i == j && dist != 0 {
+				t.Errorf("Transfer distance of tree %d with itself should be 0", i)
+			}
+		}
+	}
+}
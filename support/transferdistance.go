@@ -0,0 +1,66 @@
+package support
+
+import (
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Computes the transfer distance between two trees having the same set of
+// tips: the sum, over all internal bipartitions of the first tree, of their
+// minimum transfer distance to the bipartitions of the second tree, plus the
+// same sum from the second tree to the first tree (which makes it symmetric).
+//
+// Bipartitions defined by several edges (around the root of rooted trees) are
+// counted only once.
+//
+// It assumes that ReinitIndexes() has been called on both trees. Edge ids
+// of both trees are modified.
+func TransferDistance(t1, t2 *tree.Tree) (dist int, err error) {
+	var d12, d21 int
+
+	if err = t1.CompareTipIndexes(t2); err != nil {
+		return
+	}
+	edges1 := t1.Edges()
+	edges2 := t2.Edges()
+	for i, e := range edges1 {
+		e.SetId(i)
+	}
+	for i, e := range edges2 {
+		e.SetId(i)
+	}
+	d12 = transferDissimilarity(t1, t2, edges1, edges2)
+	d21 = transferDissimilarity(t2, t1, edges2, edges1)
+	dist = d12 + d21
+	return
+}
+
+// Sum of the minimum transfer distances of the internal bipartitions
+// of the reference tree to the bipartitions of the compared tree
+func transferDissimilarity(reftree, comptree *tree.Tree, refedges, compedges []*tree.Edge) (dist int) {
+	ntips := len(reftree.Tips())
+	compindex := tree.NewEdgeIndex(uint64(len(compedges)*2), 0.75)
+	for i, e := range compedges {
+		compindex.PutEdgeValue(e, i, e.Length())
+	}
+	refindex := tree.NewEdgeIndex(uint64(len(refedges)*2), 0.75)
+	for i, e := range refedges {
+		p, _ := e.TopoDepth()
+		if p <= 1 {
+			continue
+		}
+		if _, ok := refindex.Value(e); ok {
+			continue
+		}
+		refindex.PutEdgeValue(e, i, e.Length())
+		if _, ok := compindex.Value(e); ok {
+			continue
+		}
+		if p == 2 {
+			dist++
+			continue
+		}
+		d, _, _, _ := MinTransferDist(e, reftree, comptree, ntips, compedges, true)
+		dist += d
+	}
+	return
+}
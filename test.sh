@@ -313,6 +313,28 @@ ${GOTREE} compare trees -i <(${GOTREE} generate yuletree --seed 10) -c <(${GOTRE
 diff -q -b expected result
 rm -f expected result
 
+echo "->gotree compare trees metric"
+cat > expected <<EOF
+tree	wrf
+0	3.000000
+1	2.000000
+tree	kf
+0	2.236068
+1	1.414214
+tree	msd
+0	0.000000
+1	2.000000
+EOF
+cat > tmp_trees.txt <<EOF
+((A:1,B:2):1,C:1,(D:1,E:1):3);
+((A:1,C:1):1,B:1,(D:1,E:1):1);
+EOF
+${GOTREE} compare trees -i <(echo "((A:1,B:1):1,C:1,(D:1,E:1):1);") -c tmp_trees.txt --metric wrf > result
+${GOTREE} compare trees -i <(echo "((A:1,B:1):1,C:1,(D:1,E:1):1);") -c tmp_trees.txt --metric kf >> result
+${GOTREE} compare trees -i <(echo "((A:1,B:1):1,C:1,(D:1,E:1):1);") -c tmp_trees.txt --metric msd >> result
+diff -q -b expected result
+rm -f expected result tmp_trees.txt
+
 # gotree compare edges
 echo "->gotree compare edges"
 cat > expected <<EOF
@@ -377,6 +399,105 @@ ${GOTREE} compare tips -i <(${GOTREE} generate yuletree --seed 10) -c <(${GOTREE
 diff -q -b expected result
 rm -f expected result
 
+echo "->gotree compare mast"
+cat > expected <<EOF
+((E:1,F:1):1,A:2,D:2);
+((A:1,B:1):1,(E:1,F:1):1,C:2);
+EOF
+cat > expected_removed <<EOF
+tree	removed_ref	removed_comp
+0	B,C	C,B,G
+1	D	D
+EOF
+cat > tmp_trees.txt <<EOF
+((A,C),(B,D),(E,(F,G)));
+(((A,B),C),((D,E),F));
+EOF
+${GOTREE} compare mast -i <(echo "((A:1,B:1):1,(C:1,D:1):1,(E:1,F:1):1);") -c tmp_trees.txt --out-removed result_removed > result
+diff -q -b expected result
+diff -q -b expected_removed result_removed
+rm -f expected result expected_removed result_removed tmp_trees.txt
+
+echo "->gotree compare matrix"
+cat > expected <<EOF
+3
+0	0	2	3
+1	2	0	3
+2	3	3	0
+tree	0	1	2
+0	0	0.3333333333333333	1
+1	0.3333333333333333	0	1
+2	1	1	0
+3
+0	0	3	15
+1	3	0	15
+2	15	15	0
+3
+0	0	2	4
+1	2	0	4
+2	4	4	0
+EOF
+cat > tmp_trees.txt <<EOF
+(A,B,(C,(D,(E,F))));
+(A,C,(B,(D,(E,F))));
+(A,B,C,D,E,F);
+EOF
+${GOTREE} compare matrix -i tmp_trees.txt --metric rf -t 2 > result
+${GOTREE} compare matrix -i tmp_trees.txt --metric nrf --output-format tsv >> result
+${GOTREE} compare matrix -i tmp_trees.txt --metric quartet >> result
+${GOTREE} compare matrix -i tmp_trees.txt --metric transfer -t 2 >> result
+diff -q -b expected result
+rm -f expected result tmp_trees.txt
+
+echo "->gotree compare pathdiff"
+cat > expected <<EOF
+3
+0	0.000000000000	2.449489742783	0.000000000000
+1	2.449489742783	0.000000000000	2.000000000000
+2	0.000000000000	2.000000000000	0.000000000000
+3
+0	0.000000000000	2.449489742783	2.828427124746
+1	2.449489742783	0.000000000000	4.000000000000
+2	2.828427124746	4.000000000000	0.000000000000
+EOF
+cat > tmp_trees.txt <<EOF
+((A:1,B:1):1,C:1,(D:1,E:1):1);
+((A:1,C:1):1,B:1,(D:1,E:1):1);
+((A:1,B:2):1,(C:1,D:1):1,F:1);
+EOF
+${GOTREE} compare pathdiff -i tmp_trees.txt > result
+${GOTREE} compare pathdiff -i tmp_trees.txt --weighted >> result
+diff -q -b expected result
+rm -f expected result tmp_trees.txt
+
+echo "->gotree compare quartets"
+cat > expected <<EOF
+tree	shared	diff	unresolved_ref	unresolved_comp	unresolved_both	total
+0	3	2	0	0	0	5
+1	0	0	0	5	0	5
+EOF
+cat > tmp_trees.txt <<EOF
+((A,C),B,(D,E));
+(A,B,C,D,E);
+EOF
+${GOTREE} compare quartets -i <(echo "((A,B),C,(D,E));") -c tmp_trees.txt > result
+diff -q -b expected result
+rm -f expected result tmp_trees.txt
+
+echo "->gotree compare triplets"
+cat > expected <<EOF
+tree	shared	diff	unresolved_ref	unresolved_comp	unresolved_both	total
+0	3	7	0	0	0	10
+1	5	4	0	1	0	10
+EOF
+cat > tmp_trees.txt <<EOF
+((A,C),(B,(D,E)));
+((A,B,C),(D,E));
+EOF
+${GOTREE} compare triplets -i <(echo "((A,B),(C,(D,E)));") -c tmp_trees.txt > result
+diff -q -b expected result
+rm -f expected result tmp_trees.txt
+
 # gotree compare tips file
 echo "->gotree compare tips file"
 cat > expected <<EOF
@@ -489,6 +610,114 @@ diff -q -b expected_comp result
 rm -f expected_comp expected_tree result
 
 
+echo "->gotree compute consensus mode"
+cat > input <<EOF
+(((A:1,B:1):1,C:1):1,(D:1,E:1):1);
+(((A:1,B:1):1,D:1):1,(C:1,E:1):1);
+(((A:1,C:1):1,B:1):1,(D:1,E:1):1);
+EOF
+cat > expected <<EOF
+(D:1,E:1,(C:1,(A:1,B:1)0.6666666666666666:1)0.6666666666666666:2);
+(A:1,B:1,C:1,D:1,E:1);
+(D:1,E:1,(C:1,(A:1,B:1)0.6666666666666666:1)0.6666666666666666:2);
+((A:1,B:1)0.6666666666666666:1,C:1,D:1,E:1);
+EOF
+${GOTREE} compute consensus -i input --mode majority > result
+${GOTREE} compute consensus -i input --mode strict >> result
+${GOTREE} compute consensus -i input --mode greedy >> result
+${GOTREE} compute consensus -i input --mode adams >> result
+diff -q -b expected result
+rm -f input expected result
+
+
+echo "->gotree supertree mrp"
+cat > input <<EOF
+((A,B),(C,D),E);
+((A,C),(B,F),G);
+EOF
+cat > expected <<EOF
+   7   4
+A  1010
+B  1001
+C  0110
+D  01??
+E  00??
+F  ??01
+G  ??00
+#NEXUS
+begin data;
+dimensions ntax=7 nchar=4;
+format datatype=standard symbols="01" missing=?;
+matrix
+A 1010
+B 1001
+C 0110
+D 01??
+E 00??
+F ??01
+G ??00
+;
+end;
+EOF
+${GOTREE} supertree mrp -i input > result
+${GOTREE} supertree mrp -i input --output-format nexus >> result
+diff -q -b expected result
+rm -f input expected result
+
+
+echo "->gotree supertree astral"
+cat > input <<EOF
+((A,B),C,(D,E));
+((A,B),C,(D,E));
+((A,C),B,(D,E));
+((A,B),(C,D),E);
+EOF
+cat > expected <<EOF
+(B,(C,(D,E)0.75)0.75,A);
+EOF
+${GOTREE} supertree astral -i input > result
+diff -q -b expected result
+rm -f input expected result
+
+
+echo "->gotree reconcile"
+cat > species <<EOF
+((A,B),(C,D));
+EOF
+printf "a1\tA\na2\tA\nb1\tB\nc1\tC\n" > map
+cat > expected <<EOF
+(((a1,b1)[S],(a2,b1)[S])[D],c1)[S];
+tree	duplications	speciations	losses
+0	1	3	1
+EOF
+echo "(((a1,b1),(a2,b1)),c1);" | ${GOTREE} reconcile -s species -m map --out-summary summary > result
+cat summary >> result
+diff -q -b expected result
+rm -f species map summary expected result
+
+
+echo "->gotree dating rtt"
+printf "A\t2012\nB\t2014\nC\t2014\nD\t2018\nE\t2012\n" > dates
+cat > expected <<EOF
+(((A:0.05,B:0.07):0.03,C:0.1):0.04,(D:0.08,E:0.02):0.1);
+(((A:0.05,B:0.07):0.03,C:0.1):0.04,(D:0.08,E:0.02):0.1);
+EOF
+echo "((A:0.05,B:0.07):0.03,C:0.1,(D:0.08,E:0.02):0.14);" | ${GOTREE} dating rtt -d dates | ${GOTREE} brlen round -p 6 > result
+echo "((A:0.05,B:0.07):0.03,C:0.1,(D:0.08,E:0.02):0.14);" | ${GOTREE} dating rtt -d dates --criterion rms | ${GOTREE} brlen round -p 6 >> result
+diff -q -b expected result
+rm -f dates expected result
+
+
+echo "->gotree dating lsd"
+printf "A\t10\nB\t10\nC\t12\nA,B\t8.5\tinf\n" > dates
+cat > expected <<EOF
+((A[date=10]:1.5,B[date=10]:1.5)[date=8.5]:0.75,C[date=12]:4.25)[date=7.75];
+EOF
+echo "((A:1,B:3):1,C:4);" | ${GOTREE} dating lsd -d dates --rate 1 > result
+diff -q -b expected result
+rm -f dates expected result
+
+
 echo "->gotree compute classical bootstrap"
 cat > expected <<EOF
 (Tip0,(Tip4,(Tip7,Tip2)1)1,((Tip9,(Tip8,Tip3)0.87)1,(Tip1,(Tip6,Tip5)0.65)0.97)0.67);
@@ -587,6 +816,42 @@ diff -q -b expected result
 rm -f expected result
 
 
+echo "->gotree generate bdtree"
+cat > expected <<EOF
+(Tip2:1.3152876216568612,(((Tip4:0.31398669514982785,Tip3:0.31398669514982785):0.4711066757524372,Tip0:0.785093370902265):0.21219572102607176,Tip1:0.9972890919283368):0.3179985297285244);
+((Tip0:0.8349229008699096,(Tip4:0.540687078328298,Tip3:0.540687078328298):0.2942358225416116):0.1650770991300904,((Tip5:0.5474387547079591,Tip1:0.5474387547079591):0.12442175234580855,Tip2:0.6718605070537677):0.32813949294623235);
+EOF
+${GOTREE} generate bdtree --seed 10 -l 5 --lambda 2 --mu 1 --rho 0.5 > result
+${GOTREE} generate bdtree --seed 10 --crown-age 1 >> result
+diff -q -b expected result
+rm -f expected result
+
+
+echo "->gotree generate coaltree"
+cat > dates <<EOF
+A	2000
+B	2005
+C	2010
+D	2010
+EOF
+cat > demes <<EOF
+A	x
+B	y
+C	x
+D	y
+EOF
+cat > expected <<EOF
+((Tip3:0.27830763362330324,Tip1:0.27830763362330324):0.718582962761058,(Tip2:0.5845663606037633,Tip0:0.5845663606037633):0.41232423578059796);
+(((C:1.5442313999859913,D:1.5442313999859913):4.239265654926749,B:0.7834970549127398):5.579872215649594,A:1.363369270562334);
+(A:1.400207383872651,((C:1.3823616555778688,D:1.3823616555778688):3.6451285047506268,B:0.027490160328495605):6.372717223544155);
+EOF
+${GOTREE} generate coaltree --seed 10 -l 4 --popsize 2 > result
+${GOTREE} generate coaltree --seed 10 -d dates --popsize 2 --growth 0.1 >> result
+${GOTREE} generate coaltree --seed 10 -d dates --deme-file demes --migration 0.5 >> result
+diff -q -b expected result
+rm -f expected result dates demes
+
+
 echo "->gotree matrix"
 cat > expected <<EOF
 5
@@ -600,6 +865,38 @@ ${GOTREE} generate yuletree --seed 10 -l 5 | ${GOTREE} matrix > result
 diff -q -b expected result
 rm -f expected result
 
+echo "->gotree infer nj"
+cat > expected <<EOF
+(((Tip4:0.020616,Tip2:0.124674):0.182468,Tip0:0.259199):0.045939,Tip1:0.198527,Tip3:0.13605);
+EOF
+${GOTREE} generate yuletree --seed 10 -l 5 | ${GOTREE} matrix | ${GOTREE} infer nj | ${GOTREE} brlen round -p 6 > result
+diff -q -b expected result
+rm -f expected result
+
+echo "->gotree infer nj bionj"
+cat > expected <<EOF
+(((Tip4:0.020616,Tip2:0.124674):0.182468,Tip0:0.259199):0.045939,Tip1:0.198527,Tip3:0.13605);
+EOF
+${GOTREE} generate yuletree --seed 10 -l 5 | ${GOTREE} matrix | ${GOTREE} infer nj --algo bionj | ${GOTREE} brlen round -p 6 > result
+diff -q -b expected result
+rm -f expected result
+
+echo "->gotree infer upgma"
+cat > matrix <<EOF
+5
+a
+b 17
+c 21 30
+d 31 34 28
+e 23 21 39 43
+EOF
+cat > expected <<EOF
+(((a:8.5,b:8.5):2.5,e:11):5.5,(d:14,c:14):2.5);
+EOF
+${GOTREE} infer upgma -i matrix > result
+diff -q -b expected result
+rm -f expected result matrix
+
 echo "->gotree brlen setmin 1"
 cat > expected <<EOF
 ((Tip4:1,(Tip7:1,Tip2:1):1):1,Tip0:1,((Tip8:1,(Tip9:1,Tip3:1):1):1,((Tip6:1,Tip5:1):1,Tip1:1):1):1);
@@ -780,6 +1077,22 @@ ${GOTREE} generate yuletree --seed 10 | ${GOTREE} reroot midpoint | ${GOTREE} br
 diff -q -b expected result
 rm -f expected result
 
+echo "->gotree reroot mad"
+cat > expected <<EOF
+(((A:1,B:2):0.5,(D:1,E:0.2):2):0.408248,C:2.591752);
+EOF
+echo "((A:1,B:2):0.5,C:3,(D:1,E:0.2):2);" | ${GOTREE} reroot mad | ${GOTREE} brlen round -p 6 > result
+diff -q -b expected result
+rm -f expected result
+
+echo "->gotree reroot minvar"
+cat > expected <<EOF
+(((A:1,B:2):0.5,(D:1,E:0.2):2):0.35,C:2.65);
+EOF
+echo "((A:1,B:2):0.5,C:3,(D:1,E:0.2):2);" | ${GOTREE} reroot minvar | ${GOTREE} brlen round -p 6 > result
+diff -q -b expected result
+rm -f expected result
+
 echo "->gotree resolve"
 cat > expected <<EOF
 ((Tip4,(Tip7,Tip2)),(Tip3,(Tip9,Tip8)),(((Tip6,Tip5),Tip1),Tip0));
@@ -1341,6 +1654,68 @@ ${GOTREE} acr -i tmp_tree.txt --states tmp_states.txt --algo deltran -o result
 diff -q -b expected result
 rm -f expected result tmp_tree.txt tmp_states.txt
 
+echo "->gotree acr mk"
+cat > tmp_states.txt <<EOF
+A,X
+B,X
+C,Y
+D,Z
+E,Y
+EOF
+cat > tmp_tree.txt <<EOF
+((A:0.1,B:0.2)n1:0.3,(C:0.2,D:0.4)n2:0.1,E:0.5)root;
+EOF
+cat > expected <<EOF
+((A[X]:0.1,B[X]:0.2)n1[X]:0.3,(C[Y]:0.2,D[Z]:0.4)n2[Y]:0.1,E[Y]:0.5)root[Y];
+EOF
+cat > expected.steps <<EOF
+lnl -5.688635
+EOF
+cat > expected.probas <<EOF
+tree	node	X	Y	Z
+0	root	0.201149	0.699052	0.099799
+0	n1	0.969739	0.023269	0.006992
+0	A	1.000000	0.000000	0.000000
+0	B	1.000000	0.000000	0.000000
+0	n2	0.121015	0.745031	0.133955
+0	C	0.000000	1.000000	0.000000
+0	D	0.000000	0.000000	1.000000
+0	E	0.000000	1.000000	0.000000
+EOF
+${GOTREE} acr -i tmp_tree.txt --states tmp_states.txt --algo mk -o result --out-steps result.steps --out-probas result.probas
+diff -q -b expected result
+diff -q -b expected.steps result.steps
+diff -q -b expected.probas result.probas
+rm -f expected result expected.steps result.steps expected.probas result.probas tmp_tree.txt tmp_states.txt
+
+echo "->gotree acr sankoff"
+cat > tmp_states.txt <<EOF
+t1,A
+t2,B
+t3,B
+t4,A
+t5,B
+t6,B
+EOF
+cat > tmp_tree.txt <<EOF
+(((t1,t2)n1,(t3,t4)n2)n3,(t5,t6)n4)root;
+EOF
+cat > tmp_costs.txt <<EOF
+	A	B
+A	0	1
+B	100	0
+EOF
+cat > expected <<EOF
+(((t1[A],t2[B])n1[A],(t3[B],t4[A])n2[A])n3[A],(t5[B],t6[B])n4[B])root[A];
+EOF
+cat > expected.steps <<EOF
+cost 3
+EOF
+${GOTREE} acr -i tmp_tree.txt --states tmp_states.txt --cost-matrix tmp_costs.txt --algo downpass -o result --out-steps result.steps
+diff -q -b expected result
+diff -q -b expected.steps result.steps
+rm -f expected result expected.steps result.steps tmp_tree.txt tmp_states.txt tmp_costs.txt
+
 
 echo "->gotree asr acctran"
 cat > tmp_states.txt <<EOF
@@ -1902,3 +2277,167 @@ ${GOTREE} unroot -i input | ${GOTREE} stats monophyletic -o result -l tipfile
 diff -q -b result expected2
 
 rm -rf tipfile input expected expected2 result
+
+echo "->gotree spr"
+cat > expected <<EOF
+((a:1,b:2):1,(c:5,e:5):2,d:2);
+((a:1,b:2):1,(c:5,d:4):2.5,e:2.5);
+EOF
+echo "((a:1,b:2):1,c:3,(d:4,e:5):2);" | ${GOTREE} spr --radius 1 > neighbors
+head -n 2 neighbors > result
+diff -q -b expected result
+rm -f expected result neighbors
+
+echo "->gotree nni/spr/tbr --count"
+cat > expected <<EOF
+6
+30
+34
+30
+EOF
+echo "(((a,b),c),d,(e,f));" | ${GOTREE} nni --count > result
+echo "(((a,b),c),d,(e,f));" | ${GOTREE} spr --count >> result
+echo "(((a,b),c),d,(e,f));" | ${GOTREE} tbr --count >> result
+echo "((a,b),(c,d),(e,f));" | ${GOTREE} tbr --unique | wc -l | awk '{print $1}' >> result
+diff -q -b expected result
+rm -f expected result
+
+echo "->gotree compute parsimony"
+cat > align.fa <<EOF
+>A
+AAAACCGT
+>B
+AAAACCGA
+>C
+CCAAGCGT
+>D
+CCAAGCTT
+>E
+CCCCGGTT
+>F
+CCCCGGTA
+EOF
+cat > expected <<EOF
+tree	parsimony
+0	16
+tree	initial	parsimony
+0	16	9
+(((B,A),C),(E,F),D);
+EOF
+echo "((A,C),(B,E),(D,F));" | ${GOTREE} compute parsimony -a align.fa > result
+echo "((A,C),(B,E),(D,F));" | ${GOTREE} compute parsimony -a align.fa --search spr --out-tree tmp >> result
+cat tmp >> result
+diff -q -b expected result
+rm -f expected result align.fa tmp
+
+echo "->gotree compute likelihood"
+cat > align.fa <<EOF
+>A
+ACGTACGTAA
+>B
+ACGTACCTAA
+>C
+ACTTACGTTA
+>D
+ACTTTCGTTN
+EOF
+cat > expected <<EOF
+tree	lnl
+0	-32.890720
+1	-32.890720
+EOF
+cat > tree.nw <<EOF
+((A:0.1,B:0.2):0.05,C:0.1,D:0.2);
+(((A:0.1,B:0.2):0.05,C:0.1):0.15,D:0.05);
+EOF
+${GOTREE} compute likelihood -i tree.nw -a align.fa --model jc69 > result
+diff -q -b expected result
+rm -f expected result align.fa tree.nw
+
+echo "->gotree brlen optimize"
+cat > align.fa <<EOF
+>A
+ACGTACGTAA
+>B
+ACGTACCTAA
+>C
+ACTTACGTTA
+>D
+ACTTTCGTTN
+>E
+ACTTTCGTTN
+EOF
+cat > expected <<EOF
+((A:0,B:0.1073):0.2326,C:0,D:0.1203);
+EOF
+cat > expected.log <<EOF
+tree	initial	lnl
+0	-32.005705	-29.651736
+EOF
+echo "((A:0.1,B:0.1):0.1,C:0.1,(D:0.1,E:0.1):0.1);" | ${GOTREE} prune E | ${GOTREE} brlen optimize -a align.fa --log result.log | ${GOTREE} brlen round -p 4 > result
+diff -q -b expected result
+diff -q -b expected.log result.log
+rm -f expected result expected.log result.log align.fa
+
+echo "->gotree asr ml"
+cat > align.fa <<EOF
+>A
+ACGTACGTAA
+>B
+ACGTACCTAA
+>C
+ACTTACGTTA
+>D
+ACTTTCGTTN
+EOF
+cat > expected <<EOF
+((A[ACGTACGTAA]:0.1,B[ACGTACCTAA]:0.2)n1[ACGTACGTAA]:0.05,C[ACTTACGTTA]:0.1,D[ACTTTCGTTN]:0.2)[ACTTACGTTA];
+EOF
+cat > expected.log <<EOF
+lnl -32.890720
+EOF
+cat > expected.post <<EOF
+tree	node	site	state	A	C	G	T
+0	0	2	T	0.002162	0.002162	0.111052	0.884625
+0	n1	2	G	0.002162	0.002162	0.884625	0.111052
+EOF
+echo "((A:0.1,B:0.2)n1:0.05,C:0.1,D:0.2);" | ${GOTREE} asr -a align.fa --algo ml --model jc69 --log result.log --posteriors result.post > result
+diff -q -b expected result
+diff -q -b expected.log result.log
+awk 'NR==1 || $3==2' result.post > result.post2
+diff -q -b expected.post result.post2
+rm -f expected result expected.log result.log expected.post result.post result.post2 align.fa
+
+echo "->gotree asr sankoff"
+cat > align.fa <<EOF
+>t1
+AAC-
+>t2
+AGCA
+>t3
+GGCA
+>t4
+AGTA
+>t5
+GGTN
+>t6
+GGTA
+EOF
+cat > tmp_costs.txt <<EOF
+# Reversals G->A are costly
+,A,G,C,T
+A,0,1,1,1
+G,5,0,1,1
+C,1,1,0,1
+T,1,1,1,0
+EOF
+cat > expected <<EOF
+(((t1[AACA],t2[AGCA])n1[AACA],(t3[GGCA],t4[AGTA])n2[AG{CT}A])n3[A{AG}{CT}A],(t5[GGTA],t6[GGTA])n4[GGTA])root[A{AG}{CT}A];
+EOF
+cat > expected.log <<EOF
+cost 2 3 2 0
+EOF
+echo "(((t1,t2)n1,(t3,t4)n2)n3,(t5,t6)n4)root;" | ${GOTREE} asr -a align.fa --cost-matrix tmp_costs.txt --algo deltran --log result.log > result
+diff -q -b expected result
+diff -q -b expected.log result.log
+rm -f expected result expected.log result.log align.fa tmp_costs.txt
@@ -3,6 +3,7 @@ package tests
 import (
 	"bufio"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/evolbioinfo/gotree/io/newick"
@@ -183,3 +184,143 @@ func TestConsensus2(t *testing.T) {
 		t.Error("Strict Consensus of 3 random binary trees (1000 tips) should strongly probably be a star tree")
 	}
 }
+
+func treeChannel(t *testing.T, nws ...string) <-chan tree.Trees {
+	trees := make(chan tree.Trees, len(nws))
+	for i, nw := range nws {
+		tr, err := newick.NewParser(strings.NewReader(nw)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		trees <- tree.Trees{Tree: tr, Id: i, Err: nil}
+	}
+	close(trees)
+	return trees
+}
+
+// Returns the consensus bipartitions with their supports
+func consensusSupports(t *testing.T, consensus *tree.Tree) map[string]float64 {
+	supports := make(map[string]float64)
+	for _, e := range consensus.Edges() {
+		if e.Right().Tip() {
+			continue
+		}
+		tips := make([]string, 0)
+		for _, name := range consensus.SortedTips() {
+			if e.TipPresent(uint(name.TipIndex())) {
+				tips = append(tips, name.Name())
+			}
+		}
+		supports[strings.Join(tips, ",")] = e.Support()
+	}
+	return supports
+}
+
+func TestStrictAndGreedyConsensus(t *testing.T) {
+	nws := []string{
+		"(((A:1,B:1):1,C:1):1,(D:1,E:1):1,F:1);",
+		"(((A:1,B:1):1,D:1):1,(C:1,E:1):1,F:1);",
+		"(((A:1,C:1):1,B:1):1,(D:1,E:1):1,F:1);",
+		"(((A:1,B:1):1,F:1):1,(D:1,E:1):1,C:1);",
+	}
+	strict, err := tree.StrictConsensus(treeChannel(t, nws...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := consensusSupports(t, strict); len(s) != 0 {
+		t.Errorf("Strict consensus should be a star tree: %s", strict.Newick())
+	}
+
+	// AB: 3/4, DE: 3/4, ABC|DEF: 2/4, ABD|CEF, ABF|CDE, AC|BDEF: 1/4
+	greedy, err := tree.GreedyConsensus(treeChannel(t, nws...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := consensusSupports(t, greedy)
+	if len(s) != 3 {
+		t.Errorf("Greedy consensus should be fully resolved: %s", greedy.Newick())
+	}
+	if s["A,B"] != 0.75 || s["D,E"] != 0.75 {
+		t.Errorf("Greedy consensus should contain AB and DE with support 0.75: %s", greedy.Newick())
+	}
+	for split := range s {
+		if split != "A,B" && split != "D,E" && split != "A,B,C" && split != "D,E,F" {
+			t.Errorf("Greedy consensus should contain ABC|DEF: %s", greedy.Newick())
+		}
+	}
+
+	// Greedy consensus contains the majority consensus
+	majority, err := tree.Consensus(treeChannel(t, nws...), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for split := range consensusSupports(t, majority) {
+		if _, ok := s[split]; !ok {
+			t.Errorf("Greedy consensus should contain majority consensus bipartition %s", split)
+		}
+	}
+}
+
+func TestAdamsConsensus(t *testing.T) {
+	// Classical example: a tip (D) jumping inside a clade
+	adams, err := tree.AdamsConsensus(treeChannel(t,
+		"((((A:1,B:1):1,C:1):1,D:1):1,E:1);",
+		"((((A:1,B:1):1,D:1):1,C:1):1,E:1);",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := consensusSupports(t, adams)
+	// Adams: (((A,B),C,D),E)
+	if len(s) != 2 || s["A,B"] != 1 {
+		t.Errorf("Wrong Adams consensus: %s", adams.Newick())
+	}
+
+	// No cluster shared below ABCD: A, B, C, D form a polytomy
+	adams, err = tree.AdamsConsensus(treeChannel(t,
+		"(((A,D),(B,C)),E);",
+		"(((A,C),(B,D)),E);",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s = consensusSupports(t, adams); len(s) != 1 {
+		t.Errorf("Wrong Adams consensus: %s", adams.Newick())
+	}
+
+	if _, err = tree.AdamsConsensus(treeChannel(t, "((A,B),C,(D,E));")); err == nil {
+		t.Errorf("Adams consensus of unrooted trees should return an error")
+	}
+}
+
+func TestConsensusRootedNoLength(t *testing.T) {
+	// Both branches around the root define the bipartition ABC|DE: undefined
+	// lengths must not be summed
+	nws := []string{
+		"(((A,B),C),(D,E));",
+		"((A,(B,C)),(D,E));",
+	}
+	consensus, err := tree.Consensus(treeChannel(t, nws...), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range consensus.Edges() {
+		if e.Length() != tree.NIL_LENGTH {
+			t.Errorf("Consensus of trees without lengths should not have lengths: %s", consensus.Newick())
+		}
+	}
+	if s := consensusSupports(t, consensus); len(s) != 1 || s["A,B,C"] != 1 {
+		t.Errorf("Wrong consensus: %s", consensus.Newick())
+	}
+
+	// Defined lengths are averaged over the trees in which they are defined
+	consensus, err = tree.Consensus(treeChannel(t, "(((A,B),C):1,(D,E):2);", "((A,(B,C)),(D,E));"), 0.5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range consensus.Edges() {
+		if !e.Right().Tip() && e.Length() != 3 {
+			t.Errorf("Wrong consensus length: %s", consensus.Newick())
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package tests
 
 import (
-	"github.com/evolbioinfo/gotree/tree"
+	"math"
+	"math/rand"
 	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
 )
 
 var prevtree2 *tree.Tree
@@ -25,3 +28,191 @@ func BenchmarkBinaryTreeGeneration1000(b *testing.B)   { benchmarkBinaryTreeGene
 func BenchmarkBinaryTreeGeneration10000(b *testing.B)  { benchmarkBinaryTreeGeneration(10000, b) }
 func BenchmarkBinaryTreeGeneration100000(b *testing.B) { benchmarkBinaryTreeGeneration(100000, b) }
 func BenchmarkBinaryTreeGeneration200000(b *testing.B) { benchmarkBinaryTreeGeneration(200000, b) }
+
+// Checks that the tree is rooted, binary and ultrametric, and returns
+// its number of tips and its crown age
+func checkBirthDeathTree(t *testing.T, tr *tree.Tree) (ntips int, age float64) {
+	if !tr.Rooted() {
+		t.Errorf("Birth-death tree should be rooted")
+	}
+	dists := make(map[string]float64)
+	tipDistances(tr.Root(), nil, 0, dists)
+	age = -1
+	for _, d := range dists {
+		if age < 0 {
+			age = d
+		}
+		if math.Abs(d-age) > 1e-9 {
+			t.Errorf("Birth-death tree should be ultrametric: %s", tr.Newick())
+			break
+		}
+	}
+	for _, n := range tr.Nodes() {
+		if !n.Tip() && n != tr.Root() && n.Nneigh() != 3 {
+			t.Errorf("Birth-death tree should be binary: %s", tr.Newick())
+		}
+	}
+	return len(dists), age
+}
+
+// Expected crown age of a reconstructed birth-death tree with n tips, under
+// a uniform prior on the crown age: its density is proportional to
+// exp(2rt)/F(t)^4*(1-1/F(t))^(n-2), with F(t) = 1+rho*lambda/r*(exp(rt)-1)
+func expectedCrownAge(n int, lambda, mu, rho float64) float64 {
+	var num, den float64
+	r := lambda - mu
+	for t := 0.00005; t < 100; t += 0.0001 {
+		f := 1 + rho*lambda/r*(math.Exp(r*t)-1)
+		g := math.Exp(2*r*t) / math.Pow(f, 4) * math.Pow(1-1/f, float64(n-2))
+		num += t * g
+		den += g
+	}
+	return num / den
+}
+
+func TestBirthDeathTreeNbTips(t *testing.T) {
+	rand.Seed(10)
+	for _, p := range [][3]float64{{1, 0, 1}, {2, 1, 0.5}, {1, 2, 1}} {
+		var mean float64
+		for i := 0; i < 2000; i++ {
+			tr, err := tree.RandomBirthDeathTree(10, 0, p[0], p[1], p[2])
+			if err != nil {
+				t.Fatal(err)
+			}
+			ntips, age := checkBirthDeathTree(t, tr)
+			if ntips != 10 {
+				t.Errorf("Birth-death tree should have 10 tips: %s", tr.Newick())
+			}
+			mean += age / 2000.0
+		}
+		expected := expectedCrownAge(10, p[0], p[1], p[2])
+		if p[1] == 0 && p[2] == 1 {
+			// Yule process: sum_{i=2}^{n} 1/(i.lambda) (Gernhard, 2008)
+			expected = 0
+			for i := 2; i <= 10; i++ {
+				expected += 1 / (float64(i) * p[0])
+			}
+		}
+		if math.Abs(mean-expected) > 0.05*expected {
+			t.Errorf("Wrong mean crown age for lambda=%f, mu=%f, rho=%f: %f, expected %f", p[0], p[1], p[2], mean, expected)
+		}
+	}
+}
+
+func TestBirthDeathTreeCrownAge(t *testing.T) {
+	rand.Seed(10)
+	// Each side of the crown has a geometric number of tips,
+	// with mean F(2) = 1+0.5*2/1*(exp(2)-1)
+	var mean float64
+	for i := 0; i < 2000; i++ {
+		tr, err := tree.RandomBirthDeathTree(0, 2, 2, 1, 0.5)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ntips, age := checkBirthDeathTree(t, tr)
+		if math.Abs(age-2) > 1e-9 {
+			t.Errorf("Birth-death tree should have a crown age of 2: %f", age)
+		}
+		mean += float64(ntips) / 2000.0
+	}
+	if expected := 2 * math.Exp(2); math.Abs(mean-expected) > 1 {
+		t.Errorf("Wrong mean number of tips: %f, expected %f", mean, expected)
+	}
+
+	tr, err := tree.RandomBirthDeathTree(5, 3, 1, 0.5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ntips, age := checkBirthDeathTree(t, tr); ntips != 5 || math.Abs(age-3) > 1e-9 {
+		t.Errorf("Birth-death tree should have 5 tips and a crown age of 3: %s", tr.Newick())
+	}
+
+	if _, err = tree.RandomBirthDeathTree(0, 0, 1, 0, 1); err == nil {
+		t.Errorf("Birth-death tree without conditioning should return an error")
+	}
+}
+
+// Mean age of the root of coalescent genealogies of the given samples
+func meanCoalescentRootAge(t *testing.T, samples []tree.CoalescentSample, popsize, growth float64, ndemes int, migration float64) float64 {
+	var mean float64
+	ages := make(map[string]float64)
+	for _, s := range samples {
+		ages[s.Name] = s.Age
+	}
+	for i := 0; i < 4000; i++ {
+		tr, err := tree.RandomCoalescentTree(samples, popsize, growth, ndemes, migration)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !tr.Rooted() || len(tr.Tips()) != len(samples) {
+			t.Fatalf("Coalescent tree should be rooted with %d tips: %s", len(samples), tr.Newick())
+		}
+		// Root-to-tip distance + sampling age is the age of the root
+		dists := make(map[string]float64)
+		tipDistances(tr.Root(), nil, 0, dists)
+		age := -1.0
+		for name, d := range dists {
+			if age < 0 {
+				age = d + ages[name]
+			}
+			if math.Abs(d+ages[name]-age) > 1e-9 {
+				t.Errorf("Wrong branch lengths of coalescent tree: %s", tr.Newick())
+				break
+			}
+		}
+		mean += age / 4000.0
+	}
+	return mean
+}
+
+func TestCoalescentTree(t *testing.T) {
+	rand.Seed(10)
+	samples := make([]tree.CoalescentSample, 10)
+	for i := range samples {
+		samples[i] = tree.CoalescentSample{Name: "Tip" + string(rune('A'+i))}
+	}
+	// Kingman coalescent: E[TMRCA] = 2.N.(1-1/n)
+	if mean := meanCoalescentRootAge(t, samples, 2, 0, 1, 0); math.Abs(mean-3.6) > 0.1 {
+		t.Errorf("Wrong mean TMRCA of Kingman coalescent: %f, expected 3.6", mean)
+	}
+
+	// Exponential growth, 2 samples: E[T] = e.E1(1)
+	if mean := meanCoalescentRootAge(t, samples[:2], 1, 1, 1, 0); math.Abs(mean-0.5963) > 0.02 {
+		t.Errorf("Wrong mean TMRCA under exponential growth: %f, expected 0.5963", mean)
+	}
+
+	// Heterochronous sampling: the second sample is older than the expected
+	// TMRCA, so it must be the root age plus an exponential time of mean N
+	hetero := []tree.CoalescentSample{{Name: "A", Age: 0}, {Name: "B", Age: 5}}
+	if mean := meanCoalescentRootAge(t, hetero, 1, 0, 1, 0); mean < 5.9 || mean > 6.1 {
+		t.Errorf("Wrong mean TMRCA with heterochronous sampling: %f, expected 6", mean)
+	}
+
+	// Old heterochronous sample with a strong growth: the population is tiny
+	// at the age of the old sample, which coalesces almost immediately
+	old := []tree.CoalescentSample{{Name: "A", Age: 0}, {Name: "B", Age: 0}, {Name: "C", Age: 100}}
+	if mean := meanCoalescentRootAge(t, old, 1, 8, 1, 0); mean < 100 || mean > 100+1e-6 {
+		t.Errorf("Wrong mean TMRCA with an old sample and exponential growth: %f, expected 100", mean)
+	}
+}
+
+func TestStructuredCoalescentTree(t *testing.T) {
+	rand.Seed(10)
+	// Island model with 2 demes, N=1, m=0.5: E[T] = 2 for 2 samples of the same
+	// deme, and 3 for 2 samples of different demes
+	same := []tree.CoalescentSample{{Name: "A", Deme: 0}, {Name: "B", Deme: 0}}
+	if mean := meanCoalescentRootAge(t, same, 1, 0, 2, 0.5); math.Abs(mean-2) > 0.1 {
+		t.Errorf("Wrong mean TMRCA of 2 samples of the same deme: %f, expected 2", mean)
+	}
+	diff := []tree.CoalescentSample{{Name: "A", Deme: 0}, {Name: "B", Deme: 1}}
+	if mean := meanCoalescentRootAge(t, diff, 1, 0, 2, 0.5); math.Abs(mean-3) > 0.15 {
+		t.Errorf("Wrong mean TMRCA of 2 samples of different demes: %f, expected 3", mean)
+	}
+
+	if _, err := tree.RandomCoalescentTree(diff, 1, 0, 2, 0); err == nil {
+		t.Errorf("Structured coalescent without migration should return an error")
+	}
+	if _, err := tree.RandomCoalescentTree(diff, 1, 0, 1, 0); err == nil {
+		t.Errorf("Sample with a wrong deme should return an error")
+	}
+}
@@ -0,0 +1,294 @@
+package tests
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/asr"
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/likelihood"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Builds a random alignment of the given length for the tips of the tree
+func randomAlignment(tr *tree.Tree, alphabet int, chars []byte, length int) (al align.Alignment, err error) {
+	al = align.NewAlign(alphabet)
+	for _, tip := range tr.Tips() {
+		seq := make([]byte, length)
+		for j := range seq {
+			seq[j] = chars[rand.Intn(len(chars))]
+		}
+		if err = al.AddSequence(tip.Name(), string(seq), ""); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func testLogLikelihood(t *testing.T, tr *tree.Tree, al align.Alignment, m *likelihood.Model) float64 {
+	lnl, err := likelihood.LogLikelihood(tr, al, m)
+	if err != nil {
+		t.Error(err)
+	}
+	return lnl
+}
+
+// Tests the likelihood of 2 sequences under JC69 against its analytical value
+func TestLikelihoodJC69(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("(A:0.1,B:0.2);")).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	al := align.NewAlign(align.NUCLEOTIDS)
+	al.AddSequence("A", "ACGTA", "")
+	al.AddSequence("B", "ACGAN", "")
+	m, err := likelihood.NewJC69Model()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	e := math.Exp(-4.0 / 3.0 * 0.3)
+	expected := 3*math.Log(0.25*(0.25+0.75*e)) + math.Log(0.25*(0.25-0.25*e)) + math.Log(0.25)
+	if lnl := testLogLikelihood(t, tr, al, m); math.Abs(lnl-expected) > 1e-10 {
+		t.Error(fmt.Errorf("JC69 log likelihood should be %f and is %f", expected, lnl))
+	}
+}
+
+// Tests that nested models give the same likelihoods, and that the
+// likelihood does not depend on the root of the tree
+func TestLikelihoodModels(t *testing.T) {
+	var nucl = []byte{'A', 'C', 'G', 'T', 'R', 'N', '-'}
+	tr, err := tree.RandomYuleBinaryTree(30, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	al, err := randomAlignment(tr, align.NUCLEOTIDS, nucl, 200)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	equal := []float64{0.25, 0.25, 0.25, 0.25}
+
+	jc, _ := likelihood.NewJC69Model()
+	k80one, _ := likelihood.NewK80Model(1.0)
+	k80, _ := likelihood.NewK80Model(4.0)
+	hky, _ := likelihood.NewHKYModel(4.0, equal)
+	gtr, _ := likelihood.NewGTRModel([]float64{1, 4, 1, 1, 4, 1}, equal)
+	gtrjc, _ := likelihood.NewGTRModel([]float64{1, 1, 1, 1, 1, 1}, equal)
+
+	lnljc := testLogLikelihood(t, tr, al, jc)
+	if lnljc >= 0 || math.IsNaN(lnljc) || math.IsInf(lnljc, 0) {
+		t.Error(fmt.Errorf("JC69 log likelihood is not valid: %f", lnljc))
+	}
+	if lnl := testLogLikelihood(t, tr, al, k80one); math.Abs(lnl-lnljc) > 1e-6 {
+		t.Error(fmt.Errorf("K80 (kappa=1) log likelihood should be %f and is %f", lnljc, lnl))
+	}
+	if lnl := testLogLikelihood(t, tr, al, gtrjc); math.Abs(lnl-lnljc) > 1e-6 {
+		t.Error(fmt.Errorf("GTR (equal rates) log likelihood should be %f and is %f", lnljc, lnl))
+	}
+	lnlk80 := testLogLikelihood(t, tr, al, k80)
+	if lnl := testLogLikelihood(t, tr, al, hky); math.Abs(lnl-lnlk80) > 1e-6 {
+		t.Error(fmt.Errorf("HKY (equal freqs) log likelihood should be %f and is %f", lnlk80, lnl))
+	}
+	if lnl := testLogLikelihood(t, tr, al, gtr); math.Abs(lnl-lnlk80) > 1e-6 {
+		t.Error(fmt.Errorf("GTR (K80 rates) log likelihood should be %f and is %f", lnlk80, lnl))
+	}
+
+	tr.UnRoot()
+	if lnl := testLogLikelihood(t, tr, al, jc); math.Abs(lnl-lnljc) > 1e-6 {
+		t.Error(fmt.Errorf("Unrooted tree log likelihood should be %f and is %f", lnljc, lnl))
+	}
+}
+
+// Tests discrete gamma rate heterogeneity
+func TestLikelihoodGamma(t *testing.T) {
+	var nucl = []byte{'A', 'C', 'G', 'T'}
+	tr, err := tree.RandomYuleBinaryTree(20, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	al, err := randomAlignment(tr, align.NUCLEOTIDS, nucl, 100)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m, _ := likelihood.NewHKYModel(2.0, []float64{0.1, 0.2, 0.3, 0.4})
+	lnl := testLogLikelihood(t, tr, al, m)
+	if err = m.SetGamma(1e6, 4); err != nil {
+		t.Error(err)
+		return
+	}
+	if lnlg := testLogLikelihood(t, tr, al, m); math.Abs(lnlg-lnl) > 1e-2 {
+		t.Error(fmt.Errorf("Log likelihood with gamma (large alpha) should be close to %f and is %f", lnl, lnlg))
+	}
+	if err = m.SetGamma(0.5, 4); err != nil {
+		t.Error(err)
+		return
+	}
+	if lnlg := testLogLikelihood(t, tr, al, m); lnlg == lnl || math.IsNaN(lnlg) || lnlg >= 0 {
+		t.Error(fmt.Errorf("Log likelihood with gamma (alpha=0.5) is not valid: %f", lnlg))
+	}
+}
+
+// Tests that the likelihood is updated when branch lengths are modified
+func TestLikelihoodUpdate(t *testing.T) {
+	var aa = []byte{'A', 'R', 'N', 'D', 'C', 'Q', 'E', 'G', 'H', 'I', 'L', 'K', 'M', 'F', 'P', 'S', 'T', 'W', 'Y', 'V', 'X', 'B'}
+	tr, err := tree.RandomYuleBinaryTree(15, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	al, err := randomAlignment(tr, align.AMINOACIDS, aa, 50)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m, err := likelihood.NewProteinModel(likelihood.MODEL_LG, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	m.SetGamma(1.0, 4)
+	l, err := likelihood.NewLikelihood(tr, al, m)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	l.LogLikelihood()
+	for i, e := range tr.Edges() {
+		if i%3 == 0 {
+			e.SetLength(e.Length() * 2)
+		}
+	}
+	lnl := l.LogLikelihood()
+	if expected := testLogLikelihood(t, tr, al, m); math.Abs(lnl-expected) > 1e-6 {
+		t.Error(fmt.Errorf("Updated log likelihood should be %f and is %f", expected, lnl))
+	}
+}
+
+// Tests branch length optimization of 2 sequences under JC69
+// against the analytical maximum likelihood distance
+func TestOptimizeBranchLengthsJC69(t *testing.T) {
+	tr, err := newick.NewParser(strings.NewReader("(A,B);")).Parse()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	al := align.NewAlign(align.NUCLEOTIDS)
+	al.AddSequence("A", "ACGTACGTAAACGTACGTAA", "")
+	al.AddSequence("B", "ACGTACCTAAACCTACGTAA", "")
+	m, _ := likelihood.NewJC69Model()
+	if _, err = likelihood.OptimizeBranchLengths(tr, al, m, 0, 1e-8); err != nil {
+		t.Error(err)
+		return
+	}
+	expected := -0.75 * math.Log(1-4.0/3.0*0.1)
+	if sum := tr.SumBranchLengths(); math.Abs(sum-expected) > 1e-4 {
+		t.Error(fmt.Errorf("Optimized distance should be %f and is %f", expected, sum))
+	}
+}
+
+// Tests that branch lengths are at a local optimum after optimization
+func TestOptimizeBranchLengths(t *testing.T) {
+	var nucl = []byte{'A', 'C'}
+	tr, err := tree.RandomYuleBinaryTree(20, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	al, err := randomAlignment(tr, align.NUCLEOTIDS, nucl, 300)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, e := range tr.Edges() {
+		e.SetLength(0.01)
+	}
+	m, _ := likelihood.NewK80Model(2.0)
+	m.SetGamma(1.0, 4)
+	initlnl := testLogLikelihood(t, tr, al, m)
+	lnl, err := likelihood.OptimizeBranchLengths(tr, al, m, 0, 1e-6)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if lnl < initlnl {
+		t.Error(fmt.Errorf("Log likelihood after optimization (%f) is lower than the initial one (%f)", lnl, initlnl))
+	}
+	if final := testLogLikelihood(t, tr, al, m); math.Abs(final-lnl) > 1e-6 {
+		t.Error(fmt.Errorf("Returned log likelihood (%f) is different from the likelihood of the optimized tree (%f)", lnl, final))
+	}
+	for _, e := range tr.Edges() {
+		l := e.Length()
+		for _, f := range []float64{0.9, 1.1} {
+			e.SetLength(l * f)
+			if other := testLogLikelihood(t, tr, al, m); other > lnl+1e-3 {
+				t.Error(fmt.Errorf("Branch length %f is not optimal: %f > %f", l, other, lnl))
+			}
+		}
+		e.SetLength(l)
+	}
+}
+
+// Tests marginal maximum likelihood ancestral sequence reconstruction
+func TestMarginalAsr(t *testing.T) {
+	tr, err := tree.RandomYuleBinaryTree(20, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, e := range tr.Edges() {
+		e.SetLength(0.01)
+	}
+	al := align.NewAlign(align.NUCLEOTIDS)
+	for i, tip := range tr.Tips() {
+		seq := []byte("ACGTACGTNN")
+		if i%5 == 0 {
+			// Some noise
+			seq[0] = 'C'
+		}
+		al.AddSequence(tip.Name(), string(seq), "")
+	}
+	m, _ := likelihood.NewHKYModel(2.0, []float64{0.25, 0.25, 0.25, 0.25})
+	posteriors, lnl, err := asr.MarginalAsr(tr, al, m)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if expected := testLogLikelihood(t, tr, al, m); math.Abs(lnl-expected) > 1e-6 {
+		t.Error(fmt.Errorf("Log likelihood should be %f and is %f", expected, lnl))
+	}
+	for _, n := range tr.Nodes() {
+		if n.Tip() {
+			continue
+		}
+		if len(posteriors[n.Id()]) != al.Length() {
+			t.Error(fmt.Errorf("There should be %d sites of posteriors and there are %d", al.Length(), len(posteriors[n.Id()])))
+			return
+		}
+		for site, post := range posteriors[n.Id()] {
+			sum := 0.0
+			for _, p := range post {
+				sum += p
+			}
+			if math.Abs(sum-1.0) > 1e-10 {
+				t.Error(fmt.Errorf("Posteriors of site %d should sum to 1 and sum to %f", site, sum))
+			}
+		}
+		// Unambiguous sites are reconstructed with high confidence
+		comments := n.Comments()
+		if len(comments) != 1 || comments[0][1:8] != "CGTACGT" {
+			t.Error(fmt.Errorf("Reconstructed sequence is not valid: %v", comments))
+		}
+		if p := posteriors[n.Id()][3][3]; p < 0.99 {
+			t.Error(fmt.Errorf("Posterior of T at site 3 should be close to 1 and is %f", p))
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package tests
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Dates given in the comments of the nodes, indexed by the comma
+// separated sorted names of the tips under them
+func lsdNodeDates(t *testing.T, n, prev *tree.Node, dates map[string]float64) []string {
+	tips := make([]string, 0)
+	if n.Tip() {
+		tips = append(tips, n.Name())
+	}
+	for _, next := range n.Neigh() {
+		if next != prev {
+			tips = append(tips, lsdNodeDates(t, next, n, dates)...)
+		}
+	}
+	sort.Strings(tips)
+	if len(n.Comments()) != 1 || !strings.HasPrefix(n.Comments()[0], "date=") {
+		t.Fatalf("Wrong date comment: %v", n.Comments())
+	}
+	d, err := strconv.ParseFloat(strings.TrimPrefix(n.Comments()[0], "date="), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates[strings.Join(tips, ",")] = d
+	return tips
+}
+
+func TestLSDDatingClock(t *testing.T) {
+	// Strict clock with rate 0.01 and root date 2000
+	tr, err := newick.NewParser(strings.NewReader("(((A:0.05,B:0.07):0.03,C:0.1):0.04,(D:0.08,E:0.02):0.1);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraints := []tree.DateConstraint{
+		{Tips: []string{"A"}, Min: 2012, Max: 2012},
+		{Tips: []string{"B"}, Min: 2014, Max: 2014},
+		{Tips: []string{"C"}, Min: 2014, Max: 2014},
+		{Tips: []string{"D"}, Min: 2018, Max: 2018},
+		{Tips: []string{"E"}, Min: 2012, Max: 2012},
+	}
+	stats, err := tr.LSDDating(constraints, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(stats.Rate-0.01) > 1e-9 || math.Abs(stats.RootDate-2000) > 1e-6 || stats.Objective > 1e-12 {
+		t.Errorf("Wrong dating: rate=%f, root=%f, objective=%f", stats.Rate, stats.RootDate, stats.Objective)
+	}
+	dates := make(map[string]float64)
+	lsdNodeDates(t, tr.Root(), nil, dates)
+	expected := map[string]float64{"A,B": 2007, "A,B,C": 2004, "D,E": 2010, "A,B,C,D,E": 2000}
+	for clade, d := range expected {
+		if math.Abs(dates[clade]-d) > 1e-6 {
+			t.Errorf("Wrong date for %s: %f, expected %f", clade, dates[clade], d)
+		}
+	}
+	for _, e := range tr.Edges() {
+		if e.Right().Name() == "D" && math.Abs(e.Length()-8) > 1e-6 {
+			t.Errorf("Wrong time length for D: %f", e.Length())
+		}
+	}
+}
+
+func TestLSDDatingConstraints(t *testing.T) {
+	// With rate 1, the unconstrained dates are 8.2 for the MRCA of A and B,
+	// and 7.6 for the root. With the MRCA of A and B not older than 8.5,
+	// the root is at ((8.5-1)+(12-4))/2 = 7.75
+	tr, err := newick.NewParser(strings.NewReader("((A:1,B:3):1,C:4);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraints := []tree.DateConstraint{
+		{Tips: []string{"A"}, Min: 10, Max: 10},
+		{Tips: []string{"B"}, Min: 10, Max: 10},
+		{Tips: []string{"C"}, Min: 12, Max: 12},
+	}
+	c := tr.Clone()
+	if _, err = c.LSDDating(constraints, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	dates := make(map[string]float64)
+	lsdNodeDates(t, c.Root(), nil, dates)
+	if math.Abs(dates["A,B"]-8.2) > 1e-9 || math.Abs(dates["A,B,C"]-7.6) > 1e-9 {
+		t.Errorf("Wrong unconstrained dates: %s", c.Newick())
+	}
+
+	constraints = append(constraints, tree.DateConstraint{Tips: []string{"A", "B"}, Min: 8.5, Max: math.Inf(1)})
+	c = tr.Clone()
+	if _, err = c.LSDDating(constraints, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	dates = make(map[string]float64)
+	lsdNodeDates(t, c.Root(), nil, dates)
+	if math.Abs(dates["A,B"]-8.5) > 1e-9 || math.Abs(dates["A,B,C"]-7.75) > 1e-9 {
+		t.Errorf("Wrong constrained dates: %s", c.Newick())
+	}
+
+	// Incompatible constraints: MRCA of A and B after A
+	constraints = append(constraints, tree.DateConstraint{Tips: []string{"A", "B"}, Min: 11, Max: 11})
+	if _, err = tr.Clone().LSDDating(constraints, 1, 0); err == nil {
+		t.Errorf("Incompatible constraints should return an error")
+	}
+
+	// Unrooted tree
+	tr.UnRoot()
+	if _, err = tr.LSDDating(constraints[:3], 1, 0); err == nil {
+		t.Errorf("Dating an unrooted tree should return an error")
+	}
+}
+
+// Minimum of f over [lo,hi]^k, by grid search refined around the best point
+func lsdBruteForce(f func(x []float64) float64, k int, lo, hi float64) (best float64) {
+	center := make([]float64, k)
+	for i := range center {
+		center[i] = (lo + hi) / 2
+	}
+	half, steps := (hi-lo)/2, 50
+	for round := 0; round < 6; round++ {
+		bestx := append([]float64{}, center...)
+		best = math.Inf(1)
+		x := make([]float64, k)
+		var grid func(i int)
+		grid = func(i int) {
+			if i == k {
+				if v := f(x); v < best {
+					best = v
+					copy(bestx, x)
+				}
+				return
+			}
+			for s := -steps; s <= steps; s++ {
+				x[i] = center[i] + half*float64(s)/float64(steps)
+				grid(i + 1)
+			}
+		}
+		grid(0)
+		center = bestx
+		half, steps = 4*half/float64(steps), 20
+	}
+	return
+}
+
+func TestLSDDatingActiveConstraints(t *testing.T) {
+	// The unconstrained dates violate the temporal constraints
+	// t(root) <= t(A,B,C) <= t(A,B): the constrained optimum
+	// is compared to a brute force search of the feasible dates
+	tr, err := newick.NewParser(strings.NewReader("(((A:60,B:60):0,C:0):0,D:5);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraints := []tree.DateConstraint{
+		{Tips: []string{"A"}, Min: 50, Max: 50},
+		{Tips: []string{"B"}, Min: 50, Max: 50},
+		{Tips: []string{"C"}, Min: 100, Max: 100},
+		{Tips: []string{"D"}, Min: 60, Max: 60},
+	}
+	stats, err := tr.LSDDating(constraints, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// x = (t(A,B), t(A,B,C), t(root))
+	expected := lsdBruteForce(func(x []float64) float64 {
+		if x[2] > x[1] || x[1] > x[0] || x[0] > 50 {
+			return math.Inf(1)
+		}
+		return 2*math.Pow(60-(50-x[0]), 2) + math.Pow(x[0]-x[1], 2) + math.Pow(100-x[1], 2) +
+			math.Pow(x[1]-x[2], 2) + math.Pow(5-(60-x[2]), 2)
+	}, 3, 0, 100)
+	if math.Abs(stats.Objective-expected) > 1e-6*expected {
+		t.Errorf("Wrong constrained objective: %f, expected %f", stats.Objective, expected)
+	}
+	dates := make(map[string]float64)
+	lsdNodeDates(t, tr.Root(), nil, dates)
+	if dates["A,B,C,D"] > dates["A,B,C"]+1e-9 || dates["A,B,C"] > dates["A,B"]+1e-9 || dates["A,B"] > 50+1e-9 {
+		t.Errorf("Dates violate temporal constraints: %s", tr.Newick())
+	}
+
+	// With an interval constraint on the MRCA of A, B and C
+	tr, err = newick.NewParser(strings.NewReader("(((A:60,B:60):0,C:0):0,D:5);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	constraints = append(constraints, tree.DateConstraint{Tips: []string{"A", "B", "C"}, Min: 10, Max: 20})
+	if stats, err = tr.LSDDating(constraints, 1, 0); err != nil {
+		t.Fatal(err)
+	}
+	expected = lsdBruteForce(func(x []float64) float64 {
+		if x[2] > x[1] || x[1] > x[0] || x[0] > 50 || x[1] < 10 || x[1] > 20 {
+			return math.Inf(1)
+		}
+		return 2*math.Pow(60-(50-x[0]), 2) + math.Pow(x[0]-x[1], 2) + math.Pow(100-x[1], 2) +
+			math.Pow(x[1]-x[2], 2) + math.Pow(5-(60-x[2]), 2)
+	}, 3, 0, 100)
+	if math.Abs(stats.Objective-expected) > 1e-6*expected {
+		t.Errorf("Wrong constrained objective: %f, expected %f", stats.Objective, expected)
+	}
+}
+
+func TestLSDDatingNoTemporalSignal(t *testing.T) {
+	// The most recent tips are the closest to the root: the analytical
+	// rate is negative, and the estimated rate is compared to a scan
+	// of the rates
+	nw := "((A:9.269868,B:9.549454):3.479540,(C:6.908388,D:7.109072):5.637796);"
+	constraints := []tree.DateConstraint{
+		{Tips: []string{"A"}, Min: 16.4949, Max: 16.4949},
+		{Tips: []string{"B"}, Min: 15.5177, Max: 15.5177},
+		{Tips: []string{"C"}, Min: 17.5582, Max: 17.5582},
+		{Tips: []string{"D"}, Min: 14.0380, Max: 14.0380},
+		{Tips: []string{"A", "B"}, Min: 2.613, Max: 7.543},
+	}
+	dating := func(rate float64) tree.LSDStats {
+		tr, err := newick.NewParser(strings.NewReader(nw)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := tr.LSDDating(constraints, rate, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return stats
+	}
+	stats := dating(0)
+	best := math.Inf(1)
+	for rate := 0.05; rate <= 10; rate += 0.005 {
+		best = math.Min(best, dating(rate).Objective)
+	}
+	if stats.Objective > best+1e-6 {
+		t.Errorf("Wrong estimated rate %f: objective %f, while a scan of the rates reaches %f", stats.Rate, stats.Objective, best)
+	}
+}
@@ -0,0 +1,133 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Ancestor deviation and variance of root-to-tip distances of a root, given
+// its distances to the tips, and the distances between tips. The relative
+// deviation of the ancestor of x and y is (d(x,root) - d(y,root))/d(x,y)
+func rootingScores(droot map[string]float64, dtips map[string]map[string]float64) (mad, variance float64) {
+	var sum, sumsq, npairs float64
+	for x, dx := range droot {
+		sum += dx
+		sumsq += dx * dx
+		for y, dy := range droot {
+			if x < y {
+				r := (dx - dy) / dtips[x][y]
+				mad += r * r
+				npairs++
+			}
+		}
+	}
+	n := float64(len(droot))
+	return math.Sqrt(mad / npairs), sumsq/n - (sum/n)*(sum/n)
+}
+
+// Minimum ancestor deviation and variance over a grid of root positions
+// on all the branches of the tree
+func bruteForceRooting(tr *tree.Tree, dtips map[string]map[string]float64) (mad, variance float64) {
+	mad, variance = math.Inf(1), math.Inf(1)
+	for _, e := range tr.Edges() {
+		dleft := make(map[string]float64)
+		dright := make(map[string]float64)
+		tipDistances(e.Left(), e.Right(), 0, dleft)
+		tipDistances(e.Right(), e.Left(), 0, dright)
+		for i := 0; i <= 100; i++ {
+			pos := e.Length() * float64(i) / 100.0
+			droot := make(map[string]float64)
+			for x, d := range dleft {
+				droot[x] = d + pos
+			}
+			for x, d := range dright {
+				droot[x] = d + e.Length() - pos
+			}
+			m, v := rootingScores(droot, dtips)
+			mad = math.Min(mad, m)
+			variance = math.Min(variance, v)
+		}
+	}
+	return
+}
+
+func TestRerootMADMinVarClock(t *testing.T) {
+	// Ultrametric tree: the true root has no deviation, and no variance
+	for _, reroot := range []string{"mad", "minvar"} {
+		tr, err := newick.NewParser(strings.NewReader("((A:1,B:1):1,C:2,(D:1.5,E:1.5):2.5);")).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var score float64
+		if reroot == "mad" {
+			score, _, err = tr.RerootMAD()
+		} else {
+			score, err = tr.RerootMinVar()
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if score > 1e-6 || !tr.Rooted() {
+			t.Errorf("Wrong %s rooting: %s (%f)", reroot, tr.Newick(), score)
+		}
+		for _, e := range tr.Root().Edges() {
+			if l := e.Length(); math.Abs(l-1) > 1e-9 && math.Abs(l-1.5) > 1e-9 {
+				t.Errorf("Wrong %s root position: %s", reroot, tr.Newick())
+			}
+		}
+	}
+}
+
+func TestRerootMADMinVarRandom(t *testing.T) {
+	rand.Seed(10)
+	for it := 0; it < 10; it++ {
+		tr, err := tree.RandomYuleBinaryTree(12, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dtips := make(map[string]map[string]float64)
+		for _, tip := range tr.Tips() {
+			dtips[tip.Name()] = make(map[string]float64)
+			tipDistances(tip, nil, 0, dtips[tip.Name()])
+		}
+		brutemad, brutevar := bruteForceRooting(tr, dtips)
+
+		c := tr.Clone()
+		mad, ai, err := c.RerootMAD()
+		if err != nil {
+			t.Fatal(err)
+		}
+		droot := make(map[string]float64)
+		tipDistances(c.Root(), nil, 0, droot)
+		m, _ := rootingScores(droot, dtips)
+		if math.Abs(m-mad) > 1e-9 {
+			t.Errorf("Wrong MAD of the rerooted tree: %f, expected %f", mad, m)
+		}
+		if mad > brutemad+1e-9 {
+			t.Errorf("MAD rooting is not optimal: %f > %f", mad, brutemad)
+		}
+		if ai <= 0 || ai > 1 {
+			t.Errorf("Wrong root ambiguity index: %f", ai)
+		}
+
+		c = tr.Clone()
+		variance, err := c.RerootMinVar()
+		if err != nil {
+			t.Fatal(err)
+		}
+		droot = make(map[string]float64)
+		tipDistances(c.Root(), nil, 0, droot)
+		_, v := rootingScores(droot, dtips)
+		if math.Abs(v-variance) > 1e-9 {
+			t.Errorf("Wrong variance of the rerooted tree: %f, expected %f", variance, v)
+		}
+		if variance > brutevar+1e-9 {
+			t.Errorf("MinVar rooting is not optimal: %f > %f", variance, brutevar)
+		}
+	}
+}
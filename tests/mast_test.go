@@ -0,0 +1,125 @@
+package tests
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Returns true if the two trees restricted to the given tips have the same topology.
+// Rooted trees are compared as unrooted trees with an outgroup attached to their root
+func agree(t *testing.T, t1, t2 *tree.Tree, tips []string, rooted bool) bool {
+	if len(tips) <= 2 || (!rooted && len(tips) <= 3) {
+		return true
+	}
+	c1, c2 := t1.Clone(), t2.Clone()
+	keep := append([]string{}, tips...)
+	if rooted {
+		for _, c := range []*tree.Tree{c1, c2} {
+			og := c.NewNode()
+			og.SetName("__outgroup__")
+			c.ConnectNodes(c.Root(), og)
+		}
+		keep = append(keep, "__outgroup__")
+	}
+	for _, c := range []*tree.Tree{c1, c2} {
+		if err := c.RemoveTips(true, keep...); err != nil {
+			t.Fatal(err)
+		}
+		if err := c.ReinitIndexes(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	rf, _, err := tree.RobinsonFoulds(c1, c2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rf == 0
+}
+
+// Size of the largest subset of tips on which the two trees agree
+func naiveMastSize(t *testing.T, t1, t2 *tree.Tree, rooted bool) int {
+	names := t1.AllTipNames()
+	best := 0
+	for mask := 1; mask < 1<<uint(len(names)); mask++ {
+		tips := make([]string, 0)
+		for i, n := range names {
+			if mask&(1<<uint(i)) != 0 {
+				tips = append(tips, n)
+			}
+		}
+		if len(tips) > best && agree(t, t1, t2, tips, rooted) {
+			best = len(tips)
+		}
+	}
+	return best
+}
+
+func collapseShort(t *tree.Tree, rooted bool) *tree.Tree {
+	c := t.Clone()
+	c.CollapseShortBranches(0.05, false, false)
+	if rooted && !c.Rooted() {
+		return t
+	}
+	return c
+}
+
+func TestMaximumAgreementSubtree(t *testing.T) {
+	for _, rooted := range []bool{false, true} {
+		for i := 0; i < 30; i++ {
+			t1, err := tree.RandomYuleBinaryTree(8, rooted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			t2, err := tree.RandomYuleBinaryTree(8, rooted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Some multifurcations (keeping rooted trees rooted)
+			if i%3 == 0 {
+				t1 = collapseShort(t1, rooted)
+			}
+			if i%4 == 0 {
+				t2 = collapseShort(t2, rooted)
+			}
+			expected := naiveMastSize(t, t1, t2, rooted)
+
+			mast, removed1, removed2, err := tree.MaximumAgreementSubtree(t1, t2, rooted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tips := mast.AllTipNames()
+			if len(tips) != expected {
+				t.Errorf("Rooted=%t, trees %s and %s: MAST has %d tips and should have %d (%s)",
+					rooted, t1.Newick(), t2.Newick(), len(tips), expected, mast.Newick())
+			}
+			if !agree(t, t1, t2, tips, rooted) {
+				t.Errorf("Rooted=%t, trees %s and %s: %s is not an agreement subtree",
+					rooted, t1.Newick(), t2.Newick(), mast.Newick())
+			}
+			if len(removed1) != 8-len(tips) || len(removed2) != 8-len(tips) {
+				t.Errorf("Rooted=%t: wrong number of removed tips: %v, %v", rooted, removed1, removed2)
+			}
+		}
+	}
+}
+
+func TestMaximumAgreementSubtreeDifferentTips(t *testing.T) {
+	t1 := parseAndIndex(t, "((A:1,B:1):1,(C:1,D:1):1,(E:1,F:1):1);")
+	t2 := parseAndIndex(t, "((A,C),(B,D),(E,(F,G)));")
+
+	mast, removed1, removed2, err := tree.MaximumAgreementSubtree(t1, t2, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tips := mast.AllTipNames()
+	sort.Strings(removed2)
+	if len(tips) != 4 || len(removed1) != 2 || len(removed2) != 3 || removed2[2] != "G" {
+		t.Errorf("Wrong MAST %s, removed tips: %v and %v", mast.Newick(), removed1, removed2)
+	}
+
+	if _, _, _, err = tree.MaximumAgreementSubtree(t1, t2, true); err == nil {
+		t.Errorf("Rooted MAST of unrooted trees should return an error")
+	}
+}
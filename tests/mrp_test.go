@@ -0,0 +1,77 @@
+package tests
+
+import (
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestMRPMatrix(t *testing.T) {
+	taxa, matrix, err := tree.MRPMatrix(treeChannel(t,
+		"(((A,B),C),(D,E));",
+		"((A,(C,F)),(B,G));",
+		"((A,B,C),D,E);"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expTaxa := []string{"A", "B", "C", "D", "E", "F", "G"}
+	if strings.Join(taxa, ",") != strings.Join(expTaxa, ",") {
+		t.Fatalf("Wrong MRP taxa: %v", taxa)
+	}
+
+	// Characters of each tree, as the lists of taxa having each state.
+	// Both root branches of rooted trees define the same bipartition
+	expected := []string{
+		"1:A,B 0:C,D,E ?:F,G",
+		"1:A,B,C 0:D,E ?:F,G",
+		"1:A,B,G 0:C,F ?:D,E",
+		"1:A,C,F 0:B,G ?:D,E",
+		"1:A,B,C 0:D,E ?:F,G",
+	}
+	ntrees := []int{2, 2, 1}
+	nchar := 0
+	for _, n := range ntrees {
+		nchar += n
+	}
+	for i, seq := range matrix {
+		if len(seq) != nchar {
+			t.Fatalf("Wrong MRP sequence length for %s: %d, expected %d", taxa[i], len(seq), nchar)
+		}
+	}
+
+	chars := make([]string, 0, nchar)
+	for c := 0; c < nchar; c++ {
+		states := map[byte][]string{}
+		for i, seq := range matrix {
+			states[seq[c]] = append(states[seq[c]], taxa[i])
+		}
+		// Both codings of a bipartition are equivalent: A is given state 1
+		one, zero := states['1'], states['0']
+		if len(zero) > 0 && zero[0] == "A" {
+			one, zero = zero, one
+		}
+		chars = append(chars, "1:"+strings.Join(one, ",")+" 0:"+strings.Join(zero, ",")+" ?:"+strings.Join(states['?'], ","))
+	}
+
+	// Characters of each tree are compared without order
+	start := 0
+	for _, n := range ntrees {
+		got := append([]string{}, chars[start:start+n]...)
+		exp := append([]string{}, expected[start:start+n]...)
+		sort.Strings(got)
+		sort.Strings(exp)
+		if strings.Join(got, "|") != strings.Join(exp, "|") {
+			t.Errorf("Wrong MRP characters: %v, expected %v", got, exp)
+		}
+		start += n
+	}
+}
+
+func TestMRPMatrixNoCharacter(t *testing.T) {
+	// No internal bipartition: the matrix would have no character
+	if _, _, err := tree.MRPMatrix(treeChannel(t, "(A,B,C);", "((A,B),C);")); err == nil {
+		t.Errorf("MRP matrix of trees without internal bipartition should return an error")
+	}
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/utils"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+/*
+Generates random trees, computes their patristic distance matrices,
+and checks that NJ and BIONJ recover the exact same trees (topology
+and branch lengths), as the matrices are additive.
+*/
+func TestNJAdditive(t *testing.T) {
+	for _, algo := range []int{tree.NJ_ALGO_NJ, tree.NJ_ALGO_BIONJ} {
+		for i := 0; i < 10; i++ {
+			tr, err := tree.RandomYuleBinaryTree(50, false)
+			if err != nil {
+				t.Error(err)
+			}
+			names := make([]string, 0, 50)
+			for _, tip := range tr.Tips() {
+				names = append(names, tip.Name())
+			}
+			mat := tr.ToDistanceMatrix()
+
+			var inferred *tree.Tree
+			if algo == tree.NJ_ALGO_NJ {
+				inferred, err = tree.NeighborJoining(names, mat)
+			} else {
+				inferred, err = tree.BioNJ(names, mat)
+			}
+			if err != nil {
+				t.Error(err)
+			}
+			if err = tr.ReinitIndexes(); err != nil {
+				t.Error(err)
+			}
+			if inferred.Rooted() {
+				t.Error("Inferred tree should not be rooted")
+			}
+			tree1, common, err := tr.CommonEdges(inferred, false)
+			if err != nil {
+				t.Error(err)
+			}
+			if tree1 != 0 || common != 47 {
+				t.Error(fmt.Sprintf("Inferred tree does not have the same topology (algo %d): %d specific, %d common", algo, tree1, common))
+			}
+			if math.Abs(tr.SumBranchLengths()-inferred.SumBranchLengths()) > 1e-9 {
+				t.Error(fmt.Sprintf("Inferred tree does not have the same total length (algo %d): %f vs. %f", algo, tr.SumBranchLengths(), inferred.SumBranchLengths()))
+			}
+		}
+	}
+}
+
+func TestNJErrors(t *testing.T) {
+	if _, err := tree.NeighborJoining([]string{"A"}, [][]float64{{0}}); err == nil {
+		t.Error("NJ with one taxon should return an error")
+	}
+	if _, err := tree.BioNJ([]string{"A", "B", "C"}, [][]float64{{0, 1}, {1, 0}}); err == nil {
+		t.Error("BIONJ with wrong matrix dimension should return an error")
+	}
+}
+
+func TestReadDistanceMatrixLayouts(t *testing.T) {
+	expected := [][]float64{{0, 1, 2}, {1, 0, 3}, {2, 3, 0}}
+	layouts := map[string]string{
+		"square":              "3\nA 0 1 2\nB 1 0 3\nC 2 3 0\n",
+		"lower triangular":    "3\nA\nB 1\nC 2 3\n",
+		"lower with diagonal": "3\nA 0\nB 1 0\nC 2 3 0\n",
+	}
+	for layout, input := range layouts {
+		names, matrix, err := utils.ReadDistanceMatrixReader(bufio.NewReader(strings.NewReader(input)))
+		if err != nil {
+			t.Fatalf("%s: %v", layout, err)
+		}
+		if strings.Join(names, ",") != "A,B,C" {
+			t.Errorf("%s: wrong names %v", layout, names)
+		}
+		for i := range expected {
+			for j := range expected[i] {
+				if matrix[i][j] != expected[i][j] {
+					t.Errorf("%s: wrong distance (%d,%d): %f, expected %f", layout, i, j, matrix[i][j], expected[i][j])
+				}
+			}
+		}
+	}
+
+	// The layout is given by the first line
+	if _, _, err := utils.ReadDistanceMatrixReader(bufio.NewReader(strings.NewReader("3\nA\nB 1 0\nC 2 3 0\n"))); err == nil {
+		t.Error("Reading a distance matrix with inconsistent layouts should return an error")
+	}
+}
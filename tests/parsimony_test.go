@@ -0,0 +1,140 @@
+package tests
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/evolbioinfo/goalign/align"
+	"github.com/evolbioinfo/gotree/asr"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Builds an alignment in which each internal edge of the tree
+// is supported by nbsites sites (A on one side, C on the other):
+// the tree is then the single most parsimonious tree, with a score
+// equal to the number of sites.
+func splitAlignment(tr *tree.Tree, nbsites int) (al align.Alignment, nsites int, err error) {
+	var seqs map[string][]byte = make(map[string][]byte)
+	for _, tip := range tr.Tips() {
+		seqs[tip.Name()] = make([]byte, 0)
+	}
+	for _, e := range tr.Edges() {
+		if e.Right().Tip() {
+			continue
+		}
+		right := make(map[string]bool)
+		for _, tip := range tr.Tips() {
+			right[tip.Name()] = e.TipPresent(uint(tip.TipIndex()))
+		}
+		for i := 0; i < nbsites; i++ {
+			for name := range seqs {
+				if right[name] {
+					seqs[name] = append(seqs[name], 'A')
+				} else {
+					seqs[name] = append(seqs[name], 'C')
+				}
+			}
+			nsites++
+		}
+	}
+	al = align.NewAlign(align.NUCLEOTIDS)
+	for name, seq := range seqs {
+		if err = al.AddSequence(name, string(seq), ""); err != nil {
+			return
+		}
+	}
+	return
+}
+
+// Tests that the parsimony score is the sum of steps given by ParsimonyAsr
+func TestParsimonyScore(t *testing.T) {
+	var tr *tree.Tree
+	var err error
+	var nsteps []int
+	var score int
+	var nucl = []byte{'A', 'C', 'G', 'T', 'R', 'N', '-'}
+
+	for i := 0; i < 10; i++ {
+		if tr, err = tree.RandomYuleBinaryTree(20, i%2 == 0); err != nil {
+			t.Error(err)
+			return
+		}
+		al := align.NewAlign(align.NUCLEOTIDS)
+		for _, tip := range tr.Tips() {
+			seq := make([]byte, 100)
+			for j := range seq {
+				seq[j] = nucl[rand.Intn(len(nucl))]
+			}
+			if err = al.AddSequence(tip.Name(), string(seq), ""); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+		if score, err = asr.ParsimonyScore(tr, al); err != nil {
+			t.Error(err)
+			return
+		}
+		if nsteps, err = asr.ParsimonyAsr(tr, al, asr.ALGO_ACCTRAN, false); err != nil {
+			t.Error(err)
+			return
+		}
+		total := 0
+		for _, s := range nsteps {
+			total += s
+		}
+		if total != score {
+			t.Error(fmt.Errorf("Parsimony score should be %d and is %d", total, score))
+		}
+	}
+}
+
+// Tests that the parsimony search finds the tree from which
+// the alignment has been built
+func TestParsimonySearch(t *testing.T) {
+	var truetree, tr *tree.Tree
+	var err error
+	var al align.Alignment
+	var nsites, score, initscore int
+
+	if truetree, err = tree.RandomYuleBinaryTree(12, false); err != nil {
+		t.Error(err)
+		return
+	}
+	if al, nsites, err = splitAlignment(truetree, 3); err != nil {
+		t.Error(err)
+		return
+	}
+
+	for _, r := range []tree.Rearranger{&tree.NNIRearranger{}, &tree.SPRRearranger{}} {
+		if tr, err = tree.RandomUniformBinaryTree(12, false); err != nil {
+			t.Error(err)
+			return
+		}
+		if initscore, err = asr.ParsimonyScore(tr, al); err != nil {
+			t.Error(err)
+			return
+		}
+		if score, err = asr.ParsimonySearch(tr, al, r); err != nil {
+			t.Error(err)
+			return
+		}
+		if score > initscore {
+			t.Error(fmt.Errorf("Parsimony score after search (%d) is worse than the initial score (%d)", score, initscore))
+		}
+		if s, _ := asr.ParsimonyScore(tr, al); s != score {
+			t.Error(fmt.Errorf("Parsimony score of the final tree (%d) is different from the returned score (%d)", s, score))
+		}
+		if err = tr.CheckTreePostOrder(); err != nil {
+			t.Error(err)
+		}
+		if _, ok := r.(*tree.SPRRearranger); ok {
+			if score != nsites {
+				t.Error(fmt.Errorf("Parsimony score after SPR search should be %d and is %d", nsites, score))
+			}
+			if _, common, _ := truetree.CommonEdges(tr, false); common != len(truetree.Tips())-3 {
+				t.Error(fmt.Errorf("Tree found by SPR search should be identical to the true tree (%d common edges)", common))
+			}
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package tests
+
+import (
+	"math"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestPathDifference(t *testing.T) {
+	t1 := parseAndIndex(t, "((A:1,B:1):1,C:1,(D:1,E:1):1);")
+	// Rooted version of t1
+	t2 := parseAndIndex(t, "(((A:1,B:1):1,C:1):0.4,(D:1,E:1):0.6);")
+	// Different topology
+	t3 := parseAndIndex(t, "((A:1,C:1):1,B:1,(D:1,E:1):1);")
+	// Different tips: common tips are A, B, C, D
+	t4 := parseAndIndex(t, "((A:1,B:2):1,(C:1,D:1):1,F:1);")
+
+	tests := []struct {
+		t2             *tree.Tree
+		topo, weighted float64
+		ncommon        int
+	}{
+		{t2, 0, 0, 5},
+		{t3, math.Sqrt(6), math.Sqrt(6), 5},
+		// Pruned t1: ((A:1,B:1):1,C:1,D:2) vs ((A:1,B:2):1,C:1,D:1), same topology
+		{t4, 0, math.Sqrt(8), 4},
+	}
+	for i, test := range tests {
+		topo, n, err := tree.PathDifference(t1, test.t2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != test.ncommon {
+			t.Errorf("Test %d: expected %d common tips, got %d", i, test.ncommon, n)
+		}
+		if math.Abs(topo-test.topo) > 1e-10 {
+			t.Errorf("Test %d: expected topological path difference %f, got %f", i, test.topo, topo)
+		}
+		weighted, _, err := tree.PathDifference(t1, test.t2, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(weighted-test.weighted) > 1e-10 {
+			t.Errorf("Test %d: expected weighted path difference %f, got %f", i, test.weighted, weighted)
+		}
+	}
+
+	// Input trees must not be modified
+	if len(t1.Tips()) != 5 || len(t4.Tips()) != 5 || !t2.Rooted() {
+		t.Errorf("Input trees have been modified")
+	}
+
+	mat, err := tree.PathDifferenceMatrix([]*tree.Tree{t1, t2, t3}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range mat {
+		for j := range mat {
+			if mat[i][j] != mat[j][i] || (i == j && mat[i][j] != 0) {
+				t.Errorf("Path difference matrix is not a distance matrix")
+			}
+		}
+	}
+	if math.Abs(mat[1][2]-math.Sqrt(6)) > 1e-10 {
+		t.Errorf("Expected path difference %f between trees 1 and 2, got %f", math.Sqrt(6), mat[1][2])
+	}
+}
+
+func TestPathDifferenceErrors(t *testing.T) {
+	t1 := parseAndIndex(t, "((A,B),C,(D,E));")
+	t2 := parseAndIndex(t, "((A,F),G,(H,E));")
+	t3 := parseAndIndex(t, "((A,B),C,(D,F));")
+
+	if _, _, err := tree.PathDifference(t1, t2, false); err == nil {
+		t.Errorf("An error should be returned when trees share less than 3 tips")
+	}
+	if _, _, err := tree.PathDifference(t1, t3, true); err == nil {
+		t.Errorf("An error should be returned when branch lengths are not defined")
+	}
+}
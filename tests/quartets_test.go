@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"strings"
 	"testing"
 
 	"github.com/evolbioinfo/gotree/hashmap"
@@ -227,3 +228,120 @@ func TestIndexQuartets3(t *testing.T) {
 		t.Error(fmt.Sprintf("There should be 5 quartets in the index, but: %d", l))
 	}
 }
+
+// Naive quartet comparison, using topological distances
+// (four point condition) for all quartets of tips
+func naiveQuartetStats(t1, t2 *tree.Tree) (stats tree.QuartetStats) {
+	topology := func(t *tree.Tree) func(a, b, c, d string) int {
+		c := t.Clone()
+		for _, e := range c.Edges() {
+			e.SetLength(1)
+		}
+		dist := c.ToDistanceMatrix()
+		index := make(map[string]int)
+		for i, tip := range c.Tips() {
+			index[tip.Name()] = i
+		}
+		return func(a, b, c, d string) int {
+			ia, ib, ic, id := index[a], index[b], index[c], index[d]
+			s := []float64{dist[ia][ib] + dist[ic][id], dist[ia][ic] + dist[ib][id], dist[ia][id] + dist[ib][ic]}
+			for i := range s {
+				if s[i] < s[(i+1)%3] && s[i] < s[(i+2)%3] {
+					return i
+				}
+			}
+			return -1
+		}
+	}
+	top1, top2 := topology(t1), topology(t2)
+	tips := t1.AllTipNames()
+	for a := 0; a < len(tips); a++ {
+		for b := a + 1; b < len(tips); b++ {
+			for c := b + 1; c < len(tips); c++ {
+				for d := c + 1; d < len(tips); d++ {
+					q1 := top1(tips[a], tips[b], tips[c], tips[d])
+					q2 := top2(tips[a], tips[b], tips[c], tips[d])
+					stats.Total++
+					switch {
+					case q1 == -1 && q2 == -1:
+						stats.UnresolvedBoth++
+					case q1 == -1:
+						stats.Unresolved1++
+					case q2 == -1:
+						stats.Unresolved2++
+					case q1 == q2:
+						stats.Shared++
+					default:
+						stats.Diff++
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+func TestQuartetDistance(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		t1, err := tree.RandomYuleBinaryTree(15, i%2 == 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t2, err := tree.RandomYuleBinaryTree(15, i%3 == 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Multifurcations
+		if i%4 > 1 {
+			t1.CollapseShortBranches(0.3, false, false)
+			t2.CollapseShortBranches(0.2, false, false)
+		}
+		stats, err := tree.QuartetDistance(t1, t2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := naiveQuartetStats(t1, t2)
+		if stats != expected {
+			t.Errorf("Quartet stats are %v and should be %v", stats, expected)
+		}
+		// The quartets of a tree with itself are all shared or unresolved
+		stats, err = tree.QuartetDistance(t1, t1.Clone())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats.Diff != 0 || stats.Unresolved1 != 0 || stats.Unresolved2 != 0 || stats.Shared+stats.UnresolvedBoth != stats.Total {
+			t.Errorf("Quartet stats of a tree with itself are %v", stats)
+		}
+	}
+}
+
+func TestQuartetDistanceHighDegree(t *testing.T) {
+	// Nodes of various degrees, so that buffers are reused with different sizes
+	trees := []string{
+		"(A,B,C,D,E,F,G,H,I,J);",
+		"((A,B,C,D,E),(F,G),H,(I,J));",
+		"(((A,B),(C,D)),((E,F),(G,H)),(I,J));",
+		"(A,(B,(C,(D,(E,(F,(G,(H,(I,J)))))))));",
+	}
+	for _, s1 := range trees {
+		for _, s2 := range trees {
+			t1, _ := newick.NewParser(strings.NewReader(s1)).Parse()
+			t2, _ := newick.NewParser(strings.NewReader(s2)).Parse()
+			stats, err := tree.QuartetDistance(t1, t2)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := naiveQuartetStats(t1, t2); stats != expected {
+				t.Errorf("Quartet stats of %s and %s are %v and should be %v", s1, s2, stats, expected)
+			}
+		}
+	}
+}
+
+func TestQuartetDistanceDifferentTips(t *testing.T) {
+	t1, _ := newick.NewParser(strings.NewReader("((A,B),C,(D,E));")).Parse()
+	t2, _ := newick.NewParser(strings.NewReader("((A,B),C,(D,F));")).Parse()
+	if _, err := tree.QuartetDistance(t1, t2); err == nil {
+		t.Error("Quartet distance should fail for trees with different tips")
+	}
+}
@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Brute force quartet topologies: for each edge, the set of tips on its right
+func treeSplitSets(t *testing.T, tr *tree.Tree) (splits []map[string]bool, present map[string]bool) {
+	if err := tr.ReinitIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	present = make(map[string]bool)
+	for _, name := range tr.AllTipNames() {
+		present[name] = true
+	}
+	for _, e := range tr.Edges() {
+		s := make(map[string]bool)
+		for _, tip := range tr.Tips() {
+			if e.TipPresent(uint(tip.TipIndex())) {
+				s[tip.Name()] = true
+			}
+		}
+		splits = append(splits, s)
+	}
+	return
+}
+
+// 0: ab|cd, 1: ac|bd, 2: ad|bc, -1: unresolved or missing taxa
+func bruteQuartetTopology(splits []map[string]bool, present map[string]bool, a, b, c, d string) int {
+	if !present[a] || !present[b] || !present[c] || !present[d] {
+		return -1
+	}
+	for _, s := range splits {
+		switch {
+		case s[a] == s[b] && s[c] == s[d] && s[a] != s[c]:
+			return 0
+		case s[a] == s[c] && s[b] == s[d] && s[a] != s[b]:
+			return 1
+		case s[a] == s[d] && s[b] == s[c] && s[a] != s[b]:
+			return 2
+		}
+	}
+	return -1
+}
+
+// Normalized quartet score of the species tree
+func bruteQuartetScore(t *testing.T, species *tree.Tree, genes []*tree.Tree) float64 {
+	names := species.AllTipNames()
+	ssplits, spresent := treeSplitSets(t, species)
+	var agree, total float64
+	for _, g := range genes {
+		gsplits, gpresent := treeSplitSets(t, g)
+		for i := 0; i < len(names); i++ {
+			for j := i + 1; j < len(names); j++ {
+				for k := j + 1; k < len(names); k++ {
+					for l := k + 1; l < len(names); l++ {
+						gq := bruteQuartetTopology(gsplits, gpresent, names[i], names[j], names[k], names[l])
+						if gq < 0 {
+							continue
+						}
+						total++
+						if gq == bruteQuartetTopology(ssplits, spresent, names[i], names[j], names[k], names[l]) {
+							agree++
+						}
+					}
+				}
+			}
+		}
+	}
+	return agree / total
+}
+
+func TestQuartetSpeciesTree(t *testing.T) {
+	rand.Seed(10)
+	for it := 0; it < 10; it++ {
+		genes := make([]*tree.Tree, 0)
+		for g := 0; g < 8; g++ {
+			gt, err := tree.RandomYuleBinaryTree(9, false)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Some gene trees have missing taxa
+			if g%3 == 2 {
+				if err = gt.RemoveTips(false, gt.Tips()[rand.Intn(9)].Name()); err != nil {
+					t.Fatal(err)
+				}
+			}
+			genes = append(genes, gt)
+		}
+		input := make([]string, len(genes))
+		for i, g := range genes {
+			input[i] = g.Newick()
+		}
+		species, score, err := tree.QuartetSpeciesTree(treeChannel(t, input...), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if species.Rooted() || len(species.Tips()) != 9 {
+			t.Fatalf("Species tree should be unrooted with 9 tips: %s", species.Newick())
+		}
+		exp := bruteQuartetScore(t, species, genes)
+		if math.Abs(score-exp) > 1e-9 {
+			t.Errorf("Wrong quartet score: %f, expected %f", score, exp)
+		}
+		// Complete gene trees are in the search space
+		for i, g := range genes {
+			if len(g.Tips()) == 9 {
+				if gs := bruteQuartetScore(t, g, genes); gs > score+1e-9 {
+					t.Errorf("Gene tree %d has a better quartet score than the species tree: %f > %f", i, gs, score)
+				}
+			}
+		}
+		for _, e := range species.Edges() {
+			if !e.Right().Tip() && (e.Support() < 0 || e.Support() > 1) {
+				t.Errorf("Wrong local quartet support: %f", e.Support())
+			}
+		}
+	}
+}
+
+func TestQuartetSpeciesTreeSupports(t *testing.T) {
+	species, score, err := tree.QuartetSpeciesTree(treeChannel(t,
+		"((A,B),C,(D,E));",
+		"((A,B),C,(D,E));",
+		"((A,C),B,(D,E));",
+		"((A,B),(C,D),E);"), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := parseAndIndex(t, "((A,B),C,(D,E));")
+	if dist, _, err := tree.RobinsonFoulds(ref, species); err != nil || dist != 0 {
+		t.Errorf("Wrong species tree: %s", species.Newick())
+	}
+	// Quartets: ABCD ABCE ABDE ACDE BCDE
+	// Trees 1 and 2: all 5 agree, tree 3: ABDE, ACDE, BCDE agree,
+	// tree 4: ABCD, ABCE, ABDE agree
+	if math.Abs(score-16./20.) > 1e-9 {
+		t.Errorf("Wrong quartet score: %f, expected %f", score, 16./20.)
+	}
+	supports := consensusSupports(t, species)
+	// Branch AB|CDE: quartets ABCD, ABCE agree in trees 1, 2 and 4
+	if s := splitSupport(supports, "A,B", "C,D,E"); math.Abs(s-6./8.) > 1e-9 {
+		t.Errorf("Wrong local quartet support of AB: %f, expected %f", s, 6./8.)
+	}
+	// Branch ABC|DE: quartets ACDE, BCDE agree in trees 1, 2 and 3
+	if s := splitSupport(supports, "D,E", "A,B,C"); math.Abs(s-6./8.) > 1e-9 {
+		t.Errorf("Wrong local quartet support of DE: %f, expected %f", s, 6./8.)
+	}
+}
+
+// Support of the bipartition, given by any of its sides
+func splitSupport(supports map[string]float64, side1, side2 string) float64 {
+	if s, ok := supports[side1]; ok {
+		return s
+	}
+	return supports[side2]
+}
@@ -0,0 +1,59 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestReconcile(t *testing.T) {
+	species, err := newick.NewParser(strings.NewReader("(((A,B),C),D);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	speciesmap := map[string]string{"a1": "A", "a2": "A", "b1": "B", "b2": "B", "c1": "C", "d1": "D"}
+
+	tests := []struct {
+		gene       string
+		reconciled string
+		stats      tree.ReconciliationStats
+	}{
+		// Same topology as the species tree
+		{"(((a1,b1),c1),d1);", "(((a1,b1)[S],c1)[S],d1)[S];", tree.ReconciliationStats{Speciations: 3}},
+		// Duplication above AB (no loss counted above the root of the gene tree)
+		{"(((a1,b1),(a2,b2)),c1);", "(((a1,b1)[S],(a2,b2)[S])[D],c1)[S];", tree.ReconciliationStats{Speciations: 3, Duplications: 1}},
+		// Incongruent topology: (A,C) and ((A,C),B) both map to ABC => duplication,
+		// with losses of B in the first copy, and of C and A in the second
+		{"(((a1,c1),b1),d1);", "(((a1,c1)[S],b1)[D],d1)[S];", tree.ReconciliationStats{Speciations: 2, Duplications: 1, Losses: 3}},
+		// Tips named after species, without map
+		{"((A,B),D);", "((A,B)[S],D)[S];", tree.ReconciliationStats{Speciations: 2, Losses: 1}},
+	}
+
+	for _, test := range tests {
+		gene, err := newick.NewParser(strings.NewReader(test.gene)).Parse()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stats, err := tree.Reconcile(gene, species, speciesmap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if stats != test.stats {
+			t.Errorf("Wrong reconciliation of %s: %v, expected %v", test.gene, stats, test.stats)
+		}
+		if gene.Newick() != test.reconciled {
+			t.Errorf("Wrong reconciled gene tree: %s, expected %s", gene.Newick(), test.reconciled)
+		}
+	}
+
+	gene, _ := newick.NewParser(strings.NewReader("((a1,b1),e1);")).Parse()
+	if _, err = tree.Reconcile(gene, species, speciesmap); err == nil {
+		t.Errorf("Reconciliation should fail with an unknown species")
+	}
+	gene, _ = newick.NewParser(strings.NewReader("(a1,b1,c1);")).Parse()
+	if _, err = tree.Reconcile(gene, species, speciesmap); err == nil {
+		t.Errorf("Reconciliation should fail with an unrooted gene tree")
+	}
+}
@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func TestRootToTipRegressionClock(t *testing.T) {
+	// Strict clock with rate 0.01 and root date 2000: branch lengths are
+	// 0.01 x durations
+	tr, err := newick.NewParser(strings.NewReader("(((A:0.05,B:0.07):0.03,C:0.1):0.04,(D:0.08,E:0.02):0.1);")).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dates := map[string]float64{"A": 2012, "B": 2014, "C": 2014, "D": 2018, "E": 2012}
+	tr.UnRoot()
+	for _, criterion := range []int{tree.RTT_R2, tree.RTT_RMS} {
+		c := tr.Clone()
+		stats, err := c.RootToTipRegression(dates, criterion)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(stats.Rate-0.01) > 1e-9 || math.Abs(stats.TMRCA-2000) > 1e-6 || math.Abs(stats.R2-1) > 1e-9 {
+			t.Errorf("Wrong root-to-tip regression: rate=%f, tmrca=%f, r2=%f", stats.Rate, stats.TMRCA, stats.R2)
+		}
+		for _, r := range stats.Residuals {
+			if math.Abs(r.Residual) > 1e-9 {
+				t.Errorf("Wrong residual for %s: %f", r.Name, r.Residual)
+			}
+		}
+		if !c.Rooted() {
+			t.Errorf("Tree should be rooted")
+		}
+		for _, e := range c.Root().Edges() {
+			if l := e.Length(); math.Abs(l-0.04) > 1e-9 && math.Abs(l-0.1) > 1e-9 {
+				t.Errorf("Wrong root position: %s", c.Newick())
+			}
+		}
+	}
+}
+
+// Distances from the given node to all the tips
+func tipDistances(n, prev *tree.Node, d float64, dists map[string]float64) {
+	if n.Tip() {
+		dists[n.Name()] = d
+	}
+	for i, next := range n.Neigh() {
+		if next != prev {
+			tipDistances(next, n, d+n.Edges()[i].Length(), dists)
+		}
+	}
+}
+
+// R² and residual sum of squares of the regression of distances on dates
+func regressionFit(dists, dates map[string]float64) (r2, rss, slope float64) {
+	var n, st, sd, stt, sdd, std float64
+	for name, d := range dists {
+		t := dates[name]
+		n++
+		st += t
+		sd += d
+		stt += t * t
+		sdd += d * d
+		std += t * d
+	}
+	stt -= st * st / n
+	sdd -= sd * sd / n
+	std -= st * sd / n
+	return std * std / (stt * sdd), sdd - std*std/stt, std / stt
+}
+
+func TestRootToTipRegressionGrid(t *testing.T) {
+	rand.Seed(10)
+	for it := 0; it < 10; it++ {
+		tr, err := tree.RandomYuleBinaryTree(10, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dates := make(map[string]float64)
+		for _, tip := range tr.Tips() {
+			dates[tip.Name()] = 2000 + rand.Float64()*20
+		}
+
+		// Grid search over all the positions on all the edges
+		bestr2, bestrss := 0.0, math.Inf(1)
+		for _, e := range tr.Edges() {
+			for k := 0; k <= 100; k++ {
+				x := e.Length() * float64(k) / 100
+				dists := make(map[string]float64)
+				tipDistances(e.Right(), e.Left(), e.Length()-x, dists)
+				tipDistances(e.Left(), e.Right(), x, dists)
+				r2, rss, slope := regressionFit(dists, dates)
+				if slope > 0 && r2 > bestr2 {
+					bestr2 = r2
+				}
+				if rss < bestrss {
+					bestrss = rss
+				}
+			}
+		}
+
+		c := tr.Clone()
+		stats, err := c.RootToTipRegression(dates, tree.RTT_R2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bestr2 > 0 && stats.R2 < bestr2-1e-9 {
+			t.Errorf("R2 of the root-to-tip regression is not maximal: %f < %f", stats.R2, bestr2)
+		}
+		c = tr.Clone()
+		stats, err = c.RootToTipRegression(dates, tree.RTT_RMS)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rss := stats.RMS * float64(len(dates)-2); rss > bestrss+1e-9 {
+			t.Errorf("RMS of the root-to-tip regression is not minimal: %f > %f", rss, bestrss)
+		}
+	}
+}
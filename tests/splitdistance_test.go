@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+func parseAndIndex(t *testing.T, nw string) *tree.Tree {
+	tr, err := newick.NewParser(strings.NewReader(nw)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = tr.ReinitIndexes(); err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+func TestWeightedRFAndBranchScore(t *testing.T) {
+	t1 := parseAndIndex(t, "((A:1,B:1):1,C:1,(D:1,E:1):1);")
+	// Same topology, different lengths
+	t2 := parseAndIndex(t, "((A:1,B:2):1,C:1,(D:1,E:1):3);")
+	// Rooted version of t1 (root edges define the same bipartition)
+	t3 := parseAndIndex(t, "(((A:1,B:1):1,C:1):0.4,(D:1,E:1):0.6);")
+	// Different topology
+	t4 := parseAndIndex(t, "((A:1,C:1):1,B:1,(D:1,E:1):1);")
+
+	tests := []struct {
+		t2       *tree.Tree
+		wrf, bsd float64
+	}{
+		{t2, 3, math.Sqrt(5)},
+		{t3, 0, 0},
+		{t4, 2, math.Sqrt(2)},
+	}
+	for i, test := range tests {
+		wrf, err := tree.WeightedRobinsonFoulds(t1, test.t2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(wrf-test.wrf) > 1e-9 {
+			t.Errorf("Test %d: weighted RF is %f and should be %f", i, wrf, test.wrf)
+		}
+		bsd, err := tree.BranchScoreDistance(t1, test.t2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if math.Abs(bsd-test.bsd) > 1e-9 {
+			t.Errorf("Test %d: branch score distance is %f and should be %f", i, bsd, test.bsd)
+		}
+	}
+
+	t5 := parseAndIndex(t, "((A,B),C,(D,E));")
+	if _, err := tree.WeightedRobinsonFoulds(t1, t5); err == nil {
+		t.Error("Weighted RF should fail when branch lengths are not defined")
+	}
+}
+
+func TestMatchingSplitDistance(t *testing.T) {
+	t1 := parseAndIndex(t, "(A,B,(C,(D,(E,F))));")
+	t2 := parseAndIndex(t, "(A,C,(B,(D,(E,F))));")
+	// Splits of t1: AB|CDEF, ABC|DEF, ABCD|EF
+	// Splits of t2: AC|BDEF, ABC|DEF, ABCD|EF
+	// AB|CDEF <-> AC|BDEF: 2
+	msd, err := tree.MatchingSplitDistance(t1, t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msd != 2 {
+		t.Errorf("Matching split distance is %f and should be 2", msd)
+	}
+	// Star tree: all splits are unmatched: 2 + 3 + 2
+	t3 := parseAndIndex(t, "(A,B,C,D,E,F);")
+	if msd, err = tree.MatchingSplitDistance(t1, t3); err != nil {
+		t.Fatal(err)
+	}
+	if msd != 7 {
+		t.Errorf("Matching split distance is %f and should be 7", msd)
+	}
+	if msd, err = tree.MatchingSplitDistance(t1, t1); err != nil {
+		t.Fatal(err)
+	}
+	if msd != 0 {
+		t.Errorf("Matching split distance is %f and should be 0", msd)
+	}
+}
+
+func TestClusteringInformationDistance(t *testing.T) {
+	t1 := parseAndIndex(t, "(A,B,(C,(D,(E,F))));")
+	t2 := parseAndIndex(t, "(A,C,(B,(D,(E,F))));")
+	t3 := parseAndIndex(t, "(A,B,C,D,E,F);")
+
+	cid, norm, err := tree.ClusteringInformationDistance(t1, t1.Clone())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(cid) > 1e-9 || math.Abs(norm) > 1e-9 {
+		t.Errorf("Clustering information distance of a tree with itself is %f (%f) and should be 0", cid, norm)
+	}
+
+	// Against a star tree: no mutual information => CID = H(t1)
+	h := func(a, n float64) float64 {
+		return -a/n*math.Log2(a/n) - (n-a)/n*math.Log2((n-a)/n)
+	}
+	ht1 := 2*h(2, 6) + h(3, 6)
+	if cid, norm, err = tree.ClusteringInformationDistance(t1, t3); err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(cid-ht1) > 1e-9 || math.Abs(norm-1) > 1e-9 {
+		t.Errorf("Clustering information distance is %f (%f) and should be %f (1)", cid, norm, ht1)
+	}
+
+	// Differ by one split, matched with each other
+	if cid, _, err = tree.ClusteringInformationDistance(t1, t2); err != nil {
+		t.Fatal(err)
+	}
+	// MI between AB|CDEF and AC|BDEF: cells 1,1,1,3 over 6 tips
+	mi := 1.0/6*math.Log2(6.0/4) + 1.0/6*math.Log2(6.0/8) + 1.0/6*math.Log2(6.0/8) + 3.0/6*math.Log2(18.0/16)
+	expected := 2 * (h(2, 6) - mi)
+	if math.Abs(cid-expected) > 1e-9 {
+		t.Errorf("Clustering information distance is %f and should be %f", cid, expected)
+	}
+}
+
+func TestRobinsonFoulds(t *testing.T) {
+	t1 := parseAndIndex(t, "(A,B,(C,(D,(E,F))));")
+	t2 := parseAndIndex(t, "(A,C,(B,(D,(E,F))));")
+	// Rooted version of t1: both root edges define ABC|DEF
+	t3 := parseAndIndex(t, "((A,B,C),(D,(E,F)));")
+	t4 := parseAndIndex(t, "(A,B,C,D,E,F);")
+
+	tests := []struct {
+		t2   *tree.Tree
+		rf   int
+		norm float64
+	}{
+		{t1.Clone(), 0, 0},
+		{t2, 2, 2.0 / 6.0},
+		{t3, 1, 1.0 / 5.0},
+		{t4, 3, 1},
+	}
+	for i, test := range tests {
+		if err := test.t2.ReinitIndexes(); err != nil {
+			t.Fatal(err)
+		}
+		rf, norm, err := tree.RobinsonFoulds(t1, test.t2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rf != test.rf || math.Abs(norm-test.norm) > 1e-9 {
+			t.Errorf("Test %d: RF is %d (%f) and should be %d (%f)", i, rf, norm, test.rf, test.norm)
+		}
+		// Symmetry
+		if rf2, _, err := tree.RobinsonFoulds(test.t2, t1); err != nil || rf2 != rf {
+			t.Errorf("Test %d: RF is not symmetric (%d vs %d)", i, rf, rf2)
+		}
+	}
+
+	// Star tree as first tree: no internal split in the index
+	star := parseAndIndex(t, "(a,b,c,d,e);")
+	other := parseAndIndex(t, "((a,b),c,(d,e));")
+	if rf, _, err := tree.RobinsonFoulds(star, other); err != nil || rf != 2 {
+		t.Errorf("RF between a star tree and a resolved tree is %d and should be 2 (%v)", rf, err)
+	}
+}
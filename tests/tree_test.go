@@ -2,6 +2,7 @@ package tests
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 	"testing"
 
@@ -776,3 +777,229 @@ func TestNNI3(t *testing.T) {
 		t.Error(fmt.Errorf("The number of NNIS is not expected : %d vs. %d", nnis, (ntips-3)*2))
 	}
 }
+
+// Tests that SPR moves are correctly applied and undone, and that
+// all SPR neighbors are generated:
+// 2(n-3)(2n-7) distinct trees for unrooted binary trees with no radius limit
+func TestSPR(t *testing.T) {
+	var tr *tree.Tree
+	var err error
+	var init string
+	var nbtips int = 10
+
+	for _, rooted := range []bool{false, true} {
+		if tr, err = tree.RandomYuleBinaryTree(nbtips, rooted); err != nil {
+			t.Error(err)
+			return
+		}
+		init = tr.Newick()
+		topologies := make(map[string]bool)
+
+		r := &tree.SPRRearranger{}
+		r.Rearrange(tr, func(re tree.Rearrangement) bool {
+			if err = re.Apply(); err != nil {
+				t.Error(err)
+				return false
+			}
+			if err = tr.CheckTreePostOrder(); err != nil {
+				t.Error(err)
+				return false
+			}
+			if len(tr.Tips()) != nbtips {
+				t.Error(fmt.Errorf("Tree after applying SPR has %d tips instead of %d", len(tr.Tips()), nbtips))
+				return false
+			}
+			tr.ReinitInternalIndexes()
+			topologies[topologyKey(tr)] = true
+
+			if err = re.Undo(); err != nil {
+				t.Error(err)
+				return false
+			}
+			if err = tr.CheckTreePostOrder(); err != nil {
+				t.Error(err)
+				return false
+			}
+			if tr.Newick() != init {
+				t.Error(fmt.Errorf("Tree after undoing SPR does not correspond to the initial tree: %s / %s", init, tr.Newick()))
+				return false
+			}
+			return true
+		})
+
+		tr.ReinitInternalIndexes()
+		delete(topologies, topologyKey(tr))
+		if !rooted {
+			if expected := 2 * (nbtips - 3) * (2*nbtips - 7); len(topologies) != expected {
+				t.Error(fmt.Errorf("Number of distinct SPR neighbors should be %d and is %d", expected, len(topologies)))
+			}
+		}
+	}
+}
+
+// Tests that SPR moves of radius 1 are NNIs
+func TestSPRRadius(t *testing.T) {
+	var tr *tree.Tree
+	var err error
+
+	if tr, err = tree.RandomYuleBinaryTree(20, false); err != nil {
+		t.Error(err)
+		return
+	}
+	nnis := make(map[string]bool)
+	sprs := make(map[string]bool)
+
+	for _, r := range []tree.Rearranger{&tree.NNIRearranger{}, &tree.SPRRearranger{MaxRadius: 1}} {
+		r.Rearrange(tr, func(re tree.Rearrangement) bool {
+			if err = re.Apply(); err != nil {
+				t.Error(err)
+				return false
+			}
+			tr.ReinitInternalIndexes()
+			if _, ok := r.(*tree.NNIRearranger); ok {
+				nnis[topologyKey(tr)] = true
+			} else {
+				sprs[topologyKey(tr)] = true
+			}
+			if err = re.Undo(); err != nil {
+				t.Error(err)
+				return false
+			}
+			return true
+		})
+	}
+
+	if len(nnis) != len(sprs) {
+		t.Error(fmt.Errorf("Number of SPR neighbors of radius 1 (%d) is different from the number of NNI neighbors (%d)", len(sprs), len(nnis)))
+	}
+	for k := range nnis {
+		if !sprs[k] {
+			t.Error(fmt.Errorf("NNI neighbor not found in SPR neighbors of radius 1"))
+		}
+	}
+}
+
+// Key identifying the unrooted topology of a tree
+// (bitsets and hashes must be up to date)
+func topologyKey(tr *tree.Tree) string {
+	hashes := make([]string, 0)
+	for _, e := range tr.Edges() {
+		if !e.Right().Tip() && !e.Left().Tip() {
+			b := e.DumpBitSet()
+			if strings.HasPrefix(b, "1") {
+				b = strings.NewReplacer("0", "1", "1", "0").Replace(b)
+			}
+			hashes = append(hashes, b)
+		}
+	}
+	sort.Strings(hashes)
+	return strings.Join(hashes, ",")
+}
+
+// Tests that TBR moves are correctly applied and undone, and that
+// TBR neighbors include SPR neighbors
+func TestTBR(t *testing.T) {
+	var tr *tree.Tree
+	var err error
+	var init string
+	var nbtips int = 12
+
+	if tr, err = tree.RandomYuleBinaryTree(nbtips, false); err != nil {
+		t.Error(err)
+		return
+	}
+	init = tr.Newick()
+	topologies := make(map[string]bool)
+	tr.ReinitIndexes()
+	initkey := topologyKey(tr)
+
+	r := &tree.TBRRearranger{}
+	r.Rearrange(tr, func(re tree.Rearrangement) bool {
+		if err = re.Apply(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if err = tr.CheckTreePostOrder(); err != nil {
+			t.Error(err)
+			return false
+		}
+		tr.ReinitInternalIndexes()
+		if k := topologyKey(tr); k != initkey {
+			topologies[k] = true
+		}
+		if err = re.Undo(); err != nil {
+			t.Error(err)
+			return false
+		}
+		if tr.Newick() != init {
+			t.Error(fmt.Errorf("Tree after undoing TBR does not correspond to the initial tree: %s / %s", init, tr.Newick()))
+			return false
+		}
+		return true
+	})
+
+	sprs := make(map[string]bool)
+	if err = tr.Neighbors(&tree.SPRRearranger{}, func(nt *tree.Tree) bool {
+		sprs[topologyKey(nt)] = true
+		return true
+	}); err != nil {
+		t.Error(err)
+	}
+	for k := range sprs {
+		if !topologies[k] {
+			t.Error(fmt.Errorf("SPR neighbor not found in TBR neighbors"))
+			break
+		}
+	}
+	if len(topologies) <= len(sprs) {
+		t.Error(fmt.Errorf("Number of TBR neighbors (%d) should be greater than the number of SPR neighbors (%d)", len(topologies), len(sprs)))
+	}
+}
+
+// Tests the enumeration of distinct neighbors
+func TestCountNeighbors(t *testing.T) {
+	var tr *tree.Tree
+	var err error
+	var nb int
+	var nbtips int = 15
+
+	for i := 0; i < 10; i++ {
+		if tr, err = tree.RandomYuleBinaryTree(nbtips, false); err != nil {
+			t.Error(err)
+			return
+		}
+		init := tr.Newick()
+		for _, r := range []tree.Rearranger{&tree.NNIRearranger{}, &tree.SPRRearranger{}, &tree.TBRRearranger{}} {
+			keys := make(map[string]bool)
+			if err = tr.Neighbors(r, func(nt *tree.Tree) bool {
+				k := topologyKey(nt)
+				if keys[k] {
+					t.Error(fmt.Errorf("Topology enumerated twice"))
+				}
+				keys[k] = true
+				return true
+			}); err != nil {
+				t.Error(err)
+			}
+			if nb, err = tr.CountNeighbors(r); err != nil {
+				t.Error(err)
+			}
+			if nb != len(keys) {
+				t.Error(fmt.Errorf("Number of neighbors (%d) is different from the number of enumerated neighbors (%d)", nb, len(keys)))
+			}
+			switch r.(type) {
+			case *tree.NNIRearranger:
+				if nb != 2*(nbtips-3) {
+					t.Error(fmt.Errorf("Number of NNI neighbors should be %d and is %d", 2*(nbtips-3), nb))
+				}
+			case *tree.SPRRearranger:
+				if nb != 2*(nbtips-3)*(2*nbtips-7) {
+					t.Error(fmt.Errorf("Number of SPR neighbors should be %d and is %d", 2*(nbtips-3)*(2*nbtips-7), nb))
+				}
+			}
+			if tr.Newick() != init {
+				t.Error(fmt.Errorf("Tree has been modified by neighbor enumeration"))
+			}
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Naive rooted triplet comparison: ab|c is resolved if a clade
+// contains a and b but not c
+func naiveTripletStats(t1, t2 *tree.Tree) (stats tree.TripletStats) {
+	topology := func(t *tree.Tree) func(a, b, c string) int {
+		clades := make([]map[string]bool, 0)
+		var clade func(cur, prev *tree.Node) map[string]bool
+		clade = func(cur, prev *tree.Node) map[string]bool {
+			tips := make(map[string]bool)
+			if cur.Tip() {
+				tips[cur.Name()] = true
+			}
+			for _, n := range cur.Neigh() {
+				if n != prev {
+					for k := range clade(n, cur) {
+						tips[k] = true
+					}
+				}
+			}
+			clades = append(clades, tips)
+			return tips
+		}
+		clade(t.Root(), nil)
+		return func(a, b, c string) int {
+			for _, cl := range clades {
+				switch {
+				case cl[a] && cl[b] && !cl[c]:
+					return 0
+				case cl[a] && cl[c] && !cl[b]:
+					return 1
+				case cl[b] && cl[c] && !cl[a]:
+					return 2
+				}
+			}
+			return -1
+		}
+	}
+	top1, top2 := topology(t1), topology(t2)
+	tips := t1.AllTipNames()
+	for a := 0; a < len(tips); a++ {
+		for b := a + 1; b < len(tips); b++ {
+			for c := b + 1; c < len(tips); c++ {
+				q1 := top1(tips[a], tips[b], tips[c])
+				q2 := top2(tips[a], tips[b], tips[c])
+				stats.Total++
+				switch {
+				case q1 == -1 && q2 == -1:
+					stats.UnresolvedBoth++
+				case q1 == -1:
+					stats.Unresolved1++
+				case q2 == -1:
+					stats.Unresolved2++
+				case q1 == q2:
+					stats.Shared++
+				default:
+					stats.Diff++
+				}
+			}
+		}
+	}
+	return
+}
+
+func TestTripletDistance(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		t1, err := tree.RandomYuleBinaryTree(15, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t2, err := tree.RandomYuleBinaryTree(15, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Multifurcations
+		if i%4 > 1 {
+			t1.CollapseShortBranches(0.3, false, false)
+			t2.CollapseShortBranches(0.2, false, false)
+		}
+		stats, err := tree.TripletDistance(t1, t2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expected := naiveTripletStats(t1, t2)
+		if stats != expected {
+			t.Errorf("Triplet stats are %v and should be %v", stats, expected)
+		}
+	}
+}
+
+func TestTripletDistanceUnrooted(t *testing.T) {
+	t1, _ := newick.NewParser(strings.NewReader("((A,B),(C,D));")).Parse()
+	t2, _ := newick.NewParser(strings.NewReader("((A,B),C,D);")).Parse()
+	if _, err := tree.TripletDistance(t1, t2); err == nil {
+		t.Error("Triplet distance should fail for unrooted trees")
+	}
+	if _, err := tree.TripletDistance(t2, t1); err == nil {
+		t.Error("Triplet distance should fail for unrooted trees")
+	}
+}
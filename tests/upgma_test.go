@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/evolbioinfo/gotree/io/newick"
+	"github.com/evolbioinfo/gotree/tree"
+)
+
+// Example from https://en.wikipedia.org/wiki/UPGMA
+func TestUPGMA(t *testing.T) {
+	testClustering(t, tree.UPGMA, "(((a:8.5,b:8.5):2.5,e:11):5.5,(c:14,d:14):2.5);")
+}
+
+// Example from https://en.wikipedia.org/wiki/WPGMA
+func TestWPGMA(t *testing.T) {
+	testClustering(t, tree.WPGMA, "(((a:8.5,b:8.5):2.5,e:11):6.5,(c:14,d:14):3.5);")
+}
+
+func testClustering(t *testing.T, algo func([]string, [][]float64) (*tree.Tree, error), expected string) {
+	names := []string{"a", "b", "c", "d", "e"}
+	mat := [][]float64{
+		{0, 17, 21, 31, 23},
+		{17, 0, 30, 34, 21},
+		{21, 30, 0, 28, 39},
+		{31, 34, 28, 0, 43},
+		{23, 21, 39, 43, 0},
+	}
+	tr, err := algo(names, mat)
+	if err != nil {
+		t.Error(err)
+	}
+	if !tr.Rooted() {
+		t.Error("Output tree should be rooted")
+	}
+	exp, err := newick.NewParser(strings.NewReader(expected)).Parse()
+	if err != nil {
+		t.Error(err)
+	}
+	if err = exp.ReinitIndexes(); err != nil {
+		t.Error(err)
+	}
+	tree1, _, err := tr.CommonEdges(exp, true)
+	if err != nil {
+		t.Error(err)
+	}
+	if tree1 != 0 {
+		t.Error(fmt.Sprintf("Output tree does not have the expected topology: %s", tr.Newick()))
+	}
+	if tr.SumBranchLengths() != exp.SumBranchLengths() {
+		t.Error(fmt.Sprintf("Output tree does not have the expected total length: %f vs. %f", tr.SumBranchLengths(), exp.SumBranchLengths()))
+	}
+	// All tips must be at the same distance from the root
+	height := -1.0
+	tr.PostOrder(func(cur *tree.Node, prev *tree.Node, e *tree.Edge) bool {
+		if cur.Tip() {
+			h := 0.0
+			for n := cur; n != tr.Root(); {
+				pe, _ := n.ParentEdge()
+				h += pe.Length()
+				n = pe.Left()
+			}
+			if height >= 0 && h != height {
+				t.Error(fmt.Sprintf("Output tree is not ultrametric: %f vs. %f", h, height))
+			}
+			height = h
+		}
+		return true
+	})
+}
@@ -263,13 +263,44 @@ func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
 	if cutoff < 0.5 || cutoff > 1 {
 		return nil, errors.New("Min frequency for bipartition must be >=0.5 and <=1")
 	}
-	nbtrees := 0
-	edgeindex := NewEdgeIndex(128, .75)
-	var nodeindex *nodeIndex
-	var startree *Tree = nil
+	splits, err := countConsensusSplits(trees)
+	if err != nil {
+		return nil, err
+	}
+
+	// We take the bipartitions that are present in more than cutoff trees and less
+	// than or equal the number of trees
+	// And we add it to the startree
+	if err = splits.addToStarTree(splits.edgeindex.Edges(int(cutoff*float64(splits.nbtrees)), splits.nbtrees)); err != nil {
+		return nil, err
+	}
+	if err = splits.startree.ReinitIndexes(); err != nil {
+		return nil, err
+	}
+	return splits.startree, nil
+}
+
+// Bipartitions of a set of trees, and the star tree
+// used to build their consensus
+type consensusSplits struct {
+	nbtrees     int
+	alltips     []string
+	edgeindex   *EdgeIndex // Number of trees containing each bipartition
+	lengthindex *EdgeIndex // Number of defined lengths of each bipartition, and their sum
+	startree    *Tree
+	nodeindex   *nodeIndex
+}
+
+// Counts the bipartitions of the trees given in the input channel (each
+// bipartition is counted once per tree), and initializes the star tree of the consensus with the tips of the first tree.
+//
+// Returns an error if the trees do not have the same set of tips
+func countConsensusSplits(trees <-chan Trees) (splits *consensusSplits, err error) {
+	splits = &consensusSplits{
+		edgeindex:   NewEdgeIndex(128, .75),
+		lengthindex: NewEdgeIndex(128, .75),
+	}
 	nbtips := 0
-	var alltips []string
-	var err error
 	// We fill the edge index with all the bipartition and their count
 	for curtree := range trees {
 		if curtree.Err != nil {
@@ -284,18 +315,18 @@ func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
 		}
 
 		// If the star tree is not initialized, we create it with the tips of the first tree
-		if startree == nil {
-			alltips = curtree.Tree.AllTipNames()
-			if startree, err = StarTreeFromTree(curtree.Tree); err != nil {
+		if splits.startree == nil {
+			splits.alltips = curtree.Tree.AllTipNames()
+			if splits.startree, err = StarTreeFromTree(curtree.Tree); err != nil {
 				return nil, err
 			}
-			if err = startree.UpdateTipIndex(); err != nil {
+			if err = splits.startree.UpdateTipIndex(); err != nil {
 				return nil, err
 			}
 
-			nbtips = len(alltips)
+			nbtips = len(splits.alltips)
 			// We first build the node index
-			if nodeindex, err = NewNodeIndex(startree); err != nil {
+			if splits.nodeindex, err = NewNodeIndex(splits.startree); err != nil {
 				return nil, err
 			}
 		} else {
@@ -306,28 +337,59 @@ func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
 				return nil, errors.New("Trees do not have the same set of tips")
 			}
 			for _, name := range names {
-				if ok, err3 := startree.ExistsTip(name); err3 != nil {
+				if ok, err3 := splits.startree.ExistsTip(name); err3 != nil {
 					return nil, err3
 				} else if !ok {
 					return nil, errors.New("Trees do not have the same set of tips")
 				}
 			}
 		}
-		// We add the edge into the index
-		for _, e := range curtree.Tree.Edges() {
-			edgeindex.AddEdgeCount(e)
+		// We add the bipartitions into the index, once per tree (edges around
+		// the root of rooted trees define the same bipartition)
+		uniques, lengths, err := uniqueSplits(curtree.Tree.Edges(), true, false)
+		if err != nil {
+			return nil, err
 		}
-		nbtrees++
+		for i, e := range uniques {
+			splits.edgeindex.AddEdgeCount(e)
+			if lengths[i] == NIL_LENGTH {
+				continue
+			}
+			if v, ok := splits.lengthindex.Value(e); ok {
+				v.Count++
+				v.Len += lengths[i]
+			} else {
+				splits.lengthindex.PutEdgeValue(e, 1, lengths[i])
+			}
+		}
+		splits.nbtrees++
+	}
+	if splits.startree == nil {
+		return nil, errors.New("No tree in the input")
 	}
+	return splits, nil
+}
 
-	// We take the bipartitions that are present in more than cutoff trees and less
-	// than or equal the number of trees
-	// And we add it to the startree
-	for _, bs := range edgeindex.Edges(int(cutoff*float64(nbtrees)), nbtrees) {
+// Average of the defined lengths of the bipartition in the trees,
+// NIL_LENGTH if none is defined
+func (splits *consensusSplits) meanLength(e *Edge) float64 {
+	if v, ok := splits.lengthindex.Value(e); ok {
+		return v.Len / float64(v.Count)
+	}
+	return NIL_LENGTH
+}
+
+// Adds the given bipartitions to the star tree. Bipartitions must be compatible.
+//
+// Supports of the added branches are the proportion of trees in which the bipartitions
+// are present, and lengths are their average lengths.
+func (splits *consensusSplits) addToStarTree(bipartitions []*KeyValue) error {
+	startree := splits.startree
+	for _, bs := range bipartitions {
 		names := make([]string, 0, bs.key.Bitset().Count())
-		for _, n := range alltips {
+		for _, n := range splits.alltips {
 			if idx, err := startree.TipIndex(n); err != nil {
-				return nil, err
+				return err
 			} else {
 				if bs.key.Bitset().Test(uint(idx)) {
 					names = append(names, n)
@@ -338,39 +400,35 @@ func Consensus(trees <-chan Trees, cutoff float64) (*Tree, error) {
 		// Names of the tips in one side of the bipartition
 		if len(names) < 2 {
 			if len(names) == 1 {
-				if t, ok := nodeindex.GetNode(names[0]); !ok || !t.Tip() {
-					return nil, errors.New(fmt.Sprintf("This taxon name does not exist in the consensus: %s", names[0]))
+				if t, ok := splits.nodeindex.GetNode(names[0]); !ok || !t.Tip() {
+					return errors.New(fmt.Sprintf("This taxon name does not exist in the consensus: %s", names[0]))
 				} else {
-					t.br[0].SetLength(float64(bs.val.Len) / float64(bs.val.Count))
+					t.br[0].SetLength(splits.meanLength(bs.key))
 				}
 			} else {
-				return nil, errors.New("This bipartition has a side with no taxa")
+				return errors.New("This bipartition has a side with no taxa")
 			}
 		} else {
-			node, edges, monophyletic, err := startree.LeastCommonAncestorUnrooted(nodeindex, names...)
+			node, edges, monophyletic, err := startree.LeastCommonAncestorUnrooted(splits.nodeindex, names...)
 			if err != nil {
-				return nil, err
+				return err
 			}
 			if node == nil {
-				return nil, errors.New("Consensus error: No common ancestor found for biparition")
+				return errors.New("Consensus error: No common ancestor found for biparition")
 			}
 			if edges == nil || len(edges) == 0 {
-				return nil, errors.New("Consensus error: No common ancestor Edges found")
+				return errors.New("Consensus error: No common ancestor Edges found")
 			}
 			if !monophyletic {
-				return nil, errors.New("The group should be monophyletic")
+				return errors.New("The group should be monophyletic")
 			}
 			// We add the bipartition with a support value corresponding to the percentage of
 			// trees in which it appears
 			// TODO: Average branch length : Need to change the data structure
-			startree.AddBipartition(node, edges, float64(bs.val.Len)/float64(bs.val.Count), float64(bs.val.Count)/float64(nbtrees))
+			startree.AddBipartition(node, edges, splits.meanLength(bs.key), float64(bs.val.Count)/float64(splits.nbtrees))
 		}
 	}
-
-	if err = startree.ReinitIndexes(); err != nil {
-		return nil, err
-	}
-	return startree, nil
+	return nil
 }
 
 // This function first unroots the input tree and reroots it using the outgroup in argument.
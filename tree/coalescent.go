@@ -0,0 +1,168 @@
+package tree
+
+import (
+	"errors"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/fredericlemoine/gostats"
+)
+
+// Sample of a coalescent simulation
+type CoalescentSample struct {
+	Name string  // Name of the tip
+	Age  float64 // Sampling time, backward from the most recent sample (>= 0)
+	Deme int     // Index of the deme of the sample
+}
+
+// Lineage of a coalescent simulation
+type coalescentLineage struct {
+	node *Node
+	age  float64
+	deme int
+}
+
+// Creates a random genealogy of the given samples under the coalescent, and
+// returns it as a rooted tree whose branch lengths are in units of time.
+//
+//   - samples: Tips of the genealogy, with their sampling ages (heterochronous
+//     sampling), and their demes
+//   - popsize: Effective population size of each deme at age 0: each pair of
+//     lineages of the same deme coalesces at rate 1/N(t)
+//   - growth: Exponential growth rate g >= 0 of the population:
+//     N(t)=popsize.exp(-g.t), t being the age (backward in time). If 0: constant
+//     population size
+//   - ndemes: Number of demes. If > 1, it simulates a structured coalescent
+//     under the island model
+//   - migration: Backward migration rate of each lineage to any other deme
+//
+// With a single deme and samples of age 0, it is the Kingman coalescent.
+// Events are simulated exactly: at each step, the time to the next coalescence,
+// which has an analytical expression under exponential growth, competes with the
+// time to the next migration and the time to the next sample.
+func RandomCoalescentTree(samples []CoalescentSample, popsize, growth float64, ndemes int, migration float64) (*Tree, error) {
+	if len(samples) < 2 {
+		return nil, errors.New("Cannot create a coalescent tree with less than 2 samples")
+	}
+	if popsize <= 0 {
+		return nil, errors.New("Population size must be > 0")
+	}
+	if growth < 0 {
+		return nil, errors.New("Growth rate must be >= 0")
+	}
+	if ndemes < 1 || migration < 0 {
+		return nil, errors.New("Number of demes must be >= 1 and migration rate must be >= 0")
+	}
+	if ndemes > 1 && migration == 0 {
+		return nil, errors.New("Migration rate must be > 0 with several demes")
+	}
+
+	t := NewTree()
+	// Samples are added by increasing age
+	sorted := make([]CoalescentSample, len(samples))
+	copy(sorted, samples)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Age < sorted[j].Age })
+	for _, s := range sorted {
+		if s.Age < 0 {
+			return nil, errors.New("Sampling ages must be >= 0")
+		}
+		if s.Deme < 0 || s.Deme >= ndemes {
+			return nil, errors.New("Wrong deme for sample " + s.Name)
+		}
+	}
+
+	lineages := make([]*coalescentLineage, 0, len(sorted))
+	next := 0
+	age := sorted[0].Age
+	for next < len(sorted) || len(lineages) > 1 {
+		// New samples
+		for next < len(sorted) && sorted[next].Age <= age {
+			n := t.NewNode()
+			n.SetName(sorted[next].Name)
+			lineages = append(lineages, &coalescentLineage{node: n, age: sorted[next].Age, deme: sorted[next].Deme})
+			next++
+		}
+
+		// Number of pairs of lineages in each deme
+		pairs := make([]float64, ndemes)
+		var totalpairs float64
+		count := make([]float64, ndemes)
+		for _, l := range lineages {
+			count[l.deme]++
+		}
+		for d, c := range count {
+			pairs[d] = c * (c - 1) / 2
+			totalpairs += pairs[d]
+		}
+
+		// Time to the next coalescence: integral of the rate
+		// totalpairs.exp(g.s)/popsize between age and age+dt is Exp(1)
+		dtcoal := math.Inf(1)
+		if totalpairs > 0 {
+			e := gostats.Exp(1) * popsize / totalpairs
+			if growth == 0 {
+				dtcoal = e
+			} else {
+				// log(exp(g.age)+g.e)/g - age, without overflow for old ages
+				dtcoal = math.Log1p(growth*e*math.Exp(-growth*age)) / growth
+			}
+		}
+		dtmig := math.Inf(1)
+		if ndemes > 1 && len(lineages) > 0 {
+			dtmig = gostats.Exp(migration * float64(len(lineages)))
+		}
+		dtsample := math.Inf(1)
+		if next < len(sorted) {
+			dtsample = sorted[next].Age - age
+		}
+
+		switch {
+		case dtsample <= dtcoal && dtsample <= dtmig:
+			if math.IsInf(dtsample, 1) {
+				return nil, errors.New("Lineages never coalesce")
+			}
+			age += dtsample
+		case dtmig < dtcoal:
+			age += dtmig
+			l := lineages[rand.Intn(len(lineages))]
+			d := rand.Intn(ndemes - 1)
+			if d >= l.deme {
+				d++
+			}
+			l.deme = d
+		default:
+			age += dtcoal
+			// Deme of the coalescence, and pair of lineages of this deme
+			r := rand.Float64() * totalpairs
+			deme := 0
+			for deme < ndemes-1 && r >= pairs[deme] {
+				r -= pairs[deme]
+				deme++
+			}
+			indices := make([]int, 0, int(count[deme]))
+			for i, l := range lineages {
+				if l.deme == deme {
+					indices = append(indices, i)
+				}
+			}
+			perm := rand.Perm(len(indices))
+			i1, i2 := indices[perm[0]], indices[perm[1]]
+			l1, l2 := lineages[i1], lineages[i2]
+			parent := t.NewNode()
+			e1 := t.ConnectNodes(parent, l1.node)
+			e2 := t.ConnectNodes(parent, l2.node)
+			e1.SetLength(age - l1.age)
+			e2.SetLength(age - l2.age)
+			lineages[i1] = &coalescentLineage{node: parent, age: age, deme: deme}
+			lineages[i2] = lineages[len(lineages)-1]
+			lineages = lineages[:len(lineages)-1]
+		}
+	}
+
+	t.SetRoot(lineages[0].node)
+	if err := t.ReinitIndexes(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
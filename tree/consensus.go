@@ -0,0 +1,273 @@
+package tree
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Builds the strict consensus of trees given in the input channel: only
+// bipartitions present in all the trees are kept.
+//
+// Branch supports and lengths are computed as in Consensus.
+func StrictConsensus(trees <-chan Trees) (*Tree, error) {
+	return Consensus(trees, 1)
+}
+
+// Builds the greedy consensus (extended majority rule consensus) of trees given in
+// the input channel: bipartitions are sorted by decreasing frequency, and are added
+// one by one to the consensus if they are compatible with all the bipartitions
+// added so far, until the consensus is fully resolved.
+//
+// Bipartitions having the same frequency are considered in a deterministic order
+// (given by their bitsets).
+//
+// Branch supports and lengths are computed as in Consensus.
+func GreedyConsensus(trees <-chan Trees) (*Tree, error) {
+	splits, err := countConsensusSplits(trees)
+	if err != nil {
+		return nil, err
+	}
+
+	ntips := uint(len(splits.alltips))
+	all := splits.edgeindex.Edges(0, splits.nbtrees)
+	keys := make([]string, len(all))
+	for i, kv := range all {
+		keys[i] = kv.key.DumpBitSet()
+	}
+	sort.Sort(&greedySplits{all, keys})
+
+	// Tip bipartitions, to set tip branch lengths
+	kept := make([]*KeyValue, 0, len(all))
+	// Internal compatible bipartitions
+	internal := make([]*KeyValue, 0, ntips)
+	for _, kv := range all {
+		c := kv.key.Bitset().Count()
+		if c <= 1 || c >= ntips-1 {
+			kept = append(kept, kv)
+			continue
+		}
+		if len(internal) >= int(ntips)-3 {
+			continue
+		}
+		compatible := true
+		for _, kv2 := range internal {
+			if !compatibleSplits(kv.key, kv2.key, ntips) {
+				compatible = false
+				break
+			}
+		}
+		if compatible {
+			internal = append(internal, kv)
+		}
+	}
+	kept = append(kept, internal...)
+
+	if err = splits.addToStarTree(kept); err != nil {
+		return nil, err
+	}
+	if err = splits.startree.ReinitIndexes(); err != nil {
+		return nil, err
+	}
+	return splits.startree, nil
+}
+
+// Returns true if the two bipartitions A1|B1 and A2|B2 are compatible,
+// i.e. if one of A1∩A2, A1∩B2, B1∩A2, B1∩B2 is empty.
+func compatibleSplits(e1, e2 *Edge, ntips uint) bool {
+	b1, b2 := e1.Bitset(), e2.Bitset()
+	c1, c2 := b1.Count(), b2.Count()
+	inter := b1.IntersectionCardinality(b2)
+	return inter == 0 || inter == c1 || inter == c2 || c1+c2-inter == ntips
+}
+
+// Sorts bipartitions by decreasing count, and then by bitset
+type greedySplits struct {
+	splits []*KeyValue
+	keys   []string
+}
+
+func (g *greedySplits) Len() int {
+	return len(g.splits)
+}
+
+func (g *greedySplits) Less(i, j int) bool {
+	if g.splits[i].val.Count != g.splits[j].val.Count {
+		return g.splits[i].val.Count > g.splits[j].val.Count
+	}
+	return g.keys[i] < g.keys[j]
+}
+
+func (g *greedySplits) Swap(i, j int) {
+	g.splits[i], g.splits[j] = g.splits[j], g.splits[i]
+	g.keys[i], g.keys[j] = g.keys[j], g.keys[i]
+}
+
+// Structure used to compute the Adams consensus of a rooted tree
+type adamsTree struct {
+	parent []*Node // Parent of each node (by node id)
+	depth  []int   // Depth of each node (by node id)
+	tips   []*Node // Tip nodes, in the order of the names of the consensus
+}
+
+// Builds the Adams consensus (Adams, 1972) of the rooted trees given in the
+// input channel. Starting from the whole set of tips, the tips are
+// recursively partitioned: the children of a node of the consensus are the
+// non empty intersections of the clusters defined by the children of the most
+// recent common ancestors of its tips in all the trees.
+//
+// The consensus is rooted. Branch supports are the proportion of trees in which
+// the bipartitions are present, and lengths are their average lengths
+// (branches that are present in no tree have no length).
+//
+// Returns an error if a tree is not rooted, or if the trees do not have the same
+// set of tips.
+func AdamsConsensus(trees <-chan Trees) (*Tree, error) {
+	var input []*Tree = make([]*Tree, 0)
+	for t := range trees {
+		if t.Err != nil {
+			for range trees {
+			}
+			return nil, t.Err
+		}
+		if !t.Tree.Rooted() {
+			for range trees {
+			}
+			return nil, errors.New("Adams consensus can only be computed on rooted trees")
+		}
+		input = append(input, t.Tree)
+	}
+
+	// Bipartition counts
+	counttrees := make(chan Trees, len(input))
+	for i, t := range input {
+		counttrees <- Trees{Tree: t, Id: i}
+	}
+	close(counttrees)
+	splits, err := countConsensusSplits(counttrees)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tips of the consensus, indexed as in splits.alltips
+	tipindex := make(map[string]int, len(splits.alltips))
+	for i, name := range splits.alltips {
+		tipindex[name] = i
+	}
+	adams := make([]*adamsTree, len(input))
+	for i, t := range input {
+		adams[i] = newAdamsTree(t, tipindex)
+	}
+
+	consensus := NewTree()
+	root := consensus.NewNode()
+	consensus.SetRoot(root)
+	tips := make([]int, len(splits.alltips))
+	for i := range tips {
+		tips[i] = i
+	}
+	adamsRecur(consensus, root, tips, adams, splits.alltips)
+
+	if err = consensus.ReinitIndexes(); err != nil {
+		return nil, err
+	}
+	// Supports and lengths
+	for _, e := range consensus.Edges() {
+		v, ok := splits.edgeindex.Value(e)
+		if !e.Right().Tip() {
+			e.SetSupport(0)
+			if ok {
+				e.SetSupport(float64(v.Count) / float64(splits.nbtrees))
+			}
+		}
+		if l := splits.meanLength(e); l != NIL_LENGTH {
+			// Both branches around a bifurcating root define the same bipartition
+			if e.Left() == root && len(root.Neigh()) == 2 {
+				l /= 2
+			}
+			e.SetLength(l)
+		}
+	}
+	return consensus, nil
+}
+
+// Initializes the structure used to compute the Adams consensus
+func newAdamsTree(t *Tree, tipindex map[string]int) (a *adamsTree) {
+	nodes := t.Nodes()
+	a = &adamsTree{
+		parent: make([]*Node, len(nodes)),
+		depth:  make([]int, len(nodes)),
+		tips:   make([]*Node, len(tipindex)),
+	}
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	t.PreOrder(func(cur, prev *Node, e *Edge) bool {
+		a.parent[cur.Id()] = prev
+		if prev != nil {
+			a.depth[cur.Id()] = a.depth[prev.Id()] + 1
+		}
+		if cur.Tip() {
+			a.tips[tipindex[cur.Name()]] = cur
+		}
+		return true
+	})
+	return
+}
+
+// Most recent common ancestor of the given tips
+func (a *adamsTree) mrca(tips []int) (anc *Node) {
+	anc = a.tips[tips[0]]
+	for _, tip := range tips[1:] {
+		n := a.tips[tip]
+		for a.depth[n.Id()] > a.depth[anc.Id()] {
+			n = a.parent[n.Id()]
+		}
+		for a.depth[anc.Id()] > a.depth[n.Id()] {
+			anc = a.parent[anc.Id()]
+		}
+		for n != anc {
+			n = a.parent[n.Id()]
+			anc = a.parent[anc.Id()]
+		}
+	}
+	return
+}
+
+// Adds to the consensus node the subtree corresponding to the given tips
+func adamsRecur(consensus *Tree, node *Node, tips []int, adams []*adamsTree, names []string) {
+	// For each tip: the children of the mrca containing the tip, in all trees
+	blocks := make(map[string][]int)
+	order := make([]string, 0)
+	mrcas := make([]*Node, len(adams))
+	for i, a := range adams {
+		mrcas[i] = a.mrca(tips)
+	}
+	for _, tip := range tips {
+		ids := make([]string, len(adams))
+		for i, a := range adams {
+			n := a.tips[tip]
+			for a.parent[n.Id()] != mrcas[i] {
+				n = a.parent[n.Id()]
+			}
+			ids[i] = strconv.Itoa(n.Id())
+		}
+		key := strings.Join(ids, ",")
+		if _, ok := blocks[key]; !ok {
+			order = append(order, key)
+		}
+		blocks[key] = append(blocks[key], tip)
+	}
+
+	for _, key := range order {
+		block := blocks[key]
+		child := consensus.NewNode()
+		consensus.ConnectNodes(node, child)
+		if len(block) == 1 {
+			child.SetName(names[block[0]])
+		} else {
+			adamsRecur(consensus, child, block, adams, names)
+		}
+	}
+}
@@ -52,6 +52,16 @@ func (e *Edge) setRight(right *Node) {
 	e.right = right
 }
 
+// Replaces node old by node new in the edge extremities
+// (keeping the orientation)
+func (e *Edge) replaceNode(old, new *Node) {
+	if e.left == old {
+		e.left = new
+	} else if e.right == old {
+		e.right = new
+	}
+}
+
 // Inverse Edge orientation:
 // left becomes right and
 // right becomes left
@@ -0,0 +1,607 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Constraint on the date of a node: Min <= date <= Max.
+//
+// If Tips contains a single name, the constraint applies to the tip having this
+// name, otherwise it applies to the most recent common ancestor of the tips.
+// Min and Max may be infinite (one sided constraint), and are equal for an
+// exact date.
+type DateConstraint struct {
+	Tips []string
+	Min  float64
+	Max  float64
+}
+
+// Result of a least-squares dating
+type LSDStats struct {
+	Rate      float64 // Substitution rate
+	RootDate  float64 // Date of the root
+	Objective float64 // Weighted sum of squares of the branch residuals
+}
+
+// Structure storing the nodes of the tree in preorder, with their
+// parent, constraints and current dates
+type lsdNodes struct {
+	nodes    []*Node   // Nodes in preorder
+	parent   []int     // Index of the parent of each node, -1 for the root
+	children [][]int   // Indexes of the children of each node
+	length   []float64 // Length of the branch to the parent
+	weight   []float64 // Weight of the branch to the parent
+	min      []float64 // Lower bound of the date of each node
+	max      []float64 // Upper bound of the date of each node
+	date     []float64 // Current date of each node
+}
+
+const (
+	lsdMaxIterations = 10000
+	lsdEpsilon       = 1e-12
+)
+
+// Converts the substitution branch lengths of the rooted tree into time
+// branch lengths, by least-squares dating (LSD, To et al., 2016).
+//
+// Dates of the nodes t and the substitution rate w minimize the sum over all
+// branches from parent p to child c of weight(c).(b(c) - w.(t(c) - t(p)))²,
+// where b(c) is the substitution length of the branch, under the constraints
+// given in argument, and t(p) <= t(c). Tips without constraints have
+// their dates estimated as well.
+//
+// If seqlen is > 0, each branch is weighted by 1/(b(c) + 1/seqlen), as its
+// variance under a Poisson model of substitutions, otherwise all the branches
+// have the same weight. If rate is > 0, it is considered as known, otherwise it
+// is estimated.
+//
+// Dates are computed exactly given the rate (To et al., 2016). If they violate
+// interval or temporal constraints, the constrained least-squares dates are
+// computed with an active set method. If the rate is estimated and constraints
+// are violated, the rate and the dates are optimized alternately until
+// convergence.
+//
+// Branch lengths of the tree are replaced by time durations, and each node is
+// annotated with a comment "date=<date>".
+func (t *Tree) LSDDating(constraints []DateConstraint, rate float64, seqlen int) (stats LSDStats, err error) {
+	var ln *lsdNodes
+
+	if !t.Rooted() {
+		err = errors.New("The tree must be rooted")
+		return
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == NIL_LENGTH {
+			err = errors.New("All branch lengths must be defined")
+			return
+		}
+	}
+	if ln, err = newLSDNodes(t, constraints, seqlen); err != nil {
+		return
+	}
+
+	if rate <= 0 {
+		if rate, err = ln.estimateRate(); err != nil {
+			return
+		}
+	} else {
+		ln.solveDates(rate)
+		if !ln.feasible() {
+			ln.project(rate)
+		}
+	}
+
+	stats.Rate = rate
+	stats.RootDate = ln.date[0]
+	stats.Objective = ln.objective(rate)
+
+	for v, n := range ln.nodes {
+		if v > 0 {
+			i, _ := n.NodeIndex(ln.nodes[ln.parent[v]])
+			n.Edges()[i].SetLength(ln.date[v] - ln.date[ln.parent[v]])
+		}
+		n.AddComment("date=" + strconv.FormatFloat(ln.date[v], 'f', -1, 64))
+	}
+	return
+}
+
+// Indexes the nodes of the tree and their constraints
+func newLSDNodes(t *Tree, constraints []DateConstraint, seqlen int) (ln *lsdNodes, err error) {
+	ln = &lsdNodes{}
+	depth := make([]int, 0)
+	tips := make(map[string]int)
+	t.PreOrder(func(cur, prev *Node, e *Edge) bool {
+		v := len(ln.nodes)
+		cur.SetId(v)
+		ln.nodes = append(ln.nodes, cur)
+		ln.children = append(ln.children, make([]int, 0))
+		ln.min = append(ln.min, math.Inf(-1))
+		ln.max = append(ln.max, math.Inf(1))
+		ln.date = append(ln.date, 0)
+		if prev == nil {
+			ln.parent = append(ln.parent, -1)
+			ln.length = append(ln.length, 0)
+			ln.weight = append(ln.weight, 0)
+			depth = append(depth, 0)
+		} else {
+			w := 1.0
+			if seqlen > 0 {
+				w = 1.0 / (e.Length() + 1.0/float64(seqlen))
+			}
+			ln.parent = append(ln.parent, prev.Id())
+			ln.children[prev.Id()] = append(ln.children[prev.Id()], v)
+			ln.length = append(ln.length, e.Length())
+			ln.weight = append(ln.weight, w)
+			depth = append(depth, depth[prev.Id()]+1)
+		}
+		if cur.Tip() {
+			tips[cur.Name()] = v
+		}
+		return true
+	})
+
+	if len(constraints) == 0 {
+		err = errors.New("At least one date constraint is required")
+		return
+	}
+	for _, c := range constraints {
+		if len(c.Tips) == 0 {
+			err = errors.New("A date constraint has no tip")
+			return
+		}
+		if c.Min > c.Max {
+			err = fmt.Errorf("Wrong date constraint for %s: %v > %v", strings.Join(c.Tips, ","), c.Min, c.Max)
+			return
+		}
+		mrca := -1
+		for _, name := range c.Tips {
+			v, ok := tips[name]
+			if !ok {
+				err = fmt.Errorf("Tip %s of a date constraint is not present in the tree", name)
+				return
+			}
+			if mrca == -1 {
+				mrca = v
+				continue
+			}
+			for depth[v] > depth[mrca] {
+				v = ln.parent[v]
+			}
+			for depth[mrca] > depth[v] {
+				mrca = ln.parent[mrca]
+			}
+			for v != mrca {
+				v, mrca = ln.parent[v], ln.parent[mrca]
+			}
+		}
+		ln.min[mrca] = math.Max(ln.min[mrca], c.Min)
+		ln.max[mrca] = math.Min(ln.max[mrca], c.Max)
+	}
+
+	// Temporal constraints: a node is not older than its ancestors
+	// nor younger than its descendants
+	lower := make([]float64, len(ln.nodes))
+	upper := make([]float64, len(ln.nodes))
+	for v := range ln.nodes {
+		lower[v] = ln.min[v]
+		if p := ln.parent[v]; p >= 0 {
+			lower[v] = math.Max(lower[v], lower[p])
+		}
+	}
+	for v := len(ln.nodes) - 1; v >= 0; v-- {
+		upper[v] = ln.max[v]
+		for _, c := range ln.children[v] {
+			upper[v] = math.Min(upper[v], upper[c])
+		}
+		if lower[v] > upper[v] {
+			err = errors.New("Date constraints are not compatible with the tree")
+			return
+		}
+	}
+	ln.min, ln.max = lower, upper
+	return
+}
+
+// Estimates the rate and the dates minimizing the objective.
+//
+// Without interval and temporal constraints, the duration of each branch is
+// an affine function p + q/rate of the inverse of the rate, so that the
+// objective is a quadratic function of the rate, having an analytical minimum
+// (To et al., 2016). If the corresponding dates violate the constraints, the
+// rate and the dates are optimized alternately, starting from this rate. If
+// this minimum is not positive (no temporal signal), they are optimized
+// alternately starting from the root-to-tip regression rate (see initialRate).
+func (ln *lsdNodes) estimateRate() (rate float64, err error) {
+	var num, den float64
+
+	n := len(ln.nodes)
+	// Durations with rate=inf (q=0) and rate=1
+	ln.solveDates(math.Inf(1))
+	p := make([]float64, n)
+	for v := 1; v < n; v++ {
+		p[v] = ln.date[v] - ln.date[ln.parent[v]]
+	}
+	ln.solveDates(1)
+	for v := 1; v < n; v++ {
+		q := ln.date[v] - ln.date[ln.parent[v]] - p[v]
+		num += ln.weight[v] * (ln.length[v] - q) * p[v]
+		den += ln.weight[v] * p[v] * p[v]
+	}
+	// Without temporal signal, the analytical minimum is not valid: the
+	// rate and the dates are optimized alternately from an initial rate
+	analytical := den != 0 && num/den > 0
+	if rate = num / den; !analytical {
+		rate = ln.initialRate()
+	}
+
+	prevobj := math.Inf(1)
+	for i := 0; i < lsdMaxIterations; i++ {
+		// The constrained dates of the previous rate are the starting
+		// point of the active set method
+		if i == 0 {
+			if ln.solveDates(rate); analytical && ln.feasible() {
+				return
+			}
+		}
+		ln.project(rate)
+		num, den = 0, 0
+		for v := 1; v < n; v++ {
+			d := ln.date[v] - ln.date[ln.parent[v]]
+			num += ln.weight[v] * ln.length[v] * d
+			den += ln.weight[v] * d * d
+		}
+		if den == 0 || num <= 0 {
+			err = errors.New("Cannot estimate the substitution rate: constraints are not informative enough")
+			return
+		}
+		newrate := num / den
+		obj := ln.objective(newrate)
+		if prevobj-obj <= lsdEpsilon*obj || math.Abs(newrate-rate) <= lsdEpsilon*rate {
+			rate = newrate
+			break
+		}
+		rate, prevobj = newrate, obj
+	}
+	return
+}
+
+// Slope of the regression of root-to-tip distances against the dates of
+// the tips having a bounded constraint, or 1 if it is not positive
+func (ln *lsdNodes) initialRate() float64 {
+	var s rttSums
+	var sumtt float64
+
+	dist := make([]float64, len(ln.nodes))
+	for v := 1; v < len(ln.nodes); v++ {
+		dist[v] = dist[ln.parent[v]] + ln.length[v]
+		if len(ln.children[v]) == 0 && !math.IsInf(ln.min[v], 0) && !math.IsInf(ln.max[v], 0) {
+			d := (ln.min[v] + ln.max[v]) / 2.0
+			s = s.add(rttSums{1, d, dist[v], dist[v] * dist[v], d * dist[v]})
+			sumtt += d * d
+		}
+	}
+	if s.n < 2 {
+		return 1.0
+	}
+	stt := sumtt - s.t*s.t/s.n
+	std := s.td - s.t*s.d/s.n
+	if stt <= 0 || std <= 0 {
+		return 1.0
+	}
+	return std / stt
+}
+
+// Computes the dates minimizing the objective for the given rate, considering
+// only exact date constraints: each subtree hanging from a free node v is
+// summarized by a quadratic function a(v).(t(p) - m(v))² of the date of its
+// parent p.
+func (ln *lsdNodes) solveDates(rate float64) {
+	n := len(ln.nodes)
+	a := make([]float64, n)
+	m := make([]float64, n)
+	sum := make([]float64, n)
+	mean := make([]float64, n)
+
+	for v := n - 1; v >= 0; v-- {
+		var s, sm float64
+		for _, c := range ln.children[v] {
+			s += a[c]
+			sm += a[c] * m[c]
+		}
+		sum[v] = s
+		if s > 0 {
+			mean[v] = sm / s
+		}
+		if v == 0 {
+			break
+		}
+		r := ln.length[v] / rate
+		w := ln.weight[v]
+		if ln.fixed(v) {
+			a[v], m[v] = w, ln.min[v]-r
+		} else if s > 0 {
+			a[v], m[v] = s*w/(s+w), mean[v]-r
+		}
+	}
+
+	for v := 0; v < n; v++ {
+		switch {
+		case ln.fixed(v):
+			ln.date[v] = ln.min[v]
+		case v == 0:
+			ln.date[v] = mean[v]
+			if sum[v] == 0 {
+				ln.date[v] = ln.clamp(v, 0)
+			}
+		default:
+			r := ln.length[v] / rate
+			w := ln.weight[v]
+			ln.date[v] = (sum[v]*mean[v] + w*(ln.date[ln.parent[v]]+r)) / (sum[v] + w)
+		}
+	}
+}
+
+// True if the date of the node is known
+func (ln *lsdNodes) fixed(v int) bool {
+	return ln.min[v] == ln.max[v]
+}
+
+// Returns the date, bounded by the constraints of the node
+func (ln *lsdNodes) clamp(v int, date float64) float64 {
+	return math.Min(math.Max(date, ln.min[v]), ln.max[v])
+}
+
+// True if the current dates satisfy all the constraints
+func (ln *lsdNodes) feasible() bool {
+	for v := range ln.nodes {
+		if ln.date[v] < ln.min[v] || ln.date[v] > ln.max[v] {
+			return false
+		}
+		if p := ln.parent[v]; p >= 0 && ln.date[v] < ln.date[p] {
+			return false
+		}
+	}
+	return true
+}
+
+// Minimizes the objective for the given rate under the interval and temporal
+// constraints, with an active set method (To et al., 2016), starting from the
+// current dates moved into the feasible region.
+//
+// The working set contains temporal constraints t(p) = t(c), which merge nodes
+// into blocks having the same date, and bound constraints, which fix the date
+// of a block (at most one per block). At each iteration, the dates minimizing
+// the objective given the working set are computed exactly as in solveDates, on
+// the tree of the blocks. If they are not feasible, the dates move towards them
+// until a constraint is reached, which is added to the working set. Otherwise,
+// the constraint of the working set having the most negative Lagrange multiplier
+// is removed, until all the multipliers are non negative (optimal dates).
+func (ln *lsdNodes) project(rate float64) {
+	n := len(ln.nodes)
+	active := make([]bool, n) // Temporal constraint of each node with its parent
+	bound := make([]int, n)   // -1: min bound, 1: max bound, 0: no bound
+	top := make([]int, n)     // Top node of the block of each node
+	target := make([]float64, n)
+	grad := make([]float64, n)
+	sub := make([]float64, n)
+	withbound := make([]bool, n)
+
+	for v := range ln.nodes {
+		d := ln.clamp(v, ln.date[v])
+		if p := ln.parent[v]; p >= 0 {
+			d = math.Max(d, ln.date[p])
+		}
+		ln.date[v] = d
+	}
+
+	// Initial working set: active constraints, with at most one bound per block
+	tol := lsdEpsilon * ln.scale()
+	for v := range ln.nodes {
+		top[v] = v
+		if p := ln.parent[v]; p >= 0 && ln.date[v]-ln.date[p] <= tol && !(ln.fixed(v) && withbound[top[p]]) {
+			active[v] = true
+			top[v] = top[p]
+		}
+		switch {
+		case withbound[top[v]]:
+		case ln.fixed(v) || ln.date[v]-ln.min[v] <= tol:
+			bound[v] = -1
+		case ln.max[v]-ln.date[v] <= tol:
+			bound[v] = 1
+		}
+		if bound[v] != 0 {
+			withbound[top[v]] = true
+		}
+	}
+
+	for i := 0; i < lsdMaxIterations+10*n; i++ {
+		ln.blockDates(rate, active, bound, top, target)
+
+		// Largest step towards the target dates
+		step, blocking, blockingbound := 1.0, -1, 0
+		moved := false
+		for v := range ln.nodes {
+			d := target[v] - ln.date[v]
+			if math.Abs(d) > tol {
+				moved = true
+			}
+			if bound[v] == 0 {
+				if d < 0 && !math.IsInf(ln.min[v], -1) {
+					if s := (ln.date[v] - ln.min[v]) / -d; s < step {
+						step, blocking, blockingbound = s, v, -1
+					}
+				}
+				if d > 0 && !math.IsInf(ln.max[v], 1) {
+					if s := (ln.max[v] - ln.date[v]) / d; s < step {
+						step, blocking, blockingbound = s, v, 1
+					}
+				}
+			}
+			if p := ln.parent[v]; p >= 0 && !active[v] {
+				if dd := d - (target[p] - ln.date[p]); dd < 0 {
+					if s := (ln.date[v] - ln.date[p]) / -dd; s < step {
+						step, blocking, blockingbound = s, v, 0
+					}
+				}
+			}
+		}
+		if moved && blocking >= 0 {
+			step = math.Max(step, 0)
+			for v := range ln.nodes {
+				ln.date[v] += step * (target[v] - ln.date[v])
+			}
+			if blockingbound != 0 {
+				bound[blocking] = blockingbound
+			} else {
+				active[blocking] = true
+				ln.updateBlocks(active, top)
+			}
+			continue
+		}
+		copy(ln.date, target)
+
+		// Lagrange multipliers of the working set: sums of the gradient of the
+		// objective over the part of the block separated by each constraint,
+		// which does not contain the bound of the block
+		for v := range ln.nodes {
+			grad[v] = 0
+			if p := ln.parent[v]; p >= 0 {
+				grad[v] += ln.weight[v] * (ln.date[v] - ln.date[p] - ln.length[v]/rate)
+			}
+			for _, c := range ln.children[v] {
+				grad[v] -= ln.weight[c] * (ln.date[c] - ln.date[v] - ln.length[c]/rate)
+			}
+			sub[v], withbound[v] = grad[v], bound[v] != 0
+		}
+		for v := n - 1; v > 0; v-- {
+			if active[v] {
+				p := ln.parent[v]
+				sub[p] += sub[v]
+				withbound[p] = withbound[p] || withbound[v]
+			}
+		}
+		worst, worstnode, worstbound := -tol*ln.gradScale(grad), -1, false
+		for v := range ln.nodes {
+			if !active[v] {
+				continue
+			}
+			mult := sub[v]
+			if withbound[v] {
+				mult = sub[v] - sub[top[v]]
+			}
+			if mult < worst {
+				worst, worstnode, worstbound = mult, v, false
+			}
+		}
+		for v := range ln.nodes {
+			if bound[v] != 0 && !ln.fixed(v) {
+				if mult := float64(-bound[v]) * sub[top[v]]; mult < worst {
+					worst, worstnode, worstbound = mult, v, true
+				}
+			}
+		}
+		if worstnode < 0 {
+			return
+		}
+		if worstbound {
+			bound[worstnode] = 0
+		} else {
+			active[worstnode] = false
+			ln.updateBlocks(active, top)
+		}
+	}
+}
+
+// Updates the top node of the block of each node
+func (ln *lsdNodes) updateBlocks(active []bool, top []int) {
+	for v := range ln.nodes {
+		top[v] = v
+		if active[v] {
+			top[v] = top[ln.parent[v]]
+		}
+	}
+}
+
+// Computes the dates minimizing the objective for the given rate, when the
+// nodes of each block have the same date, and the blocks having a bound
+// have the date of this bound (see solveDates)
+func (ln *lsdNodes) blockDates(rate float64, active []bool, bound []int, top []int, dates []float64) {
+	n := len(ln.nodes)
+	sum := make([]float64, n)
+	summean := make([]float64, n)
+	fixed := make([]bool, n)
+	value := make([]float64, n)
+
+	for v := range ln.nodes {
+		if bound[v] != 0 {
+			fixed[top[v]] = true
+			value[top[v]] = ln.min[v]
+			if bound[v] > 0 {
+				value[top[v]] = ln.max[v]
+			}
+		}
+	}
+	for v := n - 1; v > 0; v-- {
+		if active[v] {
+			continue
+		}
+		var a, m float64
+		r := ln.length[v] / rate
+		w := ln.weight[v]
+		if fixed[v] {
+			a, m = w, value[v]-r
+		} else if sum[v] > 0 {
+			a, m = sum[v]*w/(sum[v]+w), summean[v]/sum[v]-r
+		}
+		sum[top[ln.parent[v]]] += a
+		summean[top[ln.parent[v]]] += a * m
+	}
+	for v := 0; v < n; v++ {
+		switch {
+		case top[v] != v:
+			dates[v] = dates[top[v]]
+		case fixed[v]:
+			dates[v] = value[v]
+		case v == 0:
+			dates[v] = ln.date[v]
+			if sum[v] > 0 {
+				dates[v] = summean[v] / sum[v]
+			}
+		default:
+			r := ln.length[v] / rate
+			w := ln.weight[v]
+			dates[v] = (summean[v] + w*(dates[top[ln.parent[v]]]+r)) / (sum[v] + w)
+		}
+	}
+}
+
+// Scale of the dates, used for tolerances
+func (ln *lsdNodes) scale() (s float64) {
+	s = 1
+	for v := range ln.nodes {
+		s = math.Max(s, math.Abs(ln.date[v]))
+	}
+	return
+}
+
+// Scale of the gradient of the objective, used for tolerances
+func (ln *lsdNodes) gradScale(grad []float64) (s float64) {
+	s = 1
+	for _, g := range grad {
+		s = math.Max(s, math.Abs(g))
+	}
+	return
+}
+
+// Weighted sum of squares of the branch residuals
+func (ln *lsdNodes) objective(rate float64) (obj float64) {
+	for v := 1; v < len(ln.nodes); v++ {
+		r := ln.length[v] - rate*(ln.date[v]-ln.date[ln.parent[v]])
+		obj += ln.weight[v] * r * r
+	}
+	return
+}
@@ -0,0 +1,153 @@
+package tree
+
+import (
+	"errors"
+	"math"
+)
+
+// Sums over the tips y of the subtree of each node, seen from a tip x:
+// number of tips, sum of 1/d(x,y) and of 1/d(x,y)²
+type madSums struct {
+	dist []float64 // Distance from x to each node
+	n    []float64
+	s1   []float64
+	s2   []float64
+}
+
+// Sum over the tips y of the subtree of node v of the squared relative
+// deviations (2.a/d(x,y) - 1)² of an ancestor located at distance a from x
+func (s *madSums) deviation(a float64, v int) float64 {
+	return 4*a*a*s.s2[v] - 4*a*s.s1[v] + s.n[v]
+}
+
+// Reroots the tree using the Minimal Ancestor Deviation method (MAD, Tria et
+// al., 2017).
+//
+// For a given root position, the relative deviation of the ancestor a of each
+// pair of tips (x,y) is 2.d(x,a)/d(x,y) - 1, and the ancestor deviation of the
+// rooted tree is the root mean square of the relative deviations of all the pairs
+// of tips. For each branch, the position of the root minimizing the ancestor
+// deviation is computed analytically, and the tree is rerooted at the
+// position having the minimum ancestor deviation among all branches.
+// Pairs of tips at distance 0 are not taken into account.
+//
+// It returns the minimum ancestor deviation, and the root ambiguity index, which
+// is the ratio between the minimum ancestor deviation and the minimum ancestor
+// deviation of the second best branch (close to 1 if the root position is
+// ambiguous).
+//
+// All branch lengths must be defined.
+func (t *Tree) RerootMAD() (mad, ai float64, err error) {
+	var npairs float64
+
+	tips := t.Tips()
+	if len(tips) < 3 {
+		err = errors.New("At least 3 tips are required for MAD rooting")
+		return
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == NIL_LENGTH {
+			err = errors.New("All branch lengths must be defined")
+			return
+		}
+	}
+
+	t.UnRoot()
+	nodes := t.Nodes()
+	edges := t.Edges()
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	for i, e := range edges {
+		e.SetId(i)
+	}
+	// Ancestor deviation of each branch, as a function A.r² + B.r + C
+	// of the relative position r of the root from the left node
+	qa := make([]float64, len(edges))
+	qb := make([]float64, len(edges))
+	qc := make([]float64, len(edges))
+	s := &madSums{
+		dist: make([]float64, len(nodes)),
+		n:    make([]float64, len(nodes)),
+		s1:   make([]float64, len(nodes)),
+		s2:   make([]float64, len(nodes)),
+	}
+	f := make([]float64, len(nodes))
+
+	// Each pair of tips is considered twice: from x and from y
+	for _, x := range tips {
+		s.sums(x, nil, x, 0)
+		npairs += s.n[x.Id()]
+		f[x.Id()] = s.n[x.Id()]
+		t.preOrderRecur(x, nil, nil, func(cur, prev *Node, e *Edge) bool {
+			if prev == nil {
+				return true
+			}
+			u, w := prev.Id(), cur.Id()
+			// Tips y that are not under cur: their ancestor with x is the
+			// ancestor of x and prev in the tree rooted at x
+			k := f[u] - s.deviation(s.dist[u], w)
+			// Tips y under cur: their ancestor with x is on the branch
+			alpha, beta := s.dist[u], e.Length()
+			if e.Left() != prev {
+				alpha, beta = s.dist[u]+e.Length(), -e.Length()
+			}
+			qa[e.Id()] += 4 * beta * beta * s.s2[w]
+			qb[e.Id()] += 8*alpha*beta*s.s2[w] - 4*beta*s.s1[w]
+			qc[e.Id()] += s.deviation(alpha, w) + k
+			f[w] = f[u] + s.deviation(s.dist[w], w) - s.deviation(s.dist[u], w)
+			return true
+		})
+	}
+	if npairs == 0 {
+		err = errors.New("All tips are at distance 0")
+		return
+	}
+
+	var best *Edge
+	var bestpos float64
+	mad, ai = math.Inf(1), math.Inf(1)
+	for _, e := range edges {
+		r := 0.0
+		if qa[e.Id()] > 0 {
+			r = math.Min(math.Max(-qb[e.Id()]/(2*qa[e.Id()]), 0), 1)
+		} else if qb[e.Id()] < 0 {
+			r = 1
+		}
+		dev := math.Sqrt(math.Max((qa[e.Id()]*r*r+qb[e.Id()]*r+qc[e.Id()])/npairs, 0))
+		if dev < mad {
+			ai = mad
+			mad = dev
+			best = e
+			bestpos = r * e.Length()
+		} else if dev < ai {
+			ai = dev
+		}
+	}
+	if ai > 0 {
+		ai = mad / ai
+	} else {
+		ai = 1
+	}
+
+	err = t.rootOnEdge(best.Left(), best.Right(), bestpos)
+	return
+}
+
+// Computes the distances from x and the sums of each subtree
+func (s *madSums) sums(cur, prev, x *Node, d float64) {
+	v := cur.Id()
+	s.dist[v] = d
+	s.n[v], s.s1[v], s.s2[v] = 0, 0, 0
+	if cur.Tip() && cur != x && d > 0 {
+		s.n[v], s.s1[v], s.s2[v] = 1, 1/d, 1/(d*d)
+	}
+	for i, next := range cur.Neigh() {
+		if next != prev {
+			s.sums(next, cur, x, d+cur.Edges()[i].Length())
+			s.n[v] += s.n[next.Id()]
+			s.s1[v] += s.s1[next.Id()]
+			s.s2[v] += s.s2[next.Id()]
+		}
+	}
+}
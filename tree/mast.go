@@ -0,0 +1,320 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Structure used to compute maximum agreement subtrees: each directed
+// subtree (a node and all its descendants when coming from one of its
+// neighbors) is indexed.
+type mastTree struct {
+	children [][]int // Child subtrees of each subtree
+	label    []int   // Index of the tip if the subtree is a tip, -1 otherwise
+	offset   []int   // Index of the first subtree of each node (by node id)
+}
+
+// Dynamic programming structure: mast size of each pair of subtrees
+type mastDP struct {
+	t1, t2 *mastTree
+	names  []string
+	memo   []int32
+}
+
+// Computes a maximum agreement subtree (MAST) of the two given trees: a tree
+// having the largest possible set of tips L, such that the two trees restricted
+// to L have the same topology.
+//
+// Trees may have different sets of tips, the MAST is computed on their common
+// tips. If rooted is true, then both trees must be rooted and the agreement
+// is computed on the rooted topologies. Otherwise, trees are considered unrooted.
+// Multifurcations are considered as hard polytomies (i.e. they must be present in
+// both trees).
+//
+// The returned MAST is the first tree restricted to the tips of the MAST (it
+// keeps the branch lengths of the first tree). It also returns the names of the
+// tips removed from the first and from the second trees.
+//
+// The algorithm is the dynamic programming of Steel & Warnow (1993), computing the
+// size of the MAST of all pairs of subtrees of the two trees. Its complexity is
+// O(n^2) in memory, and, on binary trees, O(n^2) in time. An error is returned if
+// the trees share less than 3 tips.
+func MaximumAgreementSubtree(t1, t2 *Tree, rooted bool) (mast *Tree, removed1, removed2 []string, err error) {
+	var common []string
+	var c1, c2 *Tree
+	var dp *mastDP
+	var d1, d2 int
+	var rootTip string
+
+	if rooted && (!t1.Rooted() || !t2.Rooted()) {
+		err = errors.New("Rooted maximum agreement subtree can only be computed on rooted trees")
+		return
+	}
+	if common, err = commonTipNames(t1, t2); err != nil {
+		return
+	}
+	if len(common) < 3 {
+		err = fmt.Errorf("Trees share only %d tips, at least 3 are required", len(common))
+		return
+	}
+	if c1, err = restrictTree(t1, common); err != nil {
+		return
+	}
+	if c2, err = restrictTree(t2, common); err != nil {
+		return
+	}
+
+	labels := make(map[string]int, len(common))
+	for i, name := range common {
+		labels[name] = i
+	}
+	dp = &mastDP{
+		t1:    newMastTree(c1, labels, rooted),
+		t2:    newMastTree(c2, labels, rooted),
+		names: common,
+	}
+	dp.memo = make([]int32, len(dp.t1.children)*len(dp.t2.children))
+	for i := range dp.memo {
+		dp.memo[i] = -1
+	}
+
+	if rooted {
+		// Virtual subtrees containing the whole trees
+		d1, d2 = len(dp.t1.children)-1, len(dp.t2.children)-1
+		dp.value(d1, d2)
+	} else {
+		// The unrooted MAST contains at least one tip x. Its size is
+		// 1 + the MAST of the two subtrees obtained by rooting
+		// the trees on x, and removing x
+		tips2 := make(map[string]*Node)
+		for _, tip := range c2.Tips() {
+			tips2[tip.Name()] = tip
+		}
+		var best int32 = -1
+		for _, tip1 := range c1.Tips() {
+			tip2 := tips2[tip1.Name()]
+			s1, s2 := dp.t1.subtree(tip1, 0), dp.t2.subtree(tip2, 0)
+			if v := dp.value(s1, s2); v > best {
+				best = v
+				d1, d2 = s1, s2
+				rootTip = tip1.Name()
+			}
+			if int(best)+1 == len(common) {
+				break
+			}
+		}
+	}
+
+	leaves := make([]string, 0, len(common))
+	if !rooted {
+		// Tip at which the trees have been rooted
+		leaves = append(leaves, rootTip)
+	}
+	dp.traceback(d1, d2, &leaves)
+
+	inmast := make(map[string]bool, len(leaves))
+	for _, name := range leaves {
+		inmast[name] = true
+	}
+	for _, name := range t1.AllTipNames() {
+		if !inmast[name] {
+			removed1 = append(removed1, name)
+		}
+	}
+	for _, name := range t2.AllTipNames() {
+		if !inmast[name] {
+			removed2 = append(removed2, name)
+		}
+	}
+
+	if len(leaves) < 3 {
+		// Rooted MAST having only 2 tips
+		mast = NewTree()
+		root := mast.NewNode()
+		mast.SetRoot(root)
+		for _, name := range leaves {
+			tip := mast.NewNode()
+			tip.SetName(name)
+			mast.ConnectNodes(root, tip)
+		}
+		err = mast.UpdateTipIndex()
+		return
+	}
+	if mast, err = restrictTree(c1, leaves); err != nil {
+		return
+	}
+	if !rooted {
+		mast.UnRoot()
+	}
+	return
+}
+
+// Copies the tree, keeping only the given tips
+func restrictTree(t *Tree, tips []string) (c *Tree, err error) {
+	c = t.Clone()
+	if len(tips) < len(c.Tips()) {
+		err = c.RemoveTips(true, tips...)
+	}
+	return
+}
+
+// Indexes the subtrees of the tree. If rooted is true, a last
+// subtree is added, containing the whole tree
+func newMastTree(t *Tree, labels map[string]int, rooted bool) (m *mastTree) {
+	nodes := t.Nodes()
+	m = &mastTree{
+		offset: make([]int, len(nodes)),
+	}
+	nsubtrees := 0
+	for i, n := range nodes {
+		n.SetId(i)
+		m.offset[i] = nsubtrees
+		nsubtrees += n.Nneigh()
+	}
+	if rooted {
+		nsubtrees++
+	}
+	m.children = make([][]int, nsubtrees)
+	m.label = make([]int, nsubtrees)
+
+	for _, n := range nodes {
+		for i, child := range n.Neigh() {
+			s := m.subtree(n, i)
+			m.label[s] = -1
+			if child.Tip() {
+				m.label[s] = labels[child.Name()]
+				continue
+			}
+			m.children[s] = make([]int, 0, child.Nneigh()-1)
+			for j, next := range child.Neigh() {
+				if next != n {
+					m.children[s] = append(m.children[s], m.subtree(child, j))
+				}
+			}
+		}
+	}
+	if rooted {
+		root := t.Root()
+		s := nsubtrees - 1
+		m.label[s] = -1
+		for i := range root.Neigh() {
+			m.children[s] = append(m.children[s], m.subtree(root, i))
+		}
+	}
+	return
+}
+
+// Index of the subtree rooted at the i-th neighbor of the node
+// (coming from the node)
+func (m *mastTree) subtree(n *Node, i int) int {
+	return m.offset[n.Id()] + i
+}
+
+// Size of the MAST of the two given subtrees
+func (dp *mastDP) value(d1, d2 int) int32 {
+	idx := d1*len(dp.t2.children) + d2
+	if v := dp.memo[idx]; v >= 0 {
+		return v
+	}
+	var best int32
+	l1, l2 := dp.t1.label[d1], dp.t2.label[d2]
+	switch {
+	case l1 >= 0 && l2 >= 0:
+		if l1 == l2 {
+			best = 1
+		}
+	default:
+		if l1 < 0 {
+			for _, c := range dp.t1.children[d1] {
+				best = max32(best, dp.value(c, d2))
+			}
+		}
+		if l2 < 0 {
+			for _, c := range dp.t2.children[d2] {
+				best = max32(best, dp.value(d1, c))
+			}
+		}
+		if l1 < 0 && l2 < 0 {
+			_, m := dp.matching(d1, d2)
+			best = max32(best, m)
+		}
+	}
+	dp.memo[idx] = best
+	return best
+}
+
+// Maximum weight matching between the children of the two subtrees, the weight
+// of two children being the size of their MAST. Returns the matched children
+// and the total weight
+func (dp *mastDP) matching(d1, d2 int) (pairs [][2]int, total int32) {
+	ch1, ch2 := dp.t1.children[d1], dp.t2.children[d2]
+	if len(ch1) == 2 && len(ch2) == 2 {
+		a := dp.value(ch1[0], ch2[0]) + dp.value(ch1[1], ch2[1])
+		b := dp.value(ch1[0], ch2[1]) + dp.value(ch1[1], ch2[0])
+		if a >= b {
+			return [][2]int{{ch1[0], ch2[0]}, {ch1[1], ch2[1]}}, a
+		}
+		return [][2]int{{ch1[0], ch2[1]}, {ch1[1], ch2[0]}}, b
+	}
+	size := len(ch1)
+	if len(ch2) > size {
+		size = len(ch2)
+	}
+	cost := make([][]float64, size)
+	for i := range cost {
+		cost[i] = make([]float64, size)
+		for j := range cost[i] {
+			if i < len(ch1) && j < len(ch2) {
+				cost[i][j] = -float64(dp.value(ch1[i], ch2[j]))
+			}
+		}
+	}
+	assignment, _ := minCostAssignment(cost)
+	for i, j := range assignment {
+		if i < len(ch1) && j < len(ch2) {
+			pairs = append(pairs, [2]int{ch1[i], ch2[j]})
+			total += dp.value(ch1[i], ch2[j])
+		}
+	}
+	return
+}
+
+// Adds to leaves the names of the tips of the MAST of the two subtrees
+func (dp *mastDP) traceback(d1, d2 int, leaves *[]string) {
+	v := dp.value(d1, d2)
+	if v == 0 {
+		return
+	}
+	l1, l2 := dp.t1.label[d1], dp.t2.label[d2]
+	if l1 >= 0 && l2 >= 0 {
+		*leaves = append(*leaves, dp.names[l1])
+		return
+	}
+	if l1 < 0 {
+		for _, c := range dp.t1.children[d1] {
+			if dp.value(c, d2) == v {
+				dp.traceback(c, d2, leaves)
+				return
+			}
+		}
+	}
+	if l2 < 0 {
+		for _, c := range dp.t2.children[d2] {
+			if dp.value(d1, c) == v {
+				dp.traceback(d1, c, leaves)
+				return
+			}
+		}
+	}
+	pairs, _ := dp.matching(d1, d2)
+	for _, p := range pairs {
+		dp.traceback(p[0], p[1], leaves)
+	}
+}
+
+func max32(a, b int32) int32 {
+	if a > b {
+		return a
+	}
+	return b
+}
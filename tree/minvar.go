@@ -0,0 +1,81 @@
+package tree
+
+import (
+	"errors"
+	"math"
+)
+
+// Reroots the tree using the Minimum Variance rooting method (MinVar, Mai et
+// al., 2017): the root is placed at the position, over all the positions of all
+// the branches, minimizing the variance of root-to-tip distances.
+//
+// For each branch, root-to-tip distances are linear functions of the position
+// of the root on the branch, and the best position is computed analytically.
+//
+// It returns the variance of root-to-tip distances of the rerooted tree.
+//
+// All branch lengths must be defined.
+func (t *Tree) RerootMinVar() (variance float64, err error) {
+	var bestparent, bestchild *Node
+	var bestpos float64
+
+	tips := t.Tips()
+	if len(tips) < 3 {
+		err = errors.New("At least 3 tips are required for MinVar rooting")
+		return
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == NIL_LENGTH {
+			err = errors.New("All branch lengths must be defined")
+			return
+		}
+	}
+
+	t.UnRoot()
+	nodes := t.Nodes()
+	for i, node := range nodes {
+		node.SetId(i)
+	}
+	// Sums of the distances to the tips of the subtree of each node, and to
+	// the other tips, measured from its parent (dates are not used)
+	down := make([]rttSums, len(nodes))
+	up := make([]rttSums, len(nodes))
+	rttDown(t.Root(), nil, nil, down)
+	rttUp(t.Root(), nil, nil, down, up)
+
+	n := float64(len(tips))
+	variance = math.Inf(1)
+	t.PreOrder(func(cur, prev *Node, e *Edge) bool {
+		if prev == nil {
+			return true
+		}
+		// Position x of the root on the edge, from the parent node: tips under
+		// cur are at distance a-x, and the others at distance a+x
+		l := e.Length()
+		right := down[cur.Id()].shift(l)
+		left := up[cur.Id()]
+		a := right.d + left.d
+		b := left.n - right.n
+		aa := right.dd + left.dd
+		ab := left.d - right.d
+
+		// n.Var(x) = c0 + c1.x + c2.x²
+		c0, c1, c2 := aa-a*a/n, 2*(ab-a*b/n), n-b*b/n
+		x := 0.0
+		if c2 > 0 {
+			x = math.Min(math.Max(-c1/(2*c2), 0), l)
+		} else if c1 < 0 {
+			x = l
+		}
+		if v := (c0 + c1*x + c2*x*x) / n; v < variance {
+			variance = v
+			bestparent, bestchild = prev, cur
+			bestpos = x
+		}
+		return true
+	})
+
+	variance = math.Max(variance, 0)
+	err = t.rootOnEdge(bestparent, bestchild, bestpos)
+	return
+}
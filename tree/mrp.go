@@ -0,0 +1,103 @@
+package tree
+
+import (
+	"errors"
+	"sort"
+)
+
+// Builds the Matrix Representation with Parsimony (MRP, Baum 1992; Ragan 1992) of
+// the trees given in the input channel. Trees may have different (partially
+// overlapping) sets of tips.
+//
+// Each distinct internal bipartition of each tree is coded as a binary character:
+// for each taxon of the union of all the tips, the character is:
+//   - '1' if the taxon is on the right side of the branch defining the bipartition
+//     (i.e. in the clade if the tree is rooted);
+//   - '0' if the taxon is on the left side of the branch;
+//   - '?' if the taxon is not present in the tree.
+//
+// It returns the sorted names of the taxa, and the sequence of characters of
+// each taxon (in the same order). Characters are given tree by tree, in the order
+// of the input channel.
+//
+// Returns an error if no tree has an internal bipartition (no character).
+func MRPMatrix(trees <-chan Trees) (taxa []string, matrix []string, err error) {
+	var splits []*Edge
+	var columns [][]*Edge = make([][]*Edge, 0)
+	var input []*Tree = make([]*Tree, 0)
+	var taxaindex map[string]int = make(map[string]int)
+
+	for t := range trees {
+		if t.Err != nil {
+			for range trees {
+			}
+			return nil, nil, t.Err
+		}
+		if err = t.Tree.ReinitIndexes(); err != nil {
+			for range trees {
+			}
+			return
+		}
+		if splits, _, err = uniqueSplits(t.Tree.Edges(), false, false); err != nil {
+			for range trees {
+			}
+			return
+		}
+		for _, name := range t.Tree.AllTipNames() {
+			taxaindex[name] = 0
+		}
+		input = append(input, t.Tree)
+		columns = append(columns, splits)
+	}
+	if len(input) == 0 {
+		err = errors.New("No tree in the input")
+		return
+	}
+
+	taxa = make([]string, 0, len(taxaindex))
+	for name := range taxaindex {
+		taxa = append(taxa, name)
+	}
+	sort.Strings(taxa)
+	for i, name := range taxa {
+		taxaindex[name] = i
+	}
+
+	nchar := 0
+	for _, splits := range columns {
+		nchar += len(splits)
+	}
+	if nchar == 0 {
+		err = errors.New("No internal bipartition in the input trees: the MRP matrix would be empty")
+		return
+	}
+	chars := make([][]byte, len(taxa))
+	for i := range chars {
+		chars[i] = make([]byte, nchar)
+		for j := range chars[i] {
+			chars[i][j] = '?'
+		}
+	}
+
+	col := 0
+	for i, t := range input {
+		tips := t.Tips()
+		for _, e := range columns[i] {
+			b := e.Bitset()
+			for _, tip := range tips {
+				c := byte('0')
+				if b.Test(uint(tip.TipIndex())) {
+					c = '1'
+				}
+				chars[taxaindex[tip.Name()]][col] = c
+			}
+			col++
+		}
+	}
+
+	matrix = make([]string, len(taxa))
+	for i, c := range chars {
+		matrix[i] = string(c)
+	}
+	return
+}
@@ -0,0 +1,138 @@
+package tree
+
+import (
+	"sort"
+
+	"github.com/evolbioinfo/gotree/hashmap"
+	"github.com/fredericlemoine/bitset"
+)
+
+// Unrooted topology of a tree, defined by the set
+// of its internal bipartitions.
+//
+// It implements hashmap.Hasher, and may be used as a key in a HashMap:
+// the hashcode is the sum of the hashcodes of the internal edges
+// (see ComputeEdgeHashes), and two topologies are equal if they
+// have the same bipartitions.
+type topology struct {
+	hashcode uint64
+	splits   []*bitset.BitSet
+}
+
+// Builds the topology of the tree.
+//
+// Bitsets and edge hashes of the tree must be up to date
+// (see ReinitInternalIndexes).
+func newTopology(t *Tree) *topology {
+	var hashes = make(map[*bitset.BitSet]uint64)
+	var splits []*bitset.BitSet
+	var tp *topology
+
+	for _, e := range t.Edges() {
+		if e.Left().Tip() || e.Right().Tip() {
+			continue
+		}
+		// Bipartitions are normalized so that
+		// the first tip is never in the set
+		b := e.Bitset().Clone()
+		if b.Test(0) {
+			b = b.Complement()
+		}
+		hashes[b] = e.HashCode()
+		splits = append(splits, b)
+	}
+	sort.Slice(splits, func(i, j int) bool {
+		return compareBitsets(splits[i], splits[j]) < 0
+	})
+
+	tp = &topology{splits: make([]*bitset.BitSet, 0, len(splits))}
+	for i, b := range splits {
+		// The two edges around a root of degree 2
+		// define the same bipartition
+		if i > 0 && b.Equal(splits[i-1]) {
+			continue
+		}
+		tp.splits = append(tp.splits, b)
+		tp.hashcode += hashes[b]
+	}
+	return tp
+}
+
+func (tp *topology) HashCode() uint64 {
+	return tp.hashcode
+}
+
+func (tp *topology) HashEquals(h hashmap.Hasher) bool {
+	tp2 := h.(*topology)
+	if len(tp.splits) != len(tp2.splits) {
+		return false
+	}
+	for i, b := range tp.splits {
+		if !b.Equal(tp2.splits[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// Lexicographic comparison of the words of two bitsets
+func compareBitsets(b1, b2 *bitset.BitSet) int {
+	w1, w2 := b1.Bytes(), b2.Bytes()
+	for i := 0; i < len(w1) && i < len(w2); i++ {
+		if w1[i] < w2[i] {
+			return -1
+		} else if w1[i] > w2[i] {
+			return 1
+		}
+	}
+	return len(w1) - len(w2)
+}
+
+// Enumerates all distinct unrooted topologies that are one rearrangement
+// (given by the Rearranger, e.g NNI, SPR or TBR) away from the tree.
+//
+// For each distinct topology (the initial one excluded), f is called
+// with the rearranged tree. The tree is restored after each call. If f
+// returns false, the enumeration stops.
+//
+// Topologies are deduplicated using edge hashes (see ComputeEdgeHashes)
+// and bipartitions. At the end, the tree indexes are reinitialized.
+func (t *Tree) Neighbors(r Rearranger, f func(t *Tree) bool) (err error) {
+	var index *hashmap.HashMap
+
+	if err = t.ReinitIndexes(); err != nil {
+		return
+	}
+	index = hashmap.NewHashMap(1024, .75)
+	index.PutValue(newTopology(t), true)
+
+	r.Rearrange(t, func(re Rearrangement) bool {
+		if err = re.Apply(); err != nil {
+			return false
+		}
+		t.ReinitInternalIndexes()
+		tp := newTopology(t)
+		cont := true
+		if _, ok := index.Value(tp); !ok {
+			index.PutValue(tp, true)
+			cont = f(t)
+		}
+		if err = re.Undo(); err != nil {
+			return false
+		}
+		return cont
+	})
+	t.ReinitInternalIndexes()
+	return
+}
+
+// Returns the number of distinct unrooted topologies that are one
+// rearrangement (given by the Rearranger, e.g NNI, SPR or TBR) away
+// from the tree (the initial topology excluded).
+func (t *Tree) CountNeighbors(r Rearranger) (nb int, err error) {
+	err = t.Neighbors(r, func(t *Tree) bool {
+		nb++
+		return true
+	})
+	return
+}
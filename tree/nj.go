@@ -0,0 +1,205 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	NJ_ALGO_NJ = iota
+	NJ_ALGO_BIONJ
+)
+
+// Builds an unrooted tree from a distance matrix using the
+// Neighbor-Joining algorithm (Saitou & Nei, 1987).
+//
+//	* names: names of the taxa, in the same order as the rows of the matrix
+//	* dist: square distance matrix
+//
+// The output tree is unrooted (root node has 3 neighbors, except if there are
+// less than 3 taxa). Negative branch lengths are set to 0.
+func NeighborJoining(names []string, dist [][]float64) (*Tree, error) {
+	return distanceTree(names, dist, NJ_ALGO_NJ)
+}
+
+// Builds an unrooted tree from a distance matrix using the
+// BIONJ algorithm (Gascuel, 1997).
+//
+// BIONJ is a variant of Neighbor-Joining that takes into account
+// the variances of the distance estimates when computing the
+// reduced distances after each agglomeration.
+//
+//	* names: names of the taxa, in the same order as the rows of the matrix
+//	* dist: square distance matrix
+//
+// The output tree is unrooted (root node has 3 neighbors, except if there are
+// less than 3 taxa). Negative branch lengths are set to 0.
+func BioNJ(names []string, dist [][]float64) (*Tree, error) {
+	return distanceTree(names, dist, NJ_ALGO_BIONJ)
+}
+
+// Builds a tree using the given algorithm: NJ_ALGO_NJ or NJ_ALGO_BIONJ
+func distanceTree(names []string, dist [][]float64, algo int) (t *Tree, err error) {
+	var d, v [][]float64
+	var nodes []*Node
+	var sums []float64
+	var r, i, j, k, mini, minj int
+	var q, minq, li, lj, lambda, vsum float64
+
+	if err = checkDistanceMatrix(names, dist); err != nil {
+		return
+	}
+	if algo != NJ_ALGO_NJ && algo != NJ_ALGO_BIONJ {
+		err = fmt.Errorf("unknown distance algorithm: %d", algo)
+		return
+	}
+
+	t = NewTree()
+	r = len(names)
+	nodes = make([]*Node, r)
+	for i = 0; i < r; i++ {
+		nodes[i] = t.NewNode()
+		nodes[i].SetName(names[i])
+	}
+
+	if r == 2 {
+		e := t.ConnectNodes(nodes[0], nodes[1])
+		e.SetLength(math.Max(0, dist[0][1]))
+		t.SetRoot(nodes[0])
+		err = t.ReinitIndexes()
+		return
+	}
+
+	// Working copies of the distances (and variances for BIONJ)
+	d = copyMatrix(dist)
+	if algo == NJ_ALGO_BIONJ {
+		v = copyMatrix(dist)
+	}
+	sums = make([]float64, r)
+
+	for r > 3 {
+		for i = 0; i < r; i++ {
+			sums[i] = 0
+			for k = 0; k < r; k++ {
+				sums[i] += d[i][k]
+			}
+		}
+		// We search the pair minimizing the Q criterion
+		mini, minj = 0, 1
+		minq = math.Inf(1)
+		for i = 0; i < r; i++ {
+			for j = i + 1; j < r; j++ {
+				q = float64(r-2)*d[i][j] - sums[i] - sums[j]
+				if q < minq {
+					minq = q
+					mini, minj = i, j
+				}
+			}
+		}
+
+		li = 0.5*d[mini][minj] + (sums[mini]-sums[minj])/(2.0*float64(r-2))
+		lj = d[mini][minj] - li
+
+		u := t.NewNode()
+		ei := t.ConnectNodes(u, nodes[mini])
+		ej := t.ConnectNodes(u, nodes[minj])
+		ei.SetLength(math.Max(0, li))
+		ej.SetLength(math.Max(0, lj))
+
+		// New distances are stored at index mini, and
+		// index minj is replaced by the last cluster
+		switch algo {
+		case NJ_ALGO_NJ:
+			for k = 0; k < r; k++ {
+				if k != mini && k != minj {
+					d[mini][k] = 0.5 * (d[mini][k] + d[minj][k] - d[mini][minj])
+					d[k][mini] = d[mini][k]
+				}
+			}
+		case NJ_ALGO_BIONJ:
+			lambda = 0.5
+			if v[mini][minj] > 0 {
+				vsum = 0
+				for k = 0; k < r; k++ {
+					if k != mini && k != minj {
+						vsum += v[minj][k] - v[mini][k]
+					}
+				}
+				lambda = 0.5 + vsum/(2.0*float64(r-2)*v[mini][minj])
+				lambda = math.Min(1.0, math.Max(0.0, lambda))
+			}
+			for k = 0; k < r; k++ {
+				if k != mini && k != minj {
+					d[mini][k] = lambda*(d[mini][k]-li) + (1-lambda)*(d[minj][k]-lj)
+					d[k][mini] = d[mini][k]
+					v[mini][k] = lambda*v[mini][k] + (1-lambda)*v[minj][k] - lambda*(1-lambda)*v[mini][minj]
+					v[k][mini] = v[mini][k]
+				}
+			}
+		}
+		d[mini][mini] = 0
+		nodes[mini] = u
+
+		// We move the last cluster at index minj
+		last := r - 1
+		if minj != last {
+			for k = 0; k < r; k++ {
+				d[minj][k] = d[last][k]
+				d[k][minj] = d[k][last]
+				if v != nil {
+					v[minj][k] = v[last][k]
+					v[k][minj] = v[k][last]
+				}
+			}
+			d[minj][minj] = 0
+			nodes[minj] = nodes[last]
+		}
+		r--
+	}
+
+	// Three remaining clusters: they are connected to the root
+	root := t.NewNode()
+	l0 := 0.5 * (d[0][1] + d[0][2] - d[1][2])
+	l1 := 0.5 * (d[0][1] + d[1][2] - d[0][2])
+	l2 := 0.5 * (d[0][2] + d[1][2] - d[0][1])
+	t.ConnectNodes(root, nodes[0]).SetLength(math.Max(0, l0))
+	t.ConnectNodes(root, nodes[1]).SetLength(math.Max(0, l1))
+	t.ConnectNodes(root, nodes[2]).SetLength(math.Max(0, l2))
+	t.SetRoot(root)
+
+	err = t.ReinitIndexes()
+	return
+}
+
+// Checks that the distance matrix is square, has the same
+// dimension as the name slice, has at least 2 taxa, and
+// does not contain NaN values.
+func checkDistanceMatrix(names []string, dist [][]float64) error {
+	if len(names) < 2 {
+		return errors.New("Cannot build a tree with less than 2 taxa")
+	}
+	if len(dist) != len(names) {
+		return fmt.Errorf("Distance matrix has %d rows but %d names are given", len(dist), len(names))
+	}
+	for i, row := range dist {
+		if len(row) != len(names) {
+			return fmt.Errorf("Distance matrix is not square: row %d has %d columns", i, len(row))
+		}
+		for j, val := range row {
+			if math.IsNaN(val) {
+				return fmt.Errorf("Distance between %s and %s is NaN", names[i], names[j])
+			}
+		}
+	}
+	return nil
+}
+
+func copyMatrix(m [][]float64) [][]float64 {
+	c := make([][]float64, len(m))
+	for i, row := range m {
+		c[i] = make([]float64, len(row))
+		copy(c[i], row)
+	}
+	return c
+}
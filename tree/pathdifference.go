@@ -0,0 +1,162 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Computes the path difference distance (Steel & Penny, 1993) between the two
+// trees, over their common set of tips: the euclidean distance between the
+// vectors of the distances between all pairs of common tips in the two trees.
+//
+// If weighted is false, the distance between two tips is the number of
+// branches separating them (topological path difference), and otherwise
+// the sum of the lengths of these branches (cophenetic/patristic distance),
+// in which case all branch lengths must be defined.
+//
+// Before computation, tips that are not common to both trees are removed
+// (on copies of the trees), and trees are unrooted.
+//
+// Returns the distance and the number of common tips. An error is returned if
+// the trees share less than 3 tips.
+func PathDifference(t1, t2 *Tree, weighted bool) (dist float64, ncommon int, err error) {
+	var c1, c2 *Tree
+	var m1, m2 [][]float64
+	var common []string
+
+	if common, err = commonTipNames(t1, t2); err != nil {
+		return
+	}
+	ncommon = len(common)
+	if ncommon < 3 {
+		err = fmt.Errorf("Trees share only %d tips, at least 3 are required", ncommon)
+		return
+	}
+	if c1, err = pathDifferenceTree(t1, common, weighted); err != nil {
+		return
+	}
+	if c2, err = pathDifferenceTree(t2, common, weighted); err != nil {
+		return
+	}
+
+	if weighted {
+		m1, m2 = c1.ToDistanceMatrix(), c2.ToDistanceMatrix()
+	} else {
+		m1, m2 = c1.ToTopoDistanceMatrix(), c2.ToTopoDistanceMatrix()
+	}
+
+	// Index of tips of the second tree in the matrix of the first tree
+	tips1, tips2 := c1.Tips(), c2.Tips()
+	index := make(map[string]int, len(tips1))
+	for i, tip := range tips1 {
+		index[tip.Name()] = i
+	}
+	perm := make([]int, len(tips2))
+	for j, tip := range tips2 {
+		perm[j] = index[tip.Name()]
+	}
+
+	for i := range m2 {
+		for j := i + 1; j < len(m2); j++ {
+			d := m2[i][j] - m1[perm[i]][perm[j]]
+			dist += d * d
+		}
+	}
+	dist = math.Sqrt(dist)
+	return
+}
+
+// Computes the path difference distance (see PathDifference) between
+// all pairs of given trees
+func PathDifferenceMatrix(trees []*Tree, weighted bool) (dists [][]float64, err error) {
+	dists = make([][]float64, len(trees))
+	for i := range trees {
+		dists[i] = make([]float64, len(trees))
+	}
+	for i := range trees {
+		for j := i + 1; j < len(trees); j++ {
+			if dists[i][j], _, err = PathDifference(trees[i], trees[j], weighted); err != nil {
+				err = fmt.Errorf("Trees %d and %d: %v", i, j, err)
+				return
+			}
+			dists[j][i] = dists[i][j]
+		}
+	}
+	return
+}
+
+// Distance matrix between all tips of the tree, in terms of number
+// of branches separating them. Tip ids are set to their index in the
+// matrix, which is the same as in t.Tips()
+func (t *Tree) ToTopoDistanceMatrix() [][]float64 {
+	tips := t.Tips()
+	var matrix [][]float64 = make([][]float64, len(tips))
+	for i := range tips {
+		matrix[i] = make([]float64, len(tips))
+		tips[i].SetId(i)
+	}
+
+	for i, t := range tips {
+		pathTopoLengths(t, nil, matrix[i], 0)
+	}
+	return matrix
+}
+
+func pathTopoLengths(cur *Node, prev *Node, lengths []float64, curlength float64) {
+	if cur.Tip() && prev != nil {
+		lengths[cur.Id()] = curlength
+	} else {
+		for _, child := range cur.neigh {
+			if child != prev {
+				pathTopoLengths(child, cur, lengths, curlength+1)
+			}
+		}
+	}
+}
+
+// Returns the names of the tips shared by the two trees. Returns an error
+// if a tree has several tips with the same name
+func commonTipNames(t1, t2 *Tree) (common []string, err error) {
+	names1 := make(map[string]bool)
+	for _, n := range t1.AllTipNames() {
+		if names1[n] {
+			err = fmt.Errorf("Tip %s is present several times in the first tree", n)
+			return
+		}
+		names1[n] = true
+	}
+	names2 := make(map[string]bool)
+	common = make([]string, 0, len(names1))
+	for _, n := range t2.AllTipNames() {
+		if names2[n] {
+			err = fmt.Errorf("Tip %s is present several times in the second tree", n)
+			return
+		}
+		names2[n] = true
+		if names1[n] {
+			common = append(common, n)
+		}
+	}
+	return
+}
+
+// Copies the tree, keeping only the given tips, and unroots it
+func pathDifferenceTree(t *Tree, tips []string, weighted bool) (c *Tree, err error) {
+	if weighted {
+		for _, e := range t.Edges() {
+			if e.Length() == NIL_LENGTH {
+				err = errors.New("All branch lengths must be defined")
+				return
+			}
+		}
+	}
+	c = t.Clone()
+	if len(tips) < len(c.Tips()) {
+		if err = c.RemoveTips(true, tips...); err != nil {
+			return
+		}
+	}
+	c.UnRoot()
+	return
+}
@@ -0,0 +1,396 @@
+package tree
+
+// Statistics of the comparison of the quartets of two trees
+// having the same set of tips
+type QuartetStats struct {
+	Shared         int64 // Number of quartets resolved the same way in both trees
+	Diff           int64 // Number of quartets resolved differently in both trees
+	Unresolved1    int64 // Number of quartets unresolved in the first tree, resolved in the second tree
+	Unresolved2    int64 // Number of quartets resolved in the first tree, unresolved in the second tree
+	UnresolvedBoth int64 // Number of quartets unresolved in both trees
+	Total          int64 // Total number of quartets: (n choose 4)
+}
+
+// Component of the tree defined by an internal node: set of
+// tips located at the lower (complement=false) or upper
+// (complement=true) side of an edge
+type quartetComponent struct {
+	edge       int
+	complement bool
+}
+
+// Structure used to compute quartet (and triplet) statistics: each edge
+// is indexed in a pre-order traversal from the root, and
+// each internal node having at least 3 neighbors is described
+// by the components of the tree it defines
+type quartetTree struct {
+	ntips      int
+	edges      []*Edge              // Edges in pre-order
+	lower      []*Node              // Lower node of each edge
+	sizes      []int64              // Number of tips below each edge
+	parentEdge []int                // Index of the parent edge of each node (by node id), -1 for the root
+	parentNode []*Node              // Parent node of each node (by node id)
+	ends       []int                // Index following the last edge of the subtree of each edge
+	nodes      [][]quartetComponent // Components of each internal node having at least 3 neighbors
+}
+
+// Buffers reused for all the pairs of nodes of the two trees
+type quartetBuffers struct {
+	m    [][]int64 // Number of tips shared by each pair of components of the two nodes
+	g    [][]int64 // Products of the rows of m
+	rows []int64   // Sums of the rows of m
+	cols []int64   // Sums of the columns of m
+}
+
+// Compares the quartets of the two given trees, which must have the same
+// set of tips. A quartet of tips {a,b,c,d} is resolved in a tree (e.g. ab|cd)
+// if an edge separates a and b from c and d, and unresolved otherwise
+// (multifurcation).
+//
+// Quartets are not enumerated: for each pair of internal nodes of the two trees,
+// quartets are counted using the number of tips shared by the components of the
+// trees defined by the two nodes. The complexity is O(n^2.d) in time, with d the
+// maximum degree of the trees, and O(n.d) in memory. The time remains quadratic
+// in the number of tips: the sub-quadratic algorithms of Brodal et al. (tqDist)
+// are not implemented.
+func QuartetDistance(t1, t2 *Tree) (stats QuartetStats, err error) {
+	var q1, q2 *quartetTree
+	var tips []int
+	var n int64
+
+	if err = t1.UpdateTipIndex(); err != nil {
+		return
+	}
+	if err = t2.UpdateTipIndex(); err != nil {
+		return
+	}
+	if err = t1.CompareTipIndexes(t2); err != nil {
+		return
+	}
+
+	q1 = newQuartetTree(t1)
+	q2 = newQuartetTree(t2)
+	n = int64(q1.ntips)
+	tips = quartetTipEdges(q1, q2)
+
+	var s, d int64
+	b := &quartetBuffers{}
+	rows := make([][]int32, 0)
+	edges := make([]int, 0)
+	for _, v := range q1.nodes {
+		edges = edges[:0]
+		for _, c := range v {
+			edges = append(edges, c.edge)
+		}
+		rows = quartetIntersections(q1, q2, tips, edges, rows)
+		for _, w := range q2.nodes {
+			quartetMatrix(b, q1, q2, v, w, rows)
+			s += sharedQuartetTuples(b, n)
+			d += diffQuartetTuples(b)
+		}
+	}
+
+	stats.Total = n * (n - 1) * (n - 2) * (n - 3) / 24
+	stats.Shared = s / 8
+	stats.Diff = d / 4
+	r1 := q1.resolvedQuartets()
+	r2 := q2.resolvedQuartets()
+	stats.Unresolved1 = r2 - stats.Shared - stats.Diff
+	stats.Unresolved2 = r1 - stats.Shared - stats.Diff
+	stats.UnresolvedBoth = stats.Total - r1 - r2 + stats.Shared + stats.Diff
+	return
+}
+
+// Initializes the structure used to compute quartet statistics
+func newQuartetTree(t *Tree) (q *quartetTree) {
+	nodes := t.Nodes()
+	q = &quartetTree{
+		edges:      make([]*Edge, 0, len(nodes)),
+		lower:      make([]*Node, 0, len(nodes)),
+		parentEdge: make([]int, len(nodes)),
+		parentNode: make([]*Node, len(nodes)),
+		nodes:      make([][]quartetComponent, 0),
+	}
+	for i, n := range nodes {
+		n.SetId(i)
+		q.parentEdge[i] = -1
+	}
+
+	t.PreOrder(func(cur, prev *Node, e *Edge) bool {
+		if e != nil {
+			q.parentEdge[cur.Id()] = len(q.edges)
+			q.parentNode[cur.Id()] = prev
+			q.edges = append(q.edges, e)
+			q.lower = append(q.lower, cur)
+		}
+		if cur.Tip() {
+			q.ntips++
+		}
+		return true
+	})
+
+	// Number of tips below each edge, in reverse pre-order. In pre-order,
+	// the edges of the subtree of each edge are contiguous
+	q.sizes = make([]int64, len(q.edges))
+	q.ends = make([]int, len(q.edges))
+	for i := len(q.edges) - 1; i >= 0; i-- {
+		low := q.lower[i]
+		if low.Tip() {
+			q.sizes[i] = 1
+		}
+		if q.ends[i] < i+1 {
+			q.ends[i] = i + 1
+		}
+		if p := q.parentNode[low.Id()]; p != nil && q.parentEdge[p.Id()] >= 0 {
+			pe := q.parentEdge[p.Id()]
+			q.sizes[pe] += q.sizes[i]
+			if q.ends[pe] < q.ends[i] {
+				q.ends[pe] = q.ends[i]
+			}
+		}
+	}
+
+	// Components of internal nodes
+	for _, n := range nodes {
+		if n.Nneigh() < 3 {
+			continue
+		}
+		comps := make([]quartetComponent, 0, n.Nneigh())
+		for _, next := range n.Neigh() {
+			if q.parentNode[n.Id()] == next {
+				comps = append(comps, quartetComponent{q.parentEdge[n.Id()], true})
+			} else {
+				comps = append(comps, quartetComponent{q.parentEdge[next.Id()], false})
+			}
+		}
+		q.nodes = append(q.nodes, comps)
+	}
+	return
+}
+
+// Number of tips in the given component
+func (q *quartetTree) size(c quartetComponent) int64 {
+	if c.complement {
+		return int64(q.ntips) - q.sizes[c.edge]
+	}
+	return q.sizes[c.edge]
+}
+
+// Number of quartets that are resolved in the tree:
+// each resolved quartet ab|cd is counted twice: once at
+// the node separating a from b (c and d being in the same
+// component), and once at the node separating c from d
+func (q *quartetTree) resolvedQuartets() int64 {
+	var r int64
+	n := int64(q.ntips)
+	for _, comps := range q.nodes {
+		var pairs int64
+		for _, c := range comps {
+			s := q.size(c)
+			pairs += s * (s - 1) / 2
+		}
+		for _, c := range comps {
+			s := q.size(c)
+			out := n - s
+			r += s * (s - 1) / 2 * (out*(out-1)/2 - (pairs - s*(s-1)/2))
+		}
+	}
+	return r / 2
+}
+
+// Returns, for each edge of the first tree leading to a tip, the index of
+// the edge of the second tree leading to the same tip, and -1 for the other
+// edges (tip names have been checked before)
+func quartetTipEdges(q1, q2 *quartetTree) (tips []int) {
+	edges2 := make(map[string]int)
+	for i, low := range q2.lower {
+		if low.Tip() {
+			edges2[low.Name()] = i
+		}
+	}
+	tips = make([]int, len(q1.edges))
+	for i, low := range q1.lower {
+		tips[i] = -1
+		if low.Tip() {
+			tips[i] = edges2[low.Name()]
+		}
+	}
+	return
+}
+
+// Computes the number of tips shared by the lower side of each of the given
+// edges of the first tree (one row per edge) and the lower sides of all the
+// edges of the second tree. Rows of the given slice are reused. Each row takes
+// O(n) time, and the full edges x edges matrix is never stored.
+func quartetIntersections(q1, q2 *quartetTree, tips []int, edges []int, rows [][]int32) [][]int32 {
+	for len(rows) < len(edges) {
+		rows = append(rows, make([]int32, len(q2.edges)))
+	}
+	rows = rows[:len(edges)]
+	for k, e1 := range edges {
+		row := rows[k]
+		for j := range row {
+			row[j] = 0
+		}
+		for i := e1; i < q1.ends[e1]; i++ {
+			if tips[i] >= 0 {
+				row[tips[i]] = 1
+			}
+		}
+		// In reverse pre-order: children before parents
+		for j := len(q2.edges) - 1; j >= 0; j-- {
+			if p := q2.parentNode[q2.lower[j].Id()]; q2.parentEdge[p.Id()] >= 0 {
+				row[q2.parentEdge[p.Id()]] += row[j]
+			}
+		}
+	}
+	return rows
+}
+
+// Fills the matrix of the number of tips shared by each pair of components
+// of the two given nodes (v from the first tree, w from the second tree).
+// rows are the intersections of the edges of the components of v
+// (see quartetIntersections)
+func quartetMatrix(b *quartetBuffers, q1, q2 *quartetTree, v, w []quartetComponent, rows [][]int32) {
+	n := int64(q1.ntips)
+	b.m = quartetZeros(b.m, len(v), len(w))
+	for k, c1 := range v {
+		row := b.m[k]
+		s1 := q1.size(quartetComponent{c1.edge, false})
+		for j, c2 := range w {
+			s2 := q2.size(quartetComponent{c2.edge, false})
+			i := int64(rows[k][c2.edge])
+			switch {
+			case !c1.complement && !c2.complement:
+				row[j] = i
+			case !c1.complement && c2.complement:
+				row[j] = s1 - i
+			case c1.complement && !c2.complement:
+				row[j] = s2 - i
+			default:
+				row[j] = n - s1 - s2 + i
+			}
+		}
+	}
+}
+
+// Returns a r x c matrix filled with zeros, reusing the given matrix if possible
+func quartetZeros(m [][]int64, r, c int) [][]int64 {
+	for len(m) < r {
+		m = append(m, nil)
+	}
+	m = m[:r]
+	for i := range m {
+		m[i] = quartetZeroVector(m[i], c)
+	}
+	return m
+}
+
+// Returns a vector of length l filled with zeros, reusing the given vector if possible
+func quartetZeroVector(v []int64, l int) []int64 {
+	if cap(v) < l {
+		return make([]int64, l)
+	}
+	v = v[:l]
+	for i := range v {
+		v[i] = 0
+	}
+	return v
+}
+
+// Counts the ordered tuples of tips (x,y,z,t) such that:
+// - x,y and {z,t} are in 3 different components of the first node,
+// - x,y and {z,t} are in 3 different components of the second node.
+// Each shared quartet is counted 8 times in total over all pairs of nodes
+func sharedQuartetTuples(b *quartetBuffers, n int64) (count int64) {
+	m := b.m
+	b.rows = quartetZeroVector(b.rows, len(m))
+	b.cols = quartetZeroVector(b.cols, len(m[0]))
+	rows, cols := b.rows, b.cols
+	var sq int64
+	for i, row := range m {
+		for j, v := range row {
+			rows[i] += v
+			cols[j] += v
+			sq += v * v
+		}
+	}
+	for l, rowl := range m {
+		for k, mlk := range rowl {
+			if mlk < 2 {
+				continue
+			}
+			// Tips outside component l of the first node and
+			// outside component k of the second node
+			out := n - rows[l] - cols[k] + mlk
+			samerow, samecol, samecell := int64(0), int64(0), sq
+			for i, row := range m {
+				samecell -= row[k] * row[k]
+				if i != l {
+					r := rows[i] - row[k]
+					samerow += r * r
+				}
+			}
+			for j, v := range rowl {
+				samecell -= v * v
+				if j != k {
+					c := cols[j] - v
+					samecol += c * c
+				}
+			}
+			samecell += mlk * mlk
+			count += mlk * (mlk - 1) * (out*out - samerow - samecol + samecell)
+		}
+	}
+	return
+}
+
+// Counts the ordered tuples of tips (x,y,z,t) such that:
+// - x,y and {z,t} are in 3 different components of the first node,
+// - x,z and {y,t} are in 3 different components of the second node.
+// Each quartet resolved differently in the two trees is counted 4
+// times in total over all pairs of nodes
+func diffQuartetTuples(b *quartetBuffers) (count int64) {
+	m := b.m
+	b.rows = quartetZeroVector(b.rows, len(m))
+	b.g = quartetZeros(b.g, len(m), len(m))
+	rows, g := b.rows, b.g
+	for i, row := range m {
+		for _, v := range row {
+			rows[i] += v
+		}
+	}
+	for i := range m {
+		for l := i; l < len(m); l++ {
+			for j := range m[i] {
+				g[i][l] += m[i][j] * m[l][j]
+			}
+			g[l][i] = g[i][l]
+		}
+	}
+	// Number of tuples with x in component i, and z in component l of the first node,
+	// and t in component k of the second node (x and z being in different components
+	// of the second node, both different from k)
+	tuples := func(i, l, k int) int64 {
+		return (rows[i]-m[i][k])*(rows[l]-m[l][k]) - g[i][l] + m[i][k]*m[l][k]
+	}
+	for l, rowl := range m {
+		for k, mlk := range rowl {
+			if mlk == 0 {
+				continue
+			}
+			var all int64
+			for i := range m {
+				if i != l {
+					all += tuples(i, l, k)
+				}
+			}
+			for j := range m {
+				if j != l && m[j][k] > 0 {
+					count += mlk * m[j][k] * (all - tuples(j, l, k))
+				}
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,446 @@
+package tree
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/fredericlemoine/bitset"
+)
+
+// Structure used to search for the species tree maximizing the quartet
+// score: clusters of the search space, and best resolution of each of them
+type quartetSpeciesSearch struct {
+	ntaxa    uint
+	cpus     int
+	taxa     []string
+	parts    []*bitset.BitSet // Tip sets around the internal nodes of all the gene trees
+	sizes    []float64        // Size of each tip set
+	nodes    []int            // Index of the first tip set of each gene tree internal node, and len(parts)
+	clusters []*bitset.BitSet // Clusters of the search space
+	index    map[string]int   // Index of each cluster in clusters
+	members  []*bitset.BitSet // Indices of the clusters containing each taxon
+	score    []float64        // Best score of each cluster, -1 if not computed yet
+	best     [][2]int         // Best resolution of each cluster
+}
+
+// Estimates the species tree maximizing the quartet agreement with the (unrooted)
+// gene trees given in the input channel, as in ASTRAL (Mirarab et al., 2014):
+// the species tree maximizes the number of gene tree quartets it displays, the
+// search being constrained to the species trees whose bipartitions are all
+// present in the gene trees. Gene trees may have missing taxa and multifurcations.
+//
+// The search is a dynamic programming over the clusters of the search space
+// (bipartitions of the gene trees), each cluster being resolved into two clusters
+// of the search space so that the quartet score of the tripartitions it defines is
+// maximal. Clusters that can not be resolved that way are resolved by removing one
+// taxon at a time.
+//
+// Support of each internal branch of the returned unrooted species tree is its
+// local quartet support: among gene tree quartets made of one taxon of each of the
+// four subtrees around the branch, the proportion that agree with the species tree
+// (no support if there is no such quartet).
+//
+// It also returns the normalized quartet score of the species tree: the
+// proportion of resolved gene tree quartets that it displays.
+//
+// The number of clusters of the search space grows linearly with the number of gene
+// trees and of taxa, and each resolution of each cluster is scored against all the
+// internal nodes of all the gene trees: running time grows roughly as the cube of
+// the size of the input, which is fine for a few hundred gene trees of a few hundred
+// taxa, but not for larger datasets.
+//
+// Quartet scores are computed using the given number of threads.
+func QuartetSpeciesTree(trees <-chan Trees, cpus int) (species *Tree, score float64, err error) {
+	var genetrees []*Tree = make([]*Tree, 0)
+	var taxaindex map[string]uint = make(map[string]uint)
+
+	for t := range trees {
+		if t.Err != nil {
+			for range trees {
+			}
+			return nil, 0, t.Err
+		}
+		if err = t.Tree.UpdateTipIndex(); err != nil {
+			for range trees {
+			}
+			return
+		}
+		for _, name := range t.Tree.AllTipNames() {
+			taxaindex[name] = 0
+		}
+		genetrees = append(genetrees, t.Tree)
+	}
+	if len(genetrees) == 0 {
+		err = errors.New("No tree in the input")
+		return
+	}
+	if len(taxaindex) < 4 {
+		err = fmt.Errorf("Gene trees have only %d taxa, at least 4 are required", len(taxaindex))
+		return
+	}
+
+	s := &quartetSpeciesSearch{
+		ntaxa:   uint(len(taxaindex)),
+		cpus:    cpus,
+		taxa:    make([]string, 0, len(taxaindex)),
+		index:   make(map[string]int),
+		members: make([]*bitset.BitSet, len(taxaindex)),
+	}
+	for name := range taxaindex {
+		s.taxa = append(s.taxa, name)
+	}
+	sort.Strings(s.taxa)
+	for i, name := range s.taxa {
+		taxaindex[name] = uint(i)
+	}
+
+	// Search space
+	all := bitset.New(s.ntaxa)
+	for i := uint(0); i < s.ntaxa; i++ {
+		s.members[i] = bitset.New(0)
+	}
+	for i := uint(0); i < s.ntaxa; i++ {
+		all.Set(i)
+		single := bitset.New(s.ntaxa)
+		single.Set(i)
+		s.addCluster(single)
+	}
+	for _, t := range genetrees {
+		sets := neighborTipSets(t, taxaindex, s.ntaxa)
+		for _, n := range t.Nodes() {
+			if n.Nneigh() >= 3 {
+				s.nodes = append(s.nodes, len(s.parts))
+				for _, c := range sets[n.Id()] {
+					s.parts = append(s.parts, c)
+					s.sizes = append(s.sizes, float64(c.Count()))
+				}
+			}
+			for _, c := range sets[n.Id()] {
+				s.addCluster(c)
+				s.addCluster(all.Difference(c))
+			}
+		}
+	}
+	s.nodes = append(s.nodes, len(s.parts))
+	root := s.addCluster(all)
+
+	// Dynamic programming
+	best := s.value(root)
+
+	species = NewTree()
+	rootnode := species.NewNode()
+	species.SetRoot(rootnode)
+	s.buildTree(species, rootnode, root)
+	species.UnRoot()
+	if err = species.ReinitIndexes(); err != nil {
+		return
+	}
+	s.setQuartetSupports(species, taxaindex)
+
+	if total := s.resolvedQuartets(); total > 0 {
+		score = best / total
+	}
+	return
+}
+
+// Adds the cluster to the search space if it is not already present,
+// and returns its index
+func (s *quartetSpeciesSearch) addCluster(c *bitset.BitSet) int {
+	key := clusterKey(c)
+	if i, ok := s.index[key]; ok {
+		return i
+	}
+	s.clusters = append(s.clusters, c)
+	s.score = append(s.score, -1)
+	s.best = append(s.best, [2]int{-1, -1})
+	s.index[key] = len(s.clusters) - 1
+	for i, ok := c.NextSet(0); ok; i, ok = c.NextSet(i + 1) {
+		s.members[i].Set(uint(len(s.clusters) - 1))
+	}
+	return len(s.clusters) - 1
+}
+
+// Key of the cluster in the index of the clusters
+func clusterKey(c *bitset.BitSet) string {
+	words := c.Bytes()
+	key := make([]byte, 8*len(words))
+	for i, w := range words {
+		binary.LittleEndian.PutUint64(key[8*i:], w)
+	}
+	return string(key)
+}
+
+// Indices of the clusters of the search space that are strictly included
+// in the given cluster, with at most half of its taxa: they are the clusters
+// that contain none of the taxa outside of the cluster
+func (s *quartetSpeciesSearch) subClusters(cluster *bitset.BitSet) (subs []int) {
+	size := cluster.Count()
+	outside := bitset.New(uint(len(s.clusters)))
+	for i := uint(0); i < s.ntaxa; i++ {
+		if !cluster.Test(i) {
+			outside.InPlaceUnion(s.members[i])
+		}
+	}
+	for c1, ok := outside.NextClear(0); ok && c1 < uint(len(s.clusters)); c1, ok = outside.NextClear(c1 + 1) {
+		if 2*s.clusters[c1].Count() <= size {
+			subs = append(subs, int(c1))
+		}
+	}
+	return
+}
+
+// Best quartet score of the given cluster
+func (s *quartetSpeciesSearch) value(c int) float64 {
+	if s.score[c] >= 0 {
+		return s.score[c]
+	}
+	cluster := s.clusters[c]
+	size := cluster.Count()
+	if size == 1 {
+		s.score[c] = 0
+		return 0
+	}
+
+	// Resolutions of the cluster into two clusters of the search space
+	candidates := make([][2]int, 0)
+	for _, c1 := range s.subClusters(cluster) {
+		b1 := s.clusters[c1]
+		c2, ok := s.index[clusterKey(cluster.Difference(b1))]
+		if !ok || (2*b1.Count() == size && c2 < c1) {
+			continue
+		}
+		s.value(c1)
+		s.value(c2)
+		if c2 < c1 {
+			c1, c2 = c2, c1
+		}
+		candidates = append(candidates, [2]int{c1, c2})
+	}
+	if len(candidates) == 0 {
+		// No resolution in the search space: the first taxon is
+		// separated from the others
+		first, _ := cluster.NextSet(0)
+		b1 := bitset.New(s.ntaxa)
+		b1.Set(first)
+		b2 := cluster.Clone()
+		b2.Clear(first)
+		c1, c2 := s.addCluster(b1), s.addCluster(b2)
+		s.value(c1)
+		s.value(c2)
+		candidates = append(candidates, [2]int{c1, c2})
+	}
+
+	// Number of taxa of the cluster and of the remaining
+	// taxa in each gene tree tip set
+	incluster := make([]float64, len(s.parts))
+	in3 := make([]float64, len(s.parts))
+	for i, p := range s.parts {
+		incluster[i] = float64(p.IntersectionCardinality(cluster))
+		in3[i] = s.sizes[i] - incluster[i]
+	}
+	scores := make([]float64, len(candidates))
+	index := make(chan int, len(candidates))
+	for i := range candidates {
+		index <- i
+	}
+	close(index)
+	var wg sync.WaitGroup
+	for cpu := 0; cpu < s.cpus && cpu < len(candidates); cpu++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			in1 := make([]float64, len(s.parts))
+			in2 := make([]float64, len(s.parts))
+			for i := range index {
+				b1 := s.clusters[candidates[i][0]]
+				for j, p := range s.parts {
+					in1[j] = float64(p.IntersectionCardinality(b1))
+					in2[j] = incluster[j] - in1[j]
+				}
+				scores[i] = s.tripartitionScore(in1, in2, in3)
+			}
+		}()
+	}
+	wg.Wait()
+
+	bestscore := -1.0
+	for i, cand := range candidates {
+		if v := s.score[cand[0]] + s.score[cand[1]] + scores[i]; v > bestscore {
+			bestscore = v
+			s.best[c] = cand
+		}
+	}
+	s.score[c] = bestscore
+	return bestscore
+}
+
+// Number of (gene tree quartet, species node) pairs such that the quartet is
+// resolved at an internal node of a gene tree as it is at a species tree node
+// defining a tripartition P1|P2|P3. Each gene quartet displayed by the species
+// tree is counted twice (at both ends of its internal path).
+//
+// The number of taxa of P1, P2 and P3 in each gene tree tip set are given in
+// in1, in2 and in3.
+func (s *quartetSpeciesSearch) tripartitionScore(in1, in2, in3 []float64) (score float64) {
+	counts := [3][]float64{in1, in2, in3}
+	for n := 0; n < len(s.nodes)-1; n++ {
+		start, end := s.nodes[n], s.nodes[n+1]
+		var tot [3]float64
+		var sums [3]float64 // Sum of the products of the counts of the two other parts
+		for a := start; a < end; a++ {
+			for i := 0; i < 3; i++ {
+				tot[i] += counts[i][a]
+				sums[i] += counts[(i+1)%3][a] * counts[(i+2)%3][a]
+			}
+		}
+		for i := 0; i < 3; i++ {
+			ci, cj, ck := counts[i], counts[(i+1)%3], counts[(i+2)%3]
+			tj, tk := tot[(i+1)%3], tot[(i+2)%3]
+			for a := start; a < end; a++ {
+				if ci[a] < 2 {
+					continue
+				}
+				// Two taxa of part i in the same gene subtree, and one
+				// taxon of the two other parts in two other different subtrees
+				score += ci[a] * (ci[a] - 1) / 2 * ((tj-cj[a])*(tk-ck[a]) - (sums[i] - cj[a]*ck[a]))
+			}
+		}
+	}
+	return
+}
+
+// Number of resolved gene tree quartets, each being counted twice
+func (s *quartetSpeciesSearch) resolvedQuartets() (total float64) {
+	for n := 0; n < len(s.nodes)-1; n++ {
+		var tot, sq float64
+		for _, size := range s.sizes[s.nodes[n]:s.nodes[n+1]] {
+			tot += size
+			sq += size * size
+		}
+		for _, size := range s.sizes[s.nodes[n]:s.nodes[n+1]] {
+			// Pairs of taxa in two other different subtrees
+			total += size * (size - 1) / 2 * ((tot-size)*(tot-size) - (sq - size*size)) / 2
+		}
+	}
+	return
+}
+
+// Adds to the node the subtree corresponding to the best resolution of the cluster
+func (s *quartetSpeciesSearch) buildTree(t *Tree, node *Node, c int) {
+	for _, child := range s.best[c] {
+		n := t.NewNode()
+		t.ConnectNodes(node, n)
+		if b := s.clusters[child]; b.Count() == 1 {
+			i, _ := b.NextSet(0)
+			n.SetName(s.taxa[i])
+		} else {
+			s.buildTree(t, n, child)
+		}
+	}
+}
+
+// Sets the local quartet support of each internal branch of the species tree
+func (s *quartetSpeciesSearch) setQuartetSupports(species *Tree, taxaindex map[string]uint) {
+	sets := neighborTipSets(species, taxaindex, s.ntaxa)
+	for _, e := range species.Edges() {
+		if e.Right().Tip() {
+			continue
+		}
+		// The four subtrees around the branch: a,b | c,d
+		groups := make([]*bitset.BitSet, 0, 4)
+		for _, n := range []*Node{e.Left(), e.Right()} {
+			other := e.Right()
+			if n == other {
+				other = e.Left()
+			}
+			for i, next := range n.Neigh() {
+				if next != other {
+					groups = append(groups, sets[n.Id()][i])
+				}
+			}
+		}
+		if len(groups) != 4 {
+			continue
+		}
+		a, b, c, d := groups[0], groups[1], groups[2], groups[3]
+		q1 := s.quartetFrequency(a, b, c, d)
+		q2 := s.quartetFrequency(a, c, b, d)
+		q3 := s.quartetFrequency(a, d, b, c)
+		e.SetSupport(NIL_SUPPORT)
+		if q1+q2+q3 > 0 {
+			e.SetSupport(q1 / (q1 + q2 + q3))
+		}
+	}
+}
+
+// Number of gene tree quartets (x,y,z,w), with x in a, y in b, z in c and w in
+// d, having the topology xy|zw, each being counted twice
+func (s *quartetSpeciesSearch) quartetFrequency(a, b, c, d *bitset.BitSet) (freq float64) {
+	groups := [4]*bitset.BitSet{a, b, c, d}
+	for n := 0; n < len(s.nodes)-1; n++ {
+		sets := s.parts[s.nodes[n]:s.nodes[n+1]]
+		counts := make([][4]float64, len(sets))
+		var tot [4]float64
+		var sab, scd float64
+		for p, set := range sets {
+			for i, g := range groups {
+				counts[p][i] = float64(set.IntersectionCardinality(g))
+				tot[i] += counts[p][i]
+			}
+			sab += counts[p][0] * counts[p][1]
+			scd += counts[p][2] * counts[p][3]
+		}
+		for _, c := range counts {
+			// z and w in the same subtree, x and y in two other different subtrees
+			freq += c[2] * c[3] * ((tot[0]-c[0])*(tot[1]-c[1]) - (sab - c[0]*c[1]))
+			// x and y in the same subtree, z and w in two other different subtrees
+			freq += c[0] * c[1] * ((tot[2]-c[2])*(tot[3]-c[3]) - (scd - c[2]*c[3]))
+		}
+	}
+	return
+}
+
+// Returns, for each node (by id) and each of its neighbors (in the order of
+// Neigh()), the set of the tips of the subtree behind the neighbor, using the
+// given tip index. It sets the ids of the nodes.
+func neighborTipSets(t *Tree, taxaindex map[string]uint, ntaxa uint) (sets [][]*bitset.BitSet) {
+	nodes := t.Nodes()
+	sets = make([][]*bitset.BitSet, len(nodes))
+	down := make([]*bitset.BitSet, len(nodes))
+	for i, n := range nodes {
+		n.SetId(i)
+		sets[i] = make([]*bitset.BitSet, n.Nneigh())
+	}
+	present := bitset.New(ntaxa)
+	for _, tip := range t.Tips() {
+		present.Set(taxaindex[tip.Name()])
+	}
+	neighborTipSetsDown(t.Root(), nil, taxaindex, ntaxa, sets, down)
+	for _, n := range nodes {
+		for i := range n.Neigh() {
+			// Parent of n
+			if sets[n.Id()][i] == nil {
+				sets[n.Id()][i] = present.Difference(down[n.Id()])
+			}
+		}
+	}
+	return
+}
+
+func neighborTipSetsDown(cur, prev *Node, taxaindex map[string]uint, ntaxa uint, sets [][]*bitset.BitSet, down []*bitset.BitSet) *bitset.BitSet {
+	b := bitset.New(ntaxa)
+	if cur.Tip() {
+		b.Set(taxaindex[cur.Name()])
+	}
+	for i, next := range cur.Neigh() {
+		if next != prev {
+			sets[cur.Id()][i] = neighborTipSetsDown(next, cur, taxaindex, ntaxa, sets, down)
+			b.InPlaceUnion(sets[cur.Id()][i])
+		}
+	}
+	down[cur.Id()] = b
+	return b
+}
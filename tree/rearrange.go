@@ -232,3 +232,347 @@ func (n *nni) Undo() (err error) {
 
 	return
 }
+
+// SPRRearranger generates all Subtree Prune and Regraft
+// rearrangements of a tree.
+//
+// For each edge and for each of its two sides, the subtree on that side
+// is pruned (its attachment node must have exactly 3 neighbors), and regrafted
+// on every edge of the remaining tree located at most MaxRadius edges
+// away from the pruning point. If MaxRadius <= 0, there is no limit.
+//
+// The same topology may be generated several times (for example,
+// SPR moves of radius 1 are NNIs, that may be obtained by pruning
+// different subtrees).
+type SPRRearranger struct {
+	MaxRadius int
+}
+
+func (sprr *SPRRearranger) Rearrange(t *Tree, f func(r Rearrangement) bool) {
+	for _, e := range t.Edges() {
+		// Pruned subtree on the right side, attached to e.Left()
+		// and pruned subtree on the left side, attached to e.Right()
+		for _, p := range []*Node{e.Left(), e.Right()} {
+			s := e.Left()
+			if p == s {
+				s = e.Right()
+			}
+			if p.Nneigh() != 3 {
+				continue
+			}
+			for _, target := range sprr.regraftEdges(p, s) {
+				if !f(newSPR(t, p, s, target)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// Lists all edges on which the subtree rooted at s (pruned at p)
+// can be regrafted, given the maximum radius.
+//
+// The edges connected to p are not considered: their merge
+// corresponds to the initial position of the subtree.
+func (sprr *SPRRearranger) regraftEdges(p, s *Node) (targets []*Edge) {
+	var visit func(cur, prev *Node, depth int)
+	targets = make([]*Edge, 0)
+
+	visit = func(cur, prev *Node, depth int) {
+		if sprr.MaxRadius > 0 && depth > sprr.MaxRadius {
+			return
+		}
+		for i, n := range cur.neigh {
+			if n != prev {
+				targets = append(targets, cur.br[i])
+				visit(n, cur, depth+1)
+			}
+		}
+	}
+
+	for _, n := range p.neigh {
+		if n != s {
+			visit(n, p, 1)
+		}
+	}
+	return
+}
+
+// Applies only to nodes having 3 neighbors
+type spr struct {
+	t *Tree
+	// Pruning:
+	//   s       Subtree s (connected to p by edge es)
+	//   |       is pruned, and edges ea and eb are merged
+	//   p       into ea, connecting a and b.
+	//  / \
+	// a   b
+	// Regrafting:
+	// target edge (x-y) is split into two edges:
+	// target (x-p) and eb (p-y).
+	p, s, a, b *Node
+	ea, eb     *Edge
+	x, y       *Node
+	target     *Edge
+	// Initial extremities of eb
+	ebleft, ebright *Node
+	// Indices of a and b in p neighbors
+	ia, ib int
+	// Indices of p in a and b neighbors
+	ja, jb int
+	// Indices of y in x neighbors, and x in y neighbors
+	ix, iy int
+	// Initial lengths and supports of modified edges
+	ealen, eblen, tlen  float64
+	ebsupport, ebpvalue float64
+	easupport, eapvalue float64
+	tsupport, tpvalue   float64
+	// Edges that have been reoriented after
+	// regrafting (if the root is in s or is p)
+	inversed []*Edge
+	// If the SPR has already been applied
+	applied bool
+}
+
+// p: Node at which the subtree is pruned
+// s: Root of the pruned subtree (neighbor of p)
+// target: Edge on which the subtree is regrafted
+func newSPR(t *Tree, p, s *Node, target *Edge) *spr {
+	var a, b *Node
+	for _, n := range p.neigh {
+		if n != s {
+			if a == nil {
+				a = n
+			} else {
+				b = n
+			}
+		}
+	}
+	return &spr{t: t, p: p, s: s, a: a, b: b, target: target}
+}
+
+func (sp *spr) Apply() (err error) {
+	if sp.applied {
+		return
+	}
+	var esindex int
+
+	if esindex, err = sp.p.NodeIndex(sp.s); err != nil {
+		err = fmt.Errorf("Cannot apply SPR with unconnected nodes p s")
+		return
+	}
+	if sp.ia, err = sp.p.NodeIndex(sp.a); err != nil {
+		err = fmt.Errorf("Cannot apply SPR with unconnected nodes p a")
+		return
+	}
+	if sp.ib, err = sp.p.NodeIndex(sp.b); err != nil {
+		err = fmt.Errorf("Cannot apply SPR with unconnected nodes p b")
+		return
+	}
+	if sp.ja, err = sp.a.NodeIndex(sp.p); err != nil {
+		err = fmt.Errorf("Cannot apply SPR with unconnected nodes a p")
+		return
+	}
+	if sp.jb, err = sp.b.NodeIndex(sp.p); err != nil {
+		err = fmt.Errorf("Cannot apply SPR with unconnected nodes b p")
+		return
+	}
+	sp.ea = sp.p.br[sp.ia]
+	sp.eb = sp.p.br[sp.ib]
+	if sp.target == sp.ea || sp.target == sp.eb || sp.target == sp.p.br[esindex] {
+		err = fmt.Errorf("Cannot apply SPR: the target edge is connected to the pruning node")
+		return
+	}
+
+	sp.ealen, sp.easupport, sp.eapvalue = sp.ea.length, sp.ea.support, sp.ea.pvalue
+	sp.eblen, sp.ebsupport, sp.ebpvalue = sp.eb.length, sp.eb.support, sp.eb.pvalue
+	sp.tlen, sp.tsupport, sp.tpvalue = sp.target.length, sp.target.support, sp.target.pvalue
+
+	// Pruning: a and b are connected through ea
+	sp.a.neigh[sp.ja] = sp.b
+	sp.b.neigh[sp.jb] = sp.a
+	sp.b.br[sp.jb] = sp.ea
+	sp.ea.replaceNode(sp.p, sp.b)
+	if sp.ealen != NIL_LENGTH && sp.eblen != NIL_LENGTH {
+		sp.ea.length = sp.ealen + sp.eblen
+	}
+	sp.ea.support, sp.ea.pvalue = NIL_SUPPORT, NIL_PVALUE
+
+	// Regrafting: target edge is split into x-p and p-y
+	sp.x, sp.y = sp.target.Left(), sp.target.Right()
+	if sp.ix, err = sp.x.NodeIndex(sp.y); err != nil {
+		err = fmt.Errorf("Cannot apply SPR: the target edge does not connect its nodes")
+		return
+	}
+	if sp.iy, err = sp.y.NodeIndex(sp.x); err != nil {
+		err = fmt.Errorf("Cannot apply SPR: the target edge does not connect its nodes")
+		return
+	}
+	sp.x.neigh[sp.ix] = sp.p
+	sp.y.neigh[sp.iy] = sp.p
+	sp.y.br[sp.iy] = sp.eb
+	sp.target.replaceNode(sp.y, sp.p)
+	sp.ebleft, sp.ebright = sp.eb.Left(), sp.eb.Right()
+	sp.eb.setLeft(sp.p)
+	sp.eb.setRight(sp.y)
+	sp.p.neigh[sp.ia] = sp.x
+	sp.p.br[sp.ia] = sp.target
+	sp.p.neigh[sp.ib] = sp.y
+	sp.p.br[sp.ib] = sp.eb
+	if sp.tlen != NIL_LENGTH {
+		sp.target.length = sp.tlen / 2.0
+		sp.eb.length = sp.tlen / 2.0
+	} else {
+		sp.eb.length = NIL_LENGTH
+	}
+	sp.eb.support, sp.eb.pvalue = sp.tsupport, sp.tpvalue
+
+	// If the root is p or is in the pruned subtree
+	// some edges must be reoriented
+	sp.inversed = nil
+	if sp.t.Root() == sp.p || sp.p.br[esindex].Right() == sp.p {
+		sp.inversed = sp.t.reorientEdges()
+	}
+
+	sp.applied = true
+	return
+}
+
+func (sp *spr) Undo() (err error) {
+	if !sp.applied {
+		return
+	}
+
+	for _, e := range sp.inversed {
+		e.Inverse()
+	}
+	sp.inversed = nil
+
+	// Un-regrafting
+	sp.p.neigh[sp.ia] = sp.a
+	sp.p.br[sp.ia] = sp.ea
+	sp.p.neigh[sp.ib] = sp.b
+	sp.p.br[sp.ib] = sp.eb
+	sp.x.neigh[sp.ix] = sp.y
+	sp.y.neigh[sp.iy] = sp.x
+	sp.y.br[sp.iy] = sp.target
+	sp.target.replaceNode(sp.p, sp.y)
+	sp.eb.setLeft(sp.ebleft)
+	sp.eb.setRight(sp.ebright)
+
+	// Un-pruning
+	sp.a.neigh[sp.ja] = sp.p
+	sp.b.neigh[sp.jb] = sp.p
+	sp.b.br[sp.jb] = sp.eb
+	sp.ea.replaceNode(sp.b, sp.p)
+
+	sp.ea.length, sp.ea.support, sp.ea.pvalue = sp.ealen, sp.easupport, sp.eapvalue
+	sp.eb.length, sp.eb.support, sp.eb.pvalue = sp.eblen, sp.ebsupport, sp.ebpvalue
+	sp.target.length, sp.target.support, sp.target.pvalue = sp.tlen, sp.tsupport, sp.tpvalue
+
+	sp.applied = false
+	return
+}
+
+// Orients all edges of the tree from the root to the tips
+// (left: parent, right: child), and returns the edges that
+// have been inversed.
+func (t *Tree) reorientEdges() (inversed []*Edge) {
+	inversed = make([]*Edge, 0)
+	t.PreOrder(func(cur, prev *Node, e *Edge) bool {
+		if prev != nil && e.Left() != prev {
+			e.Inverse()
+			inversed = append(inversed, e)
+		}
+		return true
+	})
+	return
+}
+
+// TBRRearranger generates all Tree Bisection and Reconnection
+// rearrangements of a tree.
+//
+// For each edge, the tree is bisected into two subtrees, which are
+// then reconnected by an edge joining any edge of the first subtree
+// to any edge of the second subtree. Reconnection edges are located at
+// most MaxRadius edges away from the bisection point, on each side.
+// If MaxRadius <= 0, there is no limit.
+//
+// Nodes at which subtrees are reconnected must have exactly 3 neighbors.
+// As for SPRs, the same topology may be generated several times.
+type TBRRearranger struct {
+	MaxRadius int
+}
+
+func (tbrr *TBRRearranger) Rearrange(t *Tree, f func(r Rearrangement) bool) {
+	sprr := &SPRRearranger{MaxRadius: tbrr.MaxRadius}
+	for _, e := range t.Edges() {
+		u, v := e.Left(), e.Right()
+		// nil: the subtree keeps its initial attachment point
+		utargets := []*Edge{nil}
+		vtargets := []*Edge{nil}
+		if u.Nneigh() == 3 {
+			utargets = append(utargets, sprr.regraftEdges(u, v)...)
+		}
+		if v.Nneigh() == 3 {
+			vtargets = append(vtargets, sprr.regraftEdges(v, u)...)
+		}
+		for _, ut := range utargets {
+			for _, vt := range vtargets {
+				if ut == nil && vt == nil {
+					continue
+				}
+				if !f(newTBR(t, u, v, ut, vt)) {
+					return
+				}
+			}
+		}
+	}
+}
+
+// A TBR is applied as the composition of (at most) two SPRs:
+//   1. The v side is pruned at u and regrafted on ut (u side);
+//   2. The u side is pruned at v and regrafted on vt (v side).
+// Undo undoes them in reverse order.
+type tbr struct {
+	sprs []*spr
+	// Number of SPRs that have been applied
+	napplied int
+}
+
+// u,v: Extremities of the bisected edge
+// ut: Edge of the u side on which u is moved (nil: no change)
+// vt: Edge of the v side on which v is moved (nil: no change)
+func newTBR(t *Tree, u, v *Node, ut, vt *Edge) *tbr {
+	sprs := make([]*spr, 0, 2)
+	if ut != nil {
+		sprs = append(sprs, newSPR(t, u, v, ut))
+	}
+	if vt != nil {
+		sprs = append(sprs, newSPR(t, v, u, vt))
+	}
+	return &tbr{sprs: sprs}
+}
+
+func (tb *tbr) Apply() (err error) {
+	if tb.napplied > 0 {
+		return
+	}
+	for _, s := range tb.sprs {
+		if err = s.Apply(); err != nil {
+			tb.Undo()
+			return
+		}
+		tb.napplied++
+	}
+	return
+}
+
+func (tb *tbr) Undo() (err error) {
+	for ; tb.napplied > 0; tb.napplied-- {
+		if err = tb.sprs[tb.napplied-1].Undo(); err != nil {
+			return
+		}
+	}
+	return
+}
@@ -0,0 +1,163 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+)
+
+const (
+	RECONCILIATION_SPECIATION  = "S"
+	RECONCILIATION_DUPLICATION = "D"
+)
+
+// Summary of the reconciliation of a gene tree with a species tree
+type ReconciliationStats struct {
+	Speciations  int // Number of speciation nodes
+	Duplications int // Number of duplication nodes
+	Losses       int // Number of gene losses
+}
+
+// Structure giving the parent and depth of each node of the species tree
+type speciesIndex struct {
+	parent []*Node
+	depth  []int
+	tips   map[string]*Node
+}
+
+// Reconciles the rooted gene tree with the rooted species tree, using the LCA
+// mapping (Goodman et al., 1979; Page, 1994): each gene tree tip is mapped to the
+// species tree tip of its species, and each internal gene tree node is mapped to
+// the most recent common ancestor of the species of its children.
+//
+// An internal gene tree node is a duplication if it is mapped to the same species
+// node as one of its children, and a speciation otherwise. The number of losses
+// on each gene tree branch from g to its child c is the number of species tree
+// nodes between M(g) and M(c) (M(g) excluded if g is a speciation).
+//
+// The species of each gene tree tip is given in the species map (gene tip name =>
+// species name). Tips that are not in the map are considered to have the species
+// of their own name.
+//
+// It adds a comment to each internal node of the gene tree:
+// RECONCILIATION_DUPLICATION or RECONCILIATION_SPECIATION, and returns the
+// numbers of duplications, speciations and losses.
+//
+// An error is returned if one of the trees is not rooted, or if a species is
+// not present in the species tree.
+func Reconcile(gene, species *Tree, speciesmap map[string]string) (stats ReconciliationStats, err error) {
+	var sindex *speciesIndex
+
+	if !gene.Rooted() {
+		err = errors.New("The gene tree must be rooted")
+		return
+	}
+	if !species.Rooted() {
+		err = errors.New("The species tree must be rooted")
+		return
+	}
+	if sindex, err = newSpeciesIndex(species); err != nil {
+		return
+	}
+	_, err = reconcileRecur(gene.Root(), nil, sindex, speciesmap, &stats)
+	return
+}
+
+// Indexes the nodes of the species tree
+func newSpeciesIndex(species *Tree) (s *speciesIndex, err error) {
+	nodes := species.Nodes()
+	s = &speciesIndex{
+		parent: make([]*Node, len(nodes)),
+		depth:  make([]int, len(nodes)),
+		tips:   make(map[string]*Node),
+	}
+	for i, n := range nodes {
+		n.SetId(i)
+	}
+	species.PreOrder(func(cur, prev *Node, e *Edge) bool {
+		s.parent[cur.Id()] = prev
+		if prev != nil {
+			s.depth[cur.Id()] = s.depth[prev.Id()] + 1
+		}
+		if cur.Tip() {
+			if _, ok := s.tips[cur.Name()]; ok {
+				err = fmt.Errorf("Species %s is present several times in the species tree", cur.Name())
+				return false
+			}
+			s.tips[cur.Name()] = cur
+		}
+		return true
+	})
+	return
+}
+
+// Most recent common ancestor of the two species nodes
+func (s *speciesIndex) lca(n1, n2 *Node) *Node {
+	for s.depth[n1.Id()] > s.depth[n2.Id()] {
+		n1 = s.parent[n1.Id()]
+	}
+	for s.depth[n2.Id()] > s.depth[n1.Id()] {
+		n2 = s.parent[n2.Id()]
+	}
+	for n1 != n2 {
+		n1 = s.parent[n1.Id()]
+		n2 = s.parent[n2.Id()]
+	}
+	return n1
+}
+
+// Maps the gene subtree to the species tree, and returns its species node
+func reconcileRecur(cur, prev *Node, sindex *speciesIndex, speciesmap map[string]string, stats *ReconciliationStats) (mapping *Node, err error) {
+	var ok bool
+
+	if cur.Tip() {
+		name := cur.Name()
+		if sp, ok2 := speciesmap[name]; ok2 {
+			name = sp
+		}
+		if mapping, ok = sindex.tips[name]; !ok {
+			err = fmt.Errorf("Species %s of gene %s is not present in the species tree", name, cur.Name())
+		}
+		return
+	}
+
+	children := make([]*Node, 0, cur.Nneigh())
+	for _, next := range cur.Neigh() {
+		if next == prev {
+			continue
+		}
+		var m *Node
+		if m, err = reconcileRecur(next, cur, sindex, speciesmap, stats); err != nil {
+			return
+		}
+		children = append(children, m)
+		if mapping == nil {
+			mapping = m
+		} else {
+			mapping = sindex.lca(mapping, m)
+		}
+	}
+
+	duplication := false
+	for _, m := range children {
+		if m == mapping {
+			duplication = true
+		}
+	}
+	if duplication {
+		stats.Duplications++
+		cur.AddComment(RECONCILIATION_DUPLICATION)
+	} else {
+		stats.Speciations++
+		cur.AddComment(RECONCILIATION_SPECIATION)
+	}
+
+	for _, m := range children {
+		// Species nodes between the two mappings
+		losses := sindex.depth[m.Id()] - sindex.depth[mapping.Id()]
+		if !duplication {
+			losses--
+		}
+		stats.Losses += losses
+	}
+	return
+}
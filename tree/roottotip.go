@@ -0,0 +1,284 @@
+package tree
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+const (
+	RTT_R2  = iota // Root-to-tip regression: maximizes R²
+	RTT_RMS        // Root-to-tip regression: minimizes the residual mean square
+)
+
+// Result of a root-to-tip regression
+type RootToTipStats struct {
+	Rate      float64       // Slope of the regression: clock rate
+	TMRCA     float64       // Date of the root: date at which the regression line reaches 0
+	R2        float64       // Coefficient of determination of the regression
+	RMS       float64       // Residual mean square
+	Residuals []TipResidual // Residual of each tip
+}
+
+// Root-to-tip distance of a tip, and its residual in the regression
+type TipResidual struct {
+	Name     string
+	Date     float64
+	Distance float64
+	Residual float64
+}
+
+// Sums over a set of tips of their dates t and of their distances
+// d to a node: number of tips, sum of t, d, d² and t.d
+type rttSums struct {
+	n, t, d, dd, td float64
+}
+
+// Sums of the distances of the tips to a node located at distance l
+func (s rttSums) shift(l float64) rttSums {
+	return rttSums{
+		n:  s.n,
+		t:  s.t,
+		d:  s.d + s.n*l,
+		dd: s.dd + 2*l*s.d + s.n*l*l,
+		td: s.td + l*s.t,
+	}
+}
+
+func (s rttSums) add(s2 rttSums) rttSums {
+	return rttSums{s.n + s2.n, s.t + s2.t, s.d + s2.d, s.dd + s2.dd, s.td + s2.td}
+}
+
+func (s rttSums) sub(s2 rttSums) rttSums {
+	return rttSums{s.n - s2.n, s.t - s2.t, s.d - s2.d, s.dd - s2.dd, s.td - s2.td}
+}
+
+// Reroots the tree at the position maximizing the fit of the regression of
+// root-to-tip distances against tip dates, as in TempEst (Rambaut et al., 2016).
+//
+// All the positions on all the branches are considered: for each branch,
+// root-to-tip distances are linear functions of the position of the root on the
+// branch, and the best position is computed analytically. The criterion is either
+// RTT_R2 (maximizes R², among positions giving a positive rate if any) or RTT_RMS
+// (minimizes the residual mean square).
+//
+// Dates are given for each tip name, and all tips must have a date. All branch
+// lengths must be defined.
+//
+// It returns the clock rate, the TMRCA, and the residual of each tip of the
+// regression, after rerooting.
+func (t *Tree) RootToTipRegression(dates map[string]float64, criterion int) (stats RootToTipStats, err error) {
+	var nodes []*Node
+	var bestparent, bestchild *Node
+	var bestpos float64
+	var bestscore float64 = math.Inf(-1)
+	var bestpositive bool
+
+	if criterion != RTT_R2 && criterion != RTT_RMS {
+		err = fmt.Errorf("Unknown root-to-tip regression criterion: %d", criterion)
+		return
+	}
+	tips := t.Tips()
+	if len(tips) < 3 {
+		err = errors.New("At least 3 tips are required for the root-to-tip regression")
+		return
+	}
+	var sumt, sumtt float64
+	for _, tip := range tips {
+		d, ok := dates[tip.Name()]
+		if !ok {
+			err = fmt.Errorf("Tip %s has no date", tip.Name())
+			return
+		}
+		sumt += d
+		sumtt += d * d
+	}
+	n := float64(len(tips))
+	stt := sumtt - sumt*sumt/n
+	if stt <= 0 {
+		err = errors.New("All tips have the same date")
+		return
+	}
+	for _, e := range t.Edges() {
+		if e.Length() == NIL_LENGTH {
+			err = errors.New("All branch lengths must be defined")
+			return
+		}
+	}
+
+	t.UnRoot()
+	nodes = t.Nodes()
+	for i, node := range nodes {
+		node.SetId(i)
+	}
+	// Sums of the tips of the subtree of each node, measured from the node,
+	// and of the other tips, measured from its parent
+	down := make([]rttSums, len(nodes))
+	up := make([]rttSums, len(nodes))
+	rttDown(t.Root(), nil, dates, down)
+	rttUp(t.Root(), nil, nil, down, up)
+
+	t.PreOrder(func(cur, prev *Node, e *Edge) bool {
+		if prev == nil {
+			return true
+		}
+		// Position x of the root on the edge, from the parent node: tips under
+		// cur are at distance a-x, and the others at distance a+x
+		l := e.Length()
+		right := down[cur.Id()].shift(l)
+		left := up[cur.Id()]
+		a := right.d + left.d
+		b := left.n - right.n
+		aa := right.dd + left.dd
+		ab := left.d - right.d
+		ta := right.td + left.td
+		tb := left.t - right.t
+
+		// S_td(x) = u0 + u1.x, and S_dd(x) = c0 + c1.x + c2.x²
+		u0, u1 := ta-sumt*a/n, tb-sumt*b/n
+		c0, c1, c2 := aa-a*a/n, 2*(ab-a*b/n), n-b*b/n
+
+		candidates := []float64{0, l}
+		switch criterion {
+		case RTT_R2:
+			// Derivative of S_td²/S_dd
+			if den := u1*c1 - 2*u0*c2; den != 0 {
+				candidates = append(candidates, (u0*c1-2*u1*c0)/den)
+			}
+		case RTT_RMS:
+			// Minimum of RSS = S_dd - S_td²/S_tt
+			if r2 := c2 - u1*u1/stt; r2 > 0 {
+				candidates = append(candidates, -(c1-2*u0*u1/stt)/(2*r2))
+			}
+		}
+		for _, x := range candidates {
+			if x < 0 || x > l {
+				continue
+			}
+			std := u0 + u1*x
+			sdd := c0 + c1*x + c2*x*x
+			var score float64
+			positive := true
+			switch criterion {
+			case RTT_R2:
+				if sdd > 0 {
+					score = std * std / (stt * sdd)
+				}
+				positive = std > 0
+			case RTT_RMS:
+				score = -(sdd - std*std/stt)
+			}
+			if (positive && !bestpositive) || (positive == bestpositive && score > bestscore) {
+				bestscore = score
+				bestparent, bestchild = prev, cur
+				bestpos = x
+				bestpositive = positive
+			}
+		}
+		return true
+	})
+
+	if err = t.rootOnEdge(bestparent, bestchild, bestpos); err != nil {
+		return
+	}
+	stats = t.rootToTipStats(dates)
+	return
+}
+
+// Sums of the tips of the subtree of each node, measured from the node
+func rttDown(cur, prev *Node, dates map[string]float64, down []rttSums) rttSums {
+	var s rttSums
+	if cur.Tip() {
+		d := dates[cur.Name()]
+		s = rttSums{n: 1, t: d}
+	}
+	for i, next := range cur.Neigh() {
+		if next != prev {
+			s = s.add(rttDown(next, cur, dates, down).shift(cur.Edges()[i].Length()))
+		}
+	}
+	down[cur.Id()] = s
+	return s
+}
+
+// Sums of the tips that are not in the subtree of each node, measured from
+// its parent
+func rttUp(cur, prev *Node, preve *Edge, down, up []rttSums) {
+	// All the tips, measured from cur
+	total := down[cur.Id()]
+	if prev != nil {
+		total = total.add(up[cur.Id()].shift(preve.Length()))
+	}
+	for i, next := range cur.Neigh() {
+		if next != prev {
+			e := cur.Edges()[i]
+			up[next.Id()] = total.sub(down[next.Id()].shift(e.Length()))
+			rttUp(next, cur, e, down, up)
+		}
+	}
+}
+
+// Roots the tree on the edge connecting the two nodes, at the given
+// distance from node1
+func (t *Tree) rootOnEdge(node1, node2 *Node, pos float64) error {
+	i, err := node1.NodeIndex(node2)
+	if err != nil {
+		return err
+	}
+	e := node1.Edges()[i]
+	l, s := e.Length(), e.Support()
+	newroot := t.NewNode()
+	node1.delNeighbor(node2)
+	node2.delNeighbor(node1)
+	e1 := t.ConnectNodes(newroot, node1)
+	e2 := t.ConnectNodes(newroot, node2)
+	e1.SetLength(pos)
+	e2.SetLength(l - pos)
+	e1.SetSupport(s)
+	e2.SetSupport(s)
+	if err = t.Reroot(newroot); err != nil {
+		return err
+	}
+	return t.UpdateTipIndex()
+}
+
+// Regression of root-to-tip distances against dates
+func (t *Tree) rootToTipStats(dates map[string]float64) (stats RootToTipStats) {
+	var s rttSums
+	var sumtt float64
+
+	distances := make(map[*Node]float64)
+	stats.Residuals = make([]TipResidual, 0)
+	t.PreOrder(func(cur, prev *Node, e *Edge) bool {
+		if prev != nil {
+			distances[cur] = distances[prev] + e.Length()
+		}
+		if cur.Tip() {
+			d, date := distances[cur], dates[cur.Name()]
+			s = s.add(rttSums{1, date, d, d * d, date * d})
+			sumtt += date * date
+			stats.Residuals = append(stats.Residuals, TipResidual{Name: cur.Name(), Date: date, Distance: d})
+		}
+		return true
+	})
+
+	stt := sumtt - s.t*s.t/s.n
+	std := s.td - s.t*s.d/s.n
+	sdd := s.dd - s.d*s.d/s.n
+	stats.Rate = std / stt
+	intercept := (s.d - stats.Rate*s.t) / s.n
+	stats.TMRCA = math.NaN()
+	if stats.Rate != 0 {
+		stats.TMRCA = -intercept / stats.Rate
+	}
+	var rss float64
+	for i, r := range stats.Residuals {
+		stats.Residuals[i].Residual = r.Distance - (intercept + stats.Rate*r.Date)
+		rss += stats.Residuals[i].Residual * stats.Residuals[i].Residual
+	}
+	if sdd > 0 {
+		stats.R2 = std * std / (stt * sdd)
+	}
+	stats.RMS = rss / (s.n - 2)
+	return
+}
@@ -0,0 +1,373 @@
+package tree
+
+import (
+	"errors"
+	"math"
+)
+
+// Returns the distinct bipartitions (splits) defined by the given edges,
+// and the sum of the lengths of the edges defining each of them (edges
+// around the root of rooted trees define the same bipartition).
+//
+// If tips is false, then trivial bipartitions (tip edges) are not taken into account.
+// If lengths is true, then returns an error if a branch length is not defined.
+//
+// Bitsets must be initialized (see ReinitIndexes)
+func uniqueSplits(edges []*Edge, tips, lengths bool) (splits []*Edge, splitlengths []float64, err error) {
+	index := NewEdgeIndex(uint64(len(edges)*2), 0.75)
+	splits = make([]*Edge, 0, len(edges))
+	splitlengths = make([]float64, 0, len(edges))
+	for _, e := range edges {
+		if e.Bitset() == nil {
+			err = errors.New("Bitsets have not been initialized")
+			return
+		}
+		// Trivial bipartition around the root of a rooted tree
+		if c := e.Bitset().Count(); !tips && (c <= 1 || c >= e.Bitset().Len()-1) {
+			continue
+		}
+		l := e.Length()
+		if lengths && l == NIL_LENGTH {
+			err = errors.New("All branch lengths must be defined")
+			return
+		}
+		if v, ok := index.Value(e); ok {
+			// Undefined lengths are not summed
+			if splitlengths[v.Count] == NIL_LENGTH {
+				splitlengths[v.Count] = l
+			} else if l != NIL_LENGTH {
+				splitlengths[v.Count] += l
+			}
+			continue
+		}
+		index.PutEdgeValue(e, len(splits), l)
+		splits = append(splits, e)
+		splitlengths = append(splitlengths, l)
+	}
+	return
+}
+
+// Computes the Robinson-Foulds distance (Robinson & Foulds, 1979) between
+// the two trees: the number of internal bipartitions present in only one of
+// the two trees.
+//
+// It also returns the normalized distance: the distance divided by the total
+// number of internal bipartitions of both trees (0 if both trees are star trees).
+//
+// It assumes that ReinitIndexes() has been called on both trees
+func RobinsonFoulds(t1, t2 *Tree) (dist int, norm float64, err error) {
+	var splits1, splits2 []*Edge
+
+	if splits1, splits2, _, err = internalSplits(t1, t2); err != nil {
+		return
+	}
+	index := NewEdgeIndex(uint64(len(splits1)*2+1), 0.75)
+	for i, e := range splits1 {
+		index.PutEdgeValue(e, i, e.Length())
+	}
+	common := 0
+	for _, e := range splits2 {
+		if _, ok := index.Value(e); ok {
+			common++
+		}
+	}
+	dist = len(splits1) + len(splits2) - 2*common
+	if total := len(splits1) + len(splits2); total > 0 {
+		norm = float64(dist) / float64(total)
+	}
+	return
+}
+
+// Computes the weighted Robinson-Foulds distance (Robinson & Foulds, 1979)
+// between the two trees: the sum, over all bipartitions of both trees, of
+// the absolute difference between their branch lengths in the two trees
+// (a bipartition absent from a tree having a length of 0).
+//
+// All branches, including tip branches, are taken into account, and all branch
+// lengths must be defined.
+//
+// It assumes that ReinitIndexes() has been called on both trees
+func WeightedRobinsonFoulds(t1, t2 *Tree) (dist float64, err error) {
+	err = compareSplitLengths(t1, t2, func(l1, l2 float64) {
+		dist += math.Abs(l1 - l2)
+	})
+	return
+}
+
+// Computes the branch score distance (Kuhner & Felsenstein, 1994) between
+// the two trees: the square root of the sum, over all bipartitions of both
+// trees, of the squared difference between their branch lengths in the two
+// trees (a bipartition absent from a tree having a length of 0).
+//
+// All branches, including tip branches, are taken into account, and all branch
+// lengths must be defined.
+//
+// It assumes that ReinitIndexes() has been called on both trees
+func BranchScoreDistance(t1, t2 *Tree) (dist float64, err error) {
+	err = compareSplitLengths(t1, t2, func(l1, l2 float64) {
+		dist += (l1 - l2) * (l1 - l2)
+	})
+	dist = math.Sqrt(dist)
+	return
+}
+
+// Calls the given function with the lengths of each bipartition in both trees
+// (0 if the bipartition is absent from one of the trees)
+func compareSplitLengths(t1, t2 *Tree, f func(l1, l2 float64)) (err error) {
+	var splits1, splits2 []*Edge
+	var lengths1, lengths2 []float64
+
+	if err = t1.CompareTipIndexes(t2); err != nil {
+		return
+	}
+	if splits1, lengths1, err = uniqueSplits(t1.Edges(), true, true); err != nil {
+		return
+	}
+	if splits2, lengths2, err = uniqueSplits(t2.Edges(), true, true); err != nil {
+		return
+	}
+	index := NewEdgeIndex(uint64(len(splits1)*2), 0.75)
+	for i, e := range splits1 {
+		index.PutEdgeValue(e, i, lengths1[i])
+	}
+	found := make([]bool, len(splits1))
+	for i, e := range splits2 {
+		if v, ok := index.Value(e); ok {
+			found[v.Count] = true
+			f(lengths1[v.Count], lengths2[i])
+		} else {
+			f(0, lengths2[i])
+		}
+	}
+	for i, l := range lengths1 {
+		if !found[i] {
+			f(l, 0)
+		}
+	}
+	return
+}
+
+// Computes the matching split distance (Bogdanowicz & Giaro, 2012) between
+// the two trees: internal bipartitions of both trees are matched so that the
+// sum of the distances between matched bipartitions is minimal. The distance
+// between two bipartitions A1|B1 and A2|B2 is the minimum number of tips to
+// move to transform one into the other: min(|A1 xor A2|, |A1 xor B2|).
+//
+// If the trees do not have the same number of internal bipartitions
+// (multifurcations), then unmatched bipartitions A|B are matched with
+// an empty bipartition, at a cost of min(|A|,|B|).
+//
+// It assumes that ReinitIndexes() has been called on both trees
+func MatchingSplitDistance(t1, t2 *Tree) (dist float64, err error) {
+	var splits1, splits2 []*Edge
+	var ntips int
+
+	if splits1, splits2, ntips, err = internalSplits(t1, t2); err != nil {
+		return
+	}
+	n := uint(ntips)
+	size := len(splits1)
+	if len(splits2) > size {
+		size = len(splits2)
+	}
+	cost := make([][]float64, size)
+	for i := range cost {
+		cost[i] = make([]float64, size)
+		for j := range cost[i] {
+			switch {
+			case i < len(splits1) && j < len(splits2):
+				b1, b2 := splits1[i].Bitset(), splits2[j].Bitset()
+				inter := b1.IntersectionCardinality(b2)
+				a1, a2 := b1.Count(), b2.Count()
+				// |A1 xor A2| and |A1 xor B2|
+				d1 := a1 + a2 - 2*inter
+				d2 := n - d1
+				cost[i][j] = float64(d1)
+				if d2 < d1 {
+					cost[i][j] = float64(d2)
+				}
+			case i < len(splits1):
+				cost[i][j] = float64(smallestSide(splits1[i], n))
+			case j < len(splits2):
+				cost[i][j] = float64(smallestSide(splits2[j], n))
+			}
+		}
+	}
+	_, dist = minCostAssignment(cost)
+	return
+}
+
+// Computes the clustering information distance (Smith, 2020) between the two
+// trees, in bits: CID = H(T1) + H(T2) - 2.MCI(T1,T2), where H(T) is the sum of
+// the entropies of the internal bipartitions of T, and MCI(T1,T2) the
+// mutual clustering information of the two trees, i.e. the maximum sum of the
+// mutual information between bipartitions of T1 and T2, over all matchings
+// of the bipartitions.
+//
+// It returns the distance and its normalized version (divided by H(T1)+H(T2),
+// 0 if both trees are star trees).
+//
+// It assumes that ReinitIndexes() has been called on both trees
+func ClusteringInformationDistance(t1, t2 *Tree) (dist, norm float64, err error) {
+	var splits1, splits2 []*Edge
+	var ntips int
+	var h float64
+
+	if splits1, splits2, ntips, err = internalSplits(t1, t2); err != nil {
+		return
+	}
+	n := float64(ntips)
+	for _, s := range splits1 {
+		h += splitEntropy(float64(s.Bitset().Count()), n)
+	}
+	for _, s := range splits2 {
+		h += splitEntropy(float64(s.Bitset().Count()), n)
+	}
+
+	size := len(splits1)
+	if len(splits2) > size {
+		size = len(splits2)
+	}
+	// We search for the maximum mutual information => negative costs
+	cost := make([][]float64, size)
+	for i := range cost {
+		cost[i] = make([]float64, size)
+		if i >= len(splits1) {
+			continue
+		}
+		for j := range splits2 {
+			b1, b2 := splits1[i].Bitset(), splits2[j].Bitset()
+			cost[i][j] = -splitMutualInformation(
+				float64(b1.Count()), float64(b2.Count()), float64(b1.IntersectionCardinality(b2)), n)
+		}
+	}
+	_, mci := minCostAssignment(cost)
+	dist = h + 2*mci
+	// Rounding errors
+	if dist < 0 {
+		dist = 0
+	}
+	if h > 0 {
+		norm = dist / h
+	}
+	return
+}
+
+// Returns the distinct internal bipartitions of both trees, and their number of tips
+func internalSplits(t1, t2 *Tree) (splits1, splits2 []*Edge, ntips int, err error) {
+	if err = t1.CompareTipIndexes(t2); err != nil {
+		return
+	}
+	if splits1, _, err = uniqueSplits(t1.Edges(), false, false); err != nil {
+		return
+	}
+	if splits2, _, err = uniqueSplits(t2.Edges(), false, false); err != nil {
+		return
+	}
+	ntips = len(t1.tipIndex)
+	return
+}
+
+// Number of tips on the smallest side of the bipartition
+func smallestSide(e *Edge, n uint) uint {
+	c := e.Bitset().Count()
+	if n-c < c {
+		return n - c
+	}
+	return c
+}
+
+// Entropy (in bits) of a bipartition of n tips having a tips on one side
+func splitEntropy(a, n float64) float64 {
+	return -xlog2x(a/n) - xlog2x((n-a)/n)
+}
+
+// Mutual information (in bits) between two bipartitions A1|B1 and A2|B2
+// of n tips, with a1=|A1|, a2=|A2| and inter=|A1 inter A2|
+func splitMutualInformation(a1, a2, inter, n float64) (mi float64) {
+	cells := [4][3]float64{
+		{inter, a1, a2},
+		{a1 - inter, a1, n - a2},
+		{a2 - inter, n - a1, a2},
+		{n - a1 - a2 + inter, n - a1, n - a2},
+	}
+	for _, c := range cells {
+		if c[0] > 0 {
+			mi += c[0] / n * math.Log2(c[0]*n/(c[1]*c[2]))
+		}
+	}
+	return
+}
+
+func xlog2x(x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	return x * math.Log2(x)
+}
+
+// Solves the assignment problem on the given square cost matrix
+// (Hungarian algorithm, O(n^3)): returns for each row the assigned column,
+// and the total cost of the assignment, which is minimal.
+func minCostAssignment(cost [][]float64) (assignment []int, total float64) {
+	n := len(cost)
+	// Potentials of rows (u) and columns (v), and row assigned
+	// to each column (p), indexes being shifted by 1
+	u := make([]float64, n+1)
+	v := make([]float64, n+1)
+	p := make([]int, n+1)
+	way := make([]int, n+1)
+	minv := make([]float64, n+1)
+	used := make([]bool, n+1)
+
+	for i := 1; i <= n; i++ {
+		p[0] = i
+		j0 := 0
+		for j := range minv {
+			minv[j] = math.Inf(1)
+			used[j] = false
+		}
+		for p[j0] != 0 {
+			used[j0] = true
+			i0, delta, j1 := p[j0], math.Inf(1), 0
+			for j := 1; j <= n; j++ {
+				if !used[j] {
+					cur := cost[i0-1][j-1] - u[i0] - v[j]
+					if cur < minv[j] {
+						minv[j] = cur
+						way[j] = j0
+					}
+					if minv[j] < delta {
+						delta = minv[j]
+						j1 = j
+					}
+				}
+			}
+			for j := 0; j <= n; j++ {
+				if used[j] {
+					u[p[j]] += delta
+					v[j] -= delta
+				} else {
+					minv[j] -= delta
+				}
+			}
+			j0 = j1
+		}
+		for j0 != 0 {
+			j1 := way[j0]
+			p[j0] = p[j1]
+			j0 = j1
+		}
+	}
+
+	assignment = make([]int, n)
+	for j := 1; j <= n; j++ {
+		if p[j] > 0 {
+			assignment[p[j]-1] = j - 1
+		}
+	}
+	for i, j := range assignment {
+		total += cost[i][j]
+	}
+	return
+}
@@ -3,6 +3,7 @@ package tree
 import (
 	"errors"
 	"fmt"
+	"math"
 	"math/rand"
 	"strconv"
 
@@ -492,3 +493,177 @@ func allTopologies_recur(t *Tree, nbTips, total int, trees *[]*Tree, tipNames ..
 	}
 	return nil
 }
+
+// Creates a random reconstructed tree (extinct and unsampled lineages are
+// pruned) under a constant rate birth-death process, with speciation rate
+// lambda, extinction rate mu, and sampling fraction rho of the extant lineages.
+//
+//	* nbtips: if > 0, the tree is conditioned on having nbtips sampled tips
+//	* crownage: if > 0, the tree is conditioned on having a crown age (date of the root)
+//	  of crownage. If only nbtips is given, the crown age follows its distribution
+//	  under a uniform prior (Gernhard, 2008; Stadler, 2011)
+//	* branch lengths: in units of time.
+//
+// The tree is simulated as a coalescent point process (Lambert and Stadler,
+// 2013): the depths of the nodes between consecutive tips are independent and
+// identically distributed, and conditioned to be smaller than the crown age.
+func RandomBirthDeathTree(nbtips int, crownage, lambda, mu, rho float64) (*Tree, error) {
+	if lambda <= 0 || mu < 0 {
+		return nil, errors.New("Speciation rate must be > 0 and extinction rate must be >= 0")
+	}
+	if rho <= 0 || rho > 1 {
+		return nil, errors.New("Sampling fraction must be in ]0,1]")
+	}
+	if nbtips <= 0 && crownage <= 0 {
+		return nil, errors.New("The tree must be conditioned on the number of tips or on the crown age")
+	}
+	if nbtips > 0 && nbtips < 2 {
+		return nil, errors.New("Cannot create a birth-death tree with less than 2 tips")
+	}
+
+	bd := birthDeath{lambda: lambda, mu: mu, rho: rho}
+	depths := make([]float64, 0, nbtips)
+	if nbtips > 0 {
+		if crownage <= 0 {
+			crownage = bd.crownAge(nbtips)
+		}
+		// Position of the crown among the node depths is uniform
+		crown := rand.Intn(nbtips - 1)
+		for i := 0; i < nbtips-1; i++ {
+			if i == crown {
+				depths = append(depths, crownage)
+			} else {
+				depths = append(depths, bd.depth(crownage))
+			}
+		}
+	} else {
+		// Two lineages from the crown, each having at least one sampled tip
+		for side := 0; side < 2; side++ {
+			for d := bd.depth(math.Inf(1)); d < crownage; d = bd.depth(math.Inf(1)) {
+				depths = append(depths, d)
+			}
+			if side == 0 {
+				depths = append(depths, crownage)
+			}
+		}
+	}
+
+	t := coalescentPointProcessTree(depths)
+	t.ReinitIndexes()
+	return t, nil
+}
+
+// Constant rate birth-death process with incomplete sampling
+type birthDeath struct {
+	lambda, mu, rho float64
+}
+
+// Inverse of the probability that the depth H of a node of the coalescent
+// point process is > t: 1/P(H > t)
+func (bd birthDeath) invTail(t float64) float64 {
+	r := bd.lambda - bd.mu
+	if r == 0 {
+		return 1 + bd.rho*bd.lambda*t
+	}
+	return 1 + bd.rho*bd.lambda/r*(math.Exp(r*t)-1)
+}
+
+// Depth t such that invTail(t) = y, or +Inf if there is none
+func (bd birthDeath) invTailInverse(y float64) float64 {
+	r := bd.lambda - bd.mu
+	if r == 0 {
+		return (y - 1) / (bd.rho * bd.lambda)
+	}
+	x := 1 + (y-1)*r/(bd.rho*bd.lambda)
+	if x <= 0 {
+		return math.Inf(1)
+	}
+	return math.Log(x) / r
+}
+
+// Random depth of a node of the coalescent point process, conditioned
+// on being smaller than max (may be +Inf)
+func (bd birthDeath) depth(max float64) float64 {
+	tail := 0.0
+	if !math.IsInf(max, 1) {
+		tail = 1.0 / bd.invTail(max)
+	}
+	return bd.invTailInverse(1.0 / (1.0 - rand.Float64()*(1.0-tail)))
+}
+
+// Random crown age of a tree with n tips, under a uniform prior on the crown
+// age: u=1/invTail(crown age) has a density proportional to
+// u²(1-u)^(n-2) + c.u(1-u)^(n-1), with c=(lambda-mu)/(rho.lambda), i.e. a mixture
+// of Beta(3,n-1) and Beta(2,n) distributions if c >= 0. If c < 0, u is drawn
+// by rejection sampling from Beta(3,n-1).
+func (bd birthDeath) crownAge(n int) float64 {
+	c := (bd.lambda - bd.mu) / (bd.rho * bd.lambda)
+	umin := 0.0
+	if c < 0 {
+		umin = 1.0 / (1.0 - 1.0/c)
+	}
+	for {
+		var u float64
+		if c > 0 && rand.Float64() < c/(c+2.0/float64(n-1)) {
+			u = randomBeta(2, float64(n))
+		} else {
+			u = randomBeta(3, float64(n-1))
+			if c < 0 && (u <= umin || rand.Float64() > 1+c*(1/u-1)) {
+				continue
+			}
+		}
+		return bd.invTailInverse(1 / u)
+	}
+}
+
+// Random number following a Beta(a,b) distribution
+func randomBeta(a, b float64) float64 {
+	x := gostats.Gamma(a, 1)
+	y := gostats.Gamma(b, 1)
+	return x / (x + y)
+}
+
+// Builds the ultrametric tree of the coalescent point process given the depths
+// of the nodes between consecutive tips: the node between tips i and i+1 has
+// depth depths[i]. Tips are named randomly Tip0..Tipn.
+func coalescentPointProcessTree(depths []float64) *Tree {
+	t := NewTree()
+	ages := make(map[*Node]float64)
+	names := rand.Perm(len(depths) + 1)
+
+	connect := func(parent, child *Node) {
+		e := t.ConnectNodes(parent, child)
+		e.SetLength(ages[parent] - ages[child])
+	}
+	newTip := func(i int) *Node {
+		tip := t.NewNode()
+		tip.SetName("Tip" + strconv.Itoa(names[i]))
+		ages[tip] = 0
+		return tip
+	}
+
+	// Stack of the nodes of the right-most path, whose right child
+	// is not connected yet
+	stack := []*Node{newTip(0)}
+	for i, d := range depths {
+		child := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for len(stack) > 0 && ages[stack[len(stack)-1]] < d {
+			parent := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			connect(parent, child)
+			child = parent
+		}
+		node := t.NewNode()
+		ages[node] = d
+		connect(node, child)
+		stack = append(stack, node, newTip(i+1))
+	}
+	child := stack[len(stack)-1]
+	for i := len(stack) - 2; i >= 0; i-- {
+		connect(stack[i], child)
+		child = stack[i]
+	}
+	t.SetRoot(child)
+	return t
+}
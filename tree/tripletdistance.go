@@ -0,0 +1,143 @@
+package tree
+
+import (
+	"errors"
+)
+
+// Statistics of the comparison of the triplets of two rooted trees
+// having the same set of tips
+type TripletStats struct {
+	Shared         int64 // Number of triplets resolved the same way in both trees
+	Diff           int64 // Number of triplets resolved differently in both trees
+	Unresolved1    int64 // Number of triplets unresolved in the first tree, resolved in the second tree
+	Unresolved2    int64 // Number of triplets resolved in the first tree, unresolved in the second tree
+	UnresolvedBoth int64 // Number of triplets unresolved in both trees
+	Total          int64 // Total number of triplets: (n choose 3)
+}
+
+// Compares the rooted triplets of the two given rooted trees, which must have
+// the same set of tips. A triplet of tips {a,b,c} is resolved in a tree (e.g. ab|c)
+// if the most recent common ancestor of a and b is a descendant of the most
+// recent common ancestor of a, b, and c, and unresolved otherwise (multifurcation).
+//
+// Returns an error if one of the trees is not rooted.
+//
+// As for QuartetDistance, triplets are not enumerated: for each pair of internal
+// nodes of the two trees, triplets are counted using the number of tips shared by
+// the child subtrees of the two nodes. The complexity is O(n^2.d) in time, with
+// d the maximum degree of the trees, and O(n.d) in memory.
+func TripletDistance(t1, t2 *Tree) (stats TripletStats, err error) {
+	var q1, q2 *quartetTree
+	var tips []int
+	var n int64
+
+	if !t1.Rooted() || !t2.Rooted() {
+		err = errors.New("Triplet distance can only be computed on rooted trees")
+		return
+	}
+	if err = t1.UpdateTipIndex(); err != nil {
+		return
+	}
+	if err = t2.UpdateTipIndex(); err != nil {
+		return
+	}
+	if err = t1.CompareTipIndexes(t2); err != nil {
+		return
+	}
+
+	q1 = newQuartetTree(t1)
+	q2 = newQuartetTree(t2)
+	n = int64(q1.ntips)
+	tips = quartetTipEdges(q1, q2)
+	children1 := q1.childEdges()
+	children2 := q2.childEdges()
+
+	rows := make([]int64, 0)
+	cols := make([]int64, 0)
+	inter := make([][]int32, 0)
+	for _, v := range children1 {
+		inter = quartetIntersections(q1, q2, tips, v, inter)
+		for _, w := range children2 {
+			rows = rows[:0]
+			cols = cols[:0]
+			var all int64
+			for l := range v {
+				var r int64
+				for _, e2 := range w {
+					r += int64(inter[l][e2])
+				}
+				rows = append(rows, r)
+				all += r
+			}
+			if all == 0 {
+				continue
+			}
+			for _, e2 := range w {
+				var c int64
+				for l := range v {
+					c += int64(inter[l][e2])
+				}
+				cols = append(cols, c)
+			}
+			for l := range v {
+				for k, e2 := range w {
+					m := int64(inter[l][e2])
+					if m == 0 {
+						continue
+					}
+					// a and b in the same child subtrees of both nodes, c in
+					// other child subtrees of both nodes
+					stats.Shared += m * (m - 1) / 2 * (all - rows[l] - cols[k] + m)
+					// a and b in the same child subtree of the first node,
+					// a and c in the same child subtree of the second node
+					stats.Diff += m * (cols[k] - m) * (rows[l] - m)
+				}
+			}
+		}
+	}
+
+	stats.Total = n * (n - 1) * (n - 2) / 6
+	r1 := q1.resolvedTriplets()
+	r2 := q2.resolvedTriplets()
+	stats.Unresolved1 = r2 - stats.Shared - stats.Diff
+	stats.Unresolved2 = r1 - stats.Shared - stats.Diff
+	stats.UnresolvedBoth = stats.Total - r1 - r2 + stats.Shared + stats.Diff
+	return
+}
+
+// Returns, for each internal node having at least 2 children,
+// the indexes of its child edges
+func (q *quartetTree) childEdges() (children [][]int) {
+	var byparent map[*Node][]int = make(map[*Node][]int)
+	var order []*Node = make([]*Node, 0)
+
+	for i, low := range q.lower {
+		p := q.parentNode[low.Id()]
+		if _, ok := byparent[p]; !ok {
+			order = append(order, p)
+		}
+		byparent[p] = append(byparent[p], i)
+	}
+	for _, p := range order {
+		if len(byparent[p]) >= 2 {
+			children = append(children, byparent[p])
+		}
+	}
+	return
+}
+
+// Number of rooted triplets that are resolved in the tree: each resolved
+// triplet ab|c is counted at the most recent common ancestor of a, b and c
+func (q *quartetTree) resolvedTriplets() (r int64) {
+	for _, edges := range q.childEdges() {
+		var below int64
+		for _, e := range edges {
+			below += q.sizes[e]
+		}
+		for _, e := range edges {
+			s := q.sizes[e]
+			r += s * (s - 1) / 2 * (below - s)
+		}
+	}
+	return
+}
@@ -0,0 +1,118 @@
+package tree
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	UPGMA_ALGO_UPGMA = iota
+	UPGMA_ALGO_WPGMA
+)
+
+// Builds a rooted ultrametric tree from a distance matrix using
+// UPGMA (Unweighted Pair Group Method with Arithmetic mean).
+//
+//	* names: names of the taxa, in the same order as the rows of the matrix
+//	* dist: square distance matrix
+//
+// When two clusters are joined, the distance between the new cluster and
+// another cluster is the average of the distances between their taxa.
+func UPGMA(names []string, dist [][]float64) (*Tree, error) {
+	return clusteringTree(names, dist, UPGMA_ALGO_UPGMA)
+}
+
+// Builds a rooted ultrametric tree from a distance matrix using
+// WPGMA (Weighted Pair Group Method with Arithmetic mean).
+//
+//	* names: names of the taxa, in the same order as the rows of the matrix
+//	* dist: square distance matrix
+//
+// When two clusters are joined, the distance between the new cluster and
+// another cluster is the mean of the distances of the two joined clusters,
+// whatever their sizes.
+func WPGMA(names []string, dist [][]float64) (*Tree, error) {
+	return clusteringTree(names, dist, UPGMA_ALGO_WPGMA)
+}
+
+// Builds a rooted tree using the given algorithm: UPGMA_ALGO_UPGMA or UPGMA_ALGO_WPGMA
+func clusteringTree(names []string, dist [][]float64, algo int) (t *Tree, err error) {
+	var d [][]float64
+	var nodes []*Node
+	var heights []float64
+	var sizes []int
+	var r, i, j, k, mini, minj int
+	var mind, h float64
+
+	if err = checkDistanceMatrix(names, dist); err != nil {
+		return
+	}
+	if algo != UPGMA_ALGO_UPGMA && algo != UPGMA_ALGO_WPGMA {
+		err = fmt.Errorf("unknown clustering algorithm: %d", algo)
+		return
+	}
+
+	t = NewTree()
+	r = len(names)
+	nodes = make([]*Node, r)
+	heights = make([]float64, r)
+	sizes = make([]int, r)
+	for i = 0; i < r; i++ {
+		nodes[i] = t.NewNode()
+		nodes[i].SetName(names[i])
+		sizes[i] = 1
+	}
+	d = copyMatrix(dist)
+
+	for r > 1 {
+		// We search the closest pair of clusters
+		mini, minj = 0, 1
+		mind = math.Inf(1)
+		for i = 0; i < r; i++ {
+			for j = i + 1; j < r; j++ {
+				if d[i][j] < mind {
+					mind = d[i][j]
+					mini, minj = i, j
+				}
+			}
+		}
+
+		h = mind / 2.0
+		u := t.NewNode()
+		t.ConnectNodes(u, nodes[mini]).SetLength(math.Max(0, h-heights[mini]))
+		t.ConnectNodes(u, nodes[minj]).SetLength(math.Max(0, h-heights[minj]))
+
+		for k = 0; k < r; k++ {
+			if k != mini && k != minj {
+				switch algo {
+				case UPGMA_ALGO_UPGMA:
+					d[mini][k] = (float64(sizes[mini])*d[mini][k] + float64(sizes[minj])*d[minj][k]) / float64(sizes[mini]+sizes[minj])
+				case UPGMA_ALGO_WPGMA:
+					d[mini][k] = 0.5 * (d[mini][k] + d[minj][k])
+				}
+				d[k][mini] = d[mini][k]
+			}
+		}
+		nodes[mini] = u
+		heights[mini] = h
+		sizes[mini] += sizes[minj]
+
+		// We move the last cluster at index minj
+		last := r - 1
+		if minj != last {
+			for k = 0; k < r; k++ {
+				d[minj][k] = d[last][k]
+				d[k][minj] = d[k][last]
+			}
+			d[minj][minj] = 0
+			nodes[minj] = nodes[last]
+			heights[minj] = heights[last]
+			sizes[minj] = sizes[last]
+		}
+		r--
+	}
+
+	t.SetRoot(nodes[0])
+	err = t.ReinitIndexes()
+	return
+}